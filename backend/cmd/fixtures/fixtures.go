@@ -12,6 +12,7 @@ func main() {
 	fixtures.LoadOrganizationFixtures()
 	fixtures.LoadUserFixtures()
 	fixtures.LoadUserInterestFixtures()
+	fixtures.LoadVenueFixtures()
 	fixtures.LoadConcertFixtures()
 	fixtures.LoadCategoryFixtures()
 	fixtures.LoadConcertCategoryFixtures()
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"weezemaster/internal/config"
+	"weezemaster/internal/payments"
+)
+
+// Envoie au serveur local un événement Stripe signé avec STRIPE_WEBHOOK_SECRET, avec la même
+// signature que celle vérifiée par le webhook, pour le tester sans passer par Stripe. Comme les
+// autres commandes, elle se lance depuis son répertoire pour trouver le fichier .env :
+//
+//	cd cmd/stripewebhook && go run . -type payment_intent.succeeded -intent pi_123
+func main() {
+	eventType := flag.String("type", "payment_intent.succeeded", "Type d'événement Stripe")
+	intentID := flag.String("intent", "", "ID du payment intent")
+	target := flag.String("url", "http://localhost:8080/webhooks/stripe", "URL du webhook")
+	flag.Parse()
+
+	if *intentID == "" {
+		log.Fatal("Payment intent ID is required (-intent)")
+	}
+
+	secret := config.StripeWebhookSecret
+	if secret == "" {
+		log.Fatal("Stripe webhook secret is not set or empty")
+	}
+
+	object := map[string]interface{}{"id": *intentID, "object": "payment_intent"}
+	if *eventType == "charge.refunded" {
+		object = map[string]interface{}{"id": "ch_fixture", "object": "charge", "payment_intent": *intentID, "refunded": true}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":     "evt_fixture_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		"object": "event",
+		"type":   *eventType,
+		"data":   map[string]interface{}{"object": object},
+	})
+	if err != nil {
+		log.Fatalf("Error building payload: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := payments.SignStripePayload(payload, timestamp, secret)
+
+	request, err := http.NewRequest("POST", *target, bytes.NewReader(payload))
+	if err != nil {
+		log.Fatalf("Error building request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, signature))
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		log.Fatalf("Error sending webhook: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+	fmt.Printf("%d %s\n", response.StatusCode, body)
+}
@@ -10,6 +10,7 @@ import (
 	"weezemaster/internal/controller"
 	"weezemaster/internal/database"
 	"weezemaster/internal/middleware"
+	"weezemaster/internal/payments"
 
 	_ "weezemaster/docs"
 
@@ -74,13 +75,34 @@ func main() {
 
 	router.Logger.Info("Logger initialized successfully.")
 
+	// La configuration est lue une seule fois ; les sweepers et les requêtes lisent les valeurs en mémoire
+	if err := config.LoadConfig(config.ConfigFile); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := controller.ValidateConfig(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	database.InitDB()
+	controller.StartTicketHoldSweeper()
+	controller.StartEscrowReleaser()
+	controller.StartTicketTransferExpirer()
+	controller.StartTicketListingSweeper()
+	controller.StartWaitlistSweeper()
+	controller.StartConcertLifecycleSweeper()
+	controller.StartUnfulfilledPaymentRefunder()
+
+	if err := payments.Init(); err != nil {
+		log.Fatalf("Failed to initialize payment provider: %v", err)
+	}
 
 	err = config.InitFirebase()
 	if err != nil {
 		log.Fatalf("Failed to initialize Firebase: %v", err)
 	}
 
+	controller.ResumeConcertCancellations()
+
 	router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			c.Response().Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -102,6 +124,7 @@ func main() {
 	authenticated.GET("/users/:id", controller.GetUser, middleware.CheckRole("user", "organizer", "admin"))
 	authenticated.PATCH("/users/:id", controller.UpdateUser, middleware.CheckRole("user", "organizer", "admin"))
 	authenticated.DELETE("/users/:id", controller.DeleteUser, middleware.CheckRole("admin"))
+	authenticated.GET("/users/:id/reputation", controller.GetUserReputation, middleware.CheckRole("user", "organizer", "admin"))
 
 	authenticated.GET("/interests", controller.GetAllInterests, middleware.CheckRole("user", "organizer", "admin"))
 	authenticated.GET("/interests-no-artists", controller.GetAllInterestsWithoutArtists, middleware.CheckRole("admin"))
@@ -130,6 +153,24 @@ func main() {
 	authenticated.PATCH("/tickets/:id", controller.UpdateTicket, middleware.CheckRole("admin"))
 	authenticated.DELETE("/tickets/:id", controller.DeleteTicket, middleware.CheckRole("admin"))
 	authenticated.GET("/tickets/mytickets", controller.GetUserTickets, middleware.CheckRole("user"))
+	authenticated.POST("/tickets/:id/transfer", controller.CreateTicketTransfer, middleware.CheckRole("user"))
+	authenticated.GET("/tickets/:id/ownership", controller.GetTicketOwnership, middleware.CheckRole("user", "admin"))
+	authenticated.GET("/user/transfers", controller.GetTicketTransfers, middleware.CheckRole("user"))
+	authenticated.POST("/waitlist", controller.CreateWaitlistEntry, middleware.CheckRole("user"))
+	authenticated.GET("/user/waitlist", controller.GetUserWaitlist, middleware.CheckRole("user"))
+	authenticated.DELETE("/waitlist/:id", controller.DeleteWaitlistEntry, middleware.CheckRole("user"))
+	authenticated.POST("/sales/:id/rating", controller.CreateSaleRating, middleware.CheckRole("user"))
+
+	authenticated.GET("/fraud/reviews", controller.GetFraudReviews, middleware.CheckRole("admin"))
+	authenticated.POST("/fraud/reviews/:id/approve", controller.ApproveFraudReview, middleware.CheckRole("admin"))
+	authenticated.POST("/fraud/reviews/:id/reject", controller.RejectFraudReview, middleware.CheckRole("admin"))
+	authenticated.POST("/transfers/:id/accept", controller.AcceptTicketTransfer, middleware.CheckRole("user"))
+	authenticated.POST("/transfers/:id/decline", controller.DeclineTicketTransfer, middleware.CheckRole("user"))
+	authenticated.POST("/transfers/:id/cancel", controller.CancelTicketTransfer, middleware.CheckRole("user"))
+	authenticated.GET("/tickets/:id/qrcode", controller.GetTicketQRCode, middleware.CheckRole("user"))
+	authenticated.POST("/checkin", controller.CheckInTicket, middleware.CheckRole("organizer", "admin"))
+	authenticated.POST("/checkin/sync", controller.SyncCheckIns, middleware.CheckRole("organizer", "admin"))
+	authenticated.GET("/checkin/bundle-key", controller.GetCheckInBundleKey, middleware.CheckRole("organizer", "admin"))
 
 	authenticated.GET("/ticketlisting", controller.GetAllTicketListings, middleware.CheckRole("admin"))
 	authenticated.GET("/ticketlisting/:id", controller.GetTicketListings, middleware.CheckRole("admin"))
@@ -137,30 +178,65 @@ func main() {
 	authenticated.PATCH("/ticketlisting/:id", controller.UpdateTicketListing, middleware.CheckRole("user", "admin"))
 	authenticated.DELETE("/ticketlisting/:id", controller.DeleteTicketListing, middleware.CheckRole("user"))
 	authenticated.GET("/ticketlisting/concert/:id", controller.GetTicketListingByConcertId, middleware.CheckRole("user", "organizer", "admin"))
+	router.GET("/ticketlisting/search", controller.SearchTicketListings)
+	router.GET("/search", controller.Search)
 
 	router.GET("/concerts", controller.GetAllConcerts)
+	router.GET("/concerts/nearby", controller.GetNearbyConcerts)
 	router.GET("/concerts/:id", controller.GetConcert)
 	authenticated.POST("/concerts", controller.CreateConcert, middleware.CheckRole("organizer", "admin"))
 	authenticated.PATCH("/concerts/:id", controller.UpdateConcert, middleware.CheckRole("organizer", "admin"))
 	authenticated.DELETE("/concerts/:id", controller.DeleteConcert, middleware.CheckRole("organizer", "admin"))
+	authenticated.POST("/concerts/:id/cancel", controller.CancelConcert, middleware.CheckRole("organizer", "admin"))
+	authenticated.POST("/concerts/:id/status", controller.UpdateConcertStatus, middleware.CheckRole("organizer", "admin"))
+	authenticated.GET("/concerts/:id/cancellation", controller.GetConcertCancellation, middleware.CheckRole("organizer", "admin"))
 	authenticated.GET("/organization/concerts", controller.GetConcertByOrganizationID, middleware.CheckRole("organizer", "admin"))
+	authenticated.GET("/organization/concerts/:id/checkin-bundle", controller.ExportCheckInBundle, middleware.CheckRole("organizer", "admin"))
 	router.GET("/concerts/artist/:id", controller.GetConcertsByArtistID)
+	router.GET("/concerts/:id/seats", controller.GetConcertSeats)
+	authenticated.POST("/concerts/:id/seating", controller.AssignConcertSeating, middleware.CheckRole("organizer", "admin"))
+
+	router.GET("/venues", controller.GetVenues)
+	router.GET("/venues/:id", controller.GetVenue)
+	authenticated.POST("/venues", controller.CreateVenue, middleware.CheckRole("organizer", "admin"))
+	authenticated.PATCH("/venues/:id", controller.UpdateVenue, middleware.CheckRole("organizer", "admin"))
+	router.GET("/venues/:id/seatmaps", controller.GetVenueSeatMaps)
+	authenticated.POST("/venues/:id/seatmaps", controller.CreateSeatMap, middleware.CheckRole("organizer", "admin"))
+	router.GET("/seatmaps/:id", controller.GetSeatMap)
 
 	authenticated.GET("/user/interests", controller.GetUserInterests, middleware.CheckRole("user"))
 	authenticated.POST("/user/interests/:id", controller.AddUserInterest, middleware.CheckRole("user", "organizer", "admin"))
 	authenticated.DELETE("/user/interests/:id", controller.RemoveUserInterest, middleware.CheckRole("user", "organizer", "admin"))
+	authenticated.GET("/user/escrow", controller.GetSellerEscrow, middleware.CheckRole("user"))
 
 	authenticated.POST("/reservation", controller.CreateReservation, middleware.CheckRole("user"))
+	authenticated.POST("/holds", controller.CreateTicketHold, middleware.CheckRole("user"))
+	authenticated.DELETE("/holds/:id", controller.ReleaseTicketHold, middleware.CheckRole("user"))
 	authenticated.POST("/ticket_listing_reservation/:ticketListingId", controller.CreateTicketListingReservation, middleware.CheckRole("user"))
 	authenticated.POST("/ticket_listing_reservation_conversation/:conversationId", controller.CreateTicketListingReservationFromConversation, middleware.CheckRole("user"))
 
+	authenticated.POST("/orders", controller.CreateOrder, middleware.CheckRole("user"))
+	authenticated.GET("/orders/:id", controller.GetOrder, middleware.CheckRole("user"))
+	authenticated.POST("/orders/:id/checkout", controller.CheckoutOrder, middleware.CheckRole("user"))
+	authenticated.DELETE("/orders/:id", controller.CancelOrder, middleware.CheckRole("user"))
+
+	authenticated.POST("/refunds", controller.CreateRefundRequest, middleware.CheckRole("user", "admin"))
+	authenticated.GET("/refunds", controller.GetRefundRequests, middleware.CheckRole("user", "admin"))
+	authenticated.POST("/refunds/:id/approve", controller.ApproveRefundRequest, middleware.CheckRole("admin"))
+	authenticated.POST("/refunds/:id/reject", controller.RejectRefundRequest, middleware.CheckRole("admin"))
+
 	authenticated.POST("/create-payment-intent", controller.CreatePaymentIntent, middleware.CheckRole("user"))
+	router.POST("/webhooks/stripe", controller.HandleStripeWebhook)
 
 	router.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	authenticated.GET("/conversations/:id", controller.GetConversation, middleware.CheckRole("user", "organizer", "admin"))
 	authenticated.POST("/conversations", controller.CreateConversation, middleware.CheckRole("user", "organizer", "admin"))
-	authenticated.PATCH("/conversations/:id", controller.UpdateConversation, middleware.CheckRole("user", "organizer", "admin"))
+	authenticated.GET("/conversations/:id/offers", controller.GetConversationOffers, middleware.CheckRole("user", "organizer", "admin"))
+	authenticated.POST("/conversations/:id/offers", controller.CreateOffer, middleware.CheckRole("user", "organizer", "admin"))
+	authenticated.POST("/offers/:id/accept", controller.AcceptOffer, middleware.CheckRole("user", "organizer", "admin"))
+	authenticated.POST("/offers/:id/reject", controller.RejectOffer, middleware.CheckRole("user", "organizer", "admin"))
+	authenticated.POST("/offers/:id/counter", controller.CounterOffer, middleware.CheckRole("user", "organizer", "admin"))
 
 	authenticated.POST("/messages", controller.PostMessage, middleware.CheckRole("user", "organizer", "admin"))
 
@@ -170,6 +246,10 @@ func main() {
 	router.GET("/ws-community", controller.HandleWebSocketCommunity)
 
 	authenticated.GET("/logs", controller.GetLogs, middleware.CheckRole("admin"))
+
+	authenticated.GET("/ledger/reconciliation", controller.GetLedgerReconciliation, middleware.CheckRole("admin"))
+	authenticated.POST("/ledger/payouts", controller.CreatePayout, middleware.CheckRole("admin"))
+
 	authenticated.GET("/config/:key", controller.GetConfigValue, middleware.CheckRole("admin"))
 	authenticated.PATCH("/config/:key", controller.UpdateConfigValue, middleware.CheckRole("admin"))
 
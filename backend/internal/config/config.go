@@ -2,22 +2,39 @@ package config
 
 import (
 	"bufio"
+	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/joho/godotenv"
 )
 
 var SecretKey []byte
+var TicketSigningKey []byte
+var PaymentProvider string
 var StripeSecretKey string
+var StripeWebhookSecret string
+var StripeApiUrl string
 var ResendApiKey string
 var ContactEmail string
 
-var Config map[string]string
+// ConfigFile est le chemin de weezemaster.config, surchargeable par la variable CONFIG_FILE
+var ConfigFile string
 
+// Valeurs de weezemaster.config chargées au démarrage ; les accès passent par Get et Set
+var (
+	configValues = map[string]string{}
+	configPath   string
+	configMutex  sync.RWMutex
+)
+
+// LoadConfig lit le fichier de configuration une fois pour toutes ; les mises à jour passent
+// ensuite par Set, qui réécrit le même fichier
 func LoadConfig(filePath string) error {
-	Config = make(map[string]string)
+	values := make(map[string]string)
 
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -37,10 +54,56 @@ func LoadConfig(filePath string) error {
 		}
 		key := strings.TrimSpace(parts[0])
 		value := strings.TrimSpace(parts[1])
-		Config[key] = value
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
 
-	return scanner.Err()
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	configValues = values
+	configPath = filePath
+	return nil
+}
+
+// Get renvoie la valeur chargée d'une clé de configuration
+func Get(key string) (string, bool) {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	value, exists := configValues[key]
+	return value, exists
+}
+
+// Set modifie une clé de configuration et réécrit le fichier chargé par LoadConfig. La valeur
+// en mémoire ne change que si l'écriture a réussi.
+func Set(key, value string) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if configPath == "" {
+		return fmt.Errorf("configuration file is not loaded")
+	}
+
+	values := make(map[string]string, len(configValues)+1)
+	for k, v := range configValues {
+		values[k] = v
+	}
+	values[key] = value
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", k, values[k]))
+	}
+	if err := os.WriteFile(configPath, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	configValues = values
+	return nil
 }
 
 func init() {
@@ -49,14 +112,40 @@ func init() {
 		log.Fatalf("Error loading .env file")
 	}
 
+	ConfigFile = os.Getenv("CONFIG_FILE")
+	if ConfigFile == "" {
+		ConfigFile = "../../cmd/weezemaster/config/weezemaster.config"
+	}
+
 	SecretKey = []byte(os.Getenv("SECRET_KEY"))
 	if len(SecretKey) == 0 {
 		log.Fatalf("Secret key is not set or empty")
 	}
+	// Clé dédiée à la signature des QR codes des tickets, distincte de celle des JWT
+	TicketSigningKey = []byte(os.Getenv("TICKET_SIGNING_KEY"))
+	if len(TicketSigningKey) == 0 {
+		log.Fatalf("Ticket signing key is not set or empty")
+	}
+	if string(TicketSigningKey) == string(SecretKey) {
+		log.Fatalf("Ticket signing key must be different from the secret key")
+	}
+	PaymentProvider = os.Getenv("PAYMENT_PROVIDER")
+	if PaymentProvider == "" {
+		PaymentProvider = "stripe"
+	}
 	StripeSecretKey = os.Getenv("STRIPE_SECRET_KEY")
-	if StripeSecretKey == "" {
+	if StripeSecretKey == "" && PaymentProvider == "stripe" {
 		log.Fatalf("Stripe secret key is not set or empty")
 	}
+	StripeWebhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if StripeWebhookSecret == "" {
+		log.Println("Stripe webhook secret is not set, Stripe webhooks will be rejected")
+	}
+	// Permet de pointer vers un faux serveur Stripe en local
+	StripeApiUrl = os.Getenv("STRIPE_API_URL")
+	if StripeApiUrl == "" {
+		StripeApiUrl = "https://api.stripe.com"
+	}
 	ResendApiKey = os.Getenv("RESEND_API_KEY")
 	if ResendApiKey == "" {
 		log.Fatalf("Resend API key is not set or empty")
@@ -41,7 +41,7 @@ func GetArtist(c echo.Context) error {
 	db := database.GetDB()
 	id := c.Param("id")
 	var artist models.Artist
-	if err := db.Preload("Concerts").Where("id = ?", id).First(&artist).Error; err != nil {
+	if err := db.Preload("Concerts", "status <> ?", models.ConcertDraft).Where("id = ?", id).First(&artist).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
 		}
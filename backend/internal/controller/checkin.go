@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errWrongConcert = errors.New("ticket is for another concert")
+var errOwnershipChanged = errors.New("ticket ownership has changed")
+var errAlreadyCheckedIn = errors.New("ticket has already been checked in")
+
+// checkInRejection associe chaque refus de scan au code renvoyé aux équipes à l'entrée
+func checkInRejection(err error) (int, string) {
+	switch {
+	case errors.Is(err, errInvalidTicketToken):
+		return http.StatusBadRequest, "invalid_token"
+	case errors.Is(err, errExpiredTicketToken):
+		return http.StatusBadRequest, "expired_token"
+	case errors.Is(err, errInvalidScanTime):
+		return http.StatusBadRequest, "invalid_scan_time"
+	case errors.Is(err, errWrongConcert):
+		return http.StatusConflict, "wrong_concert"
+	case errors.Is(err, errOwnershipChanged):
+		return http.StatusConflict, "ownership_changed"
+	case errors.Is(err, errAlreadyCheckedIn):
+		return http.StatusConflict, "already_checked_in"
+	case errors.Is(err, errTicketNotValid):
+		return http.StatusConflict, "ticket_invalid"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, "ticket_not_found"
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// checkScannedTicket vérifie que le ticket scanné est valide et toujours détenu par l'utilisateur
+// désigné par le QR code
+func checkScannedTicket(ticket *models.Ticket, payload *ticketTokenPayload) error {
+	if ticket.Status != models.TicketValid {
+		return errTicketNotValid
+	}
+	if ticket.UserId != payload.UserId {
+		return errOwnershipChanged
+	}
+	return nil
+}
+
+// performCheckIn valide l'entrée d'un ticket scanné pour un concert. Le ticket est verrouillé
+// pendant la vérification pour qu'un même QR code scanné à deux portes ne passe qu'une fois.
+// En cas de doublon, le check-in existant est renvoyé avec errAlreadyCheckedIn, sauf si le
+// nouveau scan est plus ancien (scan hors ligne synchronisé en retard) : il remplace alors le
+// check-in existant et replaced vaut true, après les mêmes contrôles du ticket et de son
+// détenteur qu'un premier scan.
+func performCheckIn(db *gorm.DB, payload *ticketTokenPayload, concertID, scannedByID uuid.UUID, scannedAt time.Time, deviceID, source string) (checkIn *models.CheckIn, replaced bool, err error) {
+	checkIn = &models.CheckIn{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", payload.TicketId).First(&ticket).Error; err != nil {
+			return err
+		}
+
+		var concertCategory models.ConcertCategory
+		if err := tx.Where("id = ?", ticket.ConcertCategoryId).First(&concertCategory).Error; err != nil {
+			return err
+		}
+		if concertCategory.ConcertId != concertID || payload.ConcertId != concertID {
+			return errWrongConcert
+		}
+
+		if err := tx.Where("ticket_id = ?", ticket.ID).First(checkIn).Error; err == nil {
+			// Même scan envoyé deux fois par un appareil (synchronisation rejouée)
+			if deviceID != "" && checkIn.DeviceId == deviceID && checkIn.ScannedAt.Equal(scannedAt) {
+				return nil
+			}
+			if !scannedAt.Before(checkIn.ScannedAt) {
+				return errAlreadyCheckedIn
+			}
+			if err := checkScannedTicket(&ticket, payload); err != nil {
+				return err
+			}
+			checkIn.ScannedAt = scannedAt
+			checkIn.ScannedById = scannedByID
+			checkIn.DeviceId = deviceID
+			checkIn.Source = source
+			checkIn.DuplicateScans++
+			checkIn.UpdatedAt = time.Now()
+			replaced = true
+			return tx.Save(checkIn).Error
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := checkScannedTicket(&ticket, payload); err != nil {
+			return err
+		}
+
+		*checkIn = models.CheckIn{
+			ID:          uuid.New(),
+			TicketId:    ticket.ID,
+			ConcertId:   concertID,
+			UserId:      ticket.UserId,
+			ScannedById: scannedByID,
+			ScannedAt:   scannedAt,
+			DeviceId:    deviceID,
+			Source:      source,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		if err := tx.Create(checkIn).Error; err != nil {
+			return err
+		}
+
+		// Le ticket a servi : les vendeurs des reventes successives peuvent être payés
+		if err := releaseTicketEscrows(tx, ticket.ID); err != nil {
+			return err
+		}
+
+		// Un ticket utilisé ne peut plus être revendu
+		return withdrawTicketListings(tx, ticket.ID)
+	})
+	if errors.Is(err, errAlreadyCheckedIn) {
+		db.Model(&models.CheckIn{}).Where("id = ?", checkIn.ID).
+			UpdateColumn("duplicate_scans", gorm.Expr("duplicate_scans + 1"))
+		return checkIn, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return checkIn, replaced, nil
+}
+
+// @Summary		Valide un ticket à l'entrée
+// @Description	Vérifie le QR code scanné par les équipes de l'organisateur et enregistre l'heure d'entrée. Les doublons, les tickets d'un autre concert et les tickets revendus depuis l'affichage du QR code sont refusés.
+// @ID				check-in-ticket
+// @Tags			Tickets
+// @Accept			json
+// @Produce		json
+// @Param			body	body		object	true	"token et concertId"
+// @Success		200		{object}	models.CheckIn
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]interface{}
+// @Failure		500		{object}	map[string]string
+// @Router			/checkin [post]
+// @Security		Bearer
+func CheckInTicket(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var reqBody struct {
+		Token     string    `json:"token"`
+		ConcertId uuid.UUID `json:"concertId"`
+	}
+	if err := c.Bind(&reqBody); err != nil || reqBody.Token == "" || reqBody.ConcertId == uuid.Nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	var concert models.Concert
+	if err := db.Where("id = ?", reqBody.ConcertId).First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if user.Role != "admin" && concert.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Concert does not belong to your organization"})
+	}
+
+	payload, err := verifyTicketToken(reqBody.Token, time.Now())
+	if err == nil {
+		var checkIn *models.CheckIn
+		checkIn, _, err = performCheckIn(db, payload, concert.ID, user.ID, time.Now(), "", models.CheckInOnline)
+		if err == nil {
+			c.Logger().Infof("event=TicketCheckedIn ticket_id=%s concert_id=%s scanner_id=%s timestamp=%s", checkIn.TicketId, concert.ID, user.ID, time.Now().Format(time.RFC3339))
+			return c.JSON(http.StatusOK, checkIn)
+		}
+		if errors.Is(err, errAlreadyCheckedIn) {
+			c.Logger().Infof("event=TicketCheckInRejected ticket_id=%s reason=already_checked_in timestamp=%s", checkIn.TicketId, time.Now().Format(time.RFC3339))
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":       err.Error(),
+				"reason":      "already_checked_in",
+				"checkedInAt": checkIn.ScannedAt,
+			})
+		}
+	}
+
+	status, reason := checkInRejection(err)
+	c.Logger().Infof("event=TicketCheckInRejected concert_id=%s reason=%s timestamp=%s", concert.ID, reason, time.Now().Format(time.RFC3339))
+	return c.JSON(status, map[string]string{"error": err.Error(), "reason": reason})
+}
@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"weezemaster/internal/config"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Version du format des bundles hors ligne, à incrémenter si leur structure change. Le format 2
+// accompagne les tokens wm2, vérifiables avec la clé publique du bundle.
+const checkInBundleFormat = 2
+
+// Nombre maximum de scans par synchronisation
+const maxCheckInSyncBatch = 500
+
+// Avance tolérée sur l'horloge d'un appareil pour l'heure d'un scan hors ligne
+const checkInClockSkew = 5 * time.Minute
+
+// Durée de validité d'un bundle après le début du concert
+const checkInBundleValidity = 24 * time.Hour
+
+var errInvalidScanTime = errors.New("scan time is outside the check-in window")
+var errUnknownBundle = errors.New("unknown check-in bundle version")
+
+type CheckInBundleTicket struct {
+	TicketId          uuid.UUID  `json:"ticketId"`
+	UserId            uuid.UUID  `json:"userId"`
+	ConcertCategoryId uuid.UUID  `json:"concertCategoryId"`
+	Category          string     `json:"category"`
+	CheckedInAt       *time.Time `json:"checkedInAt,omitempty"`
+}
+
+// CheckInBundle contient les tickets valides d'un concert pour un scan hors ligne. Un appareil
+// accepte un QR code "wm2.<payload>.<signature>" si la signature Ed25519 de "wm2.<payload>" se
+// vérifie avec la clé publique de /checkin/bundle-key (TokenKeyId), si le payload désigne ce
+// concert, un ticket et son détenteur présents dans le bundle, et si sa fenêtre de rotation
+// (w) est celle de l'heure du scan ou la précédente.
+type CheckInBundle struct {
+	Format               int                   `json:"format"`
+	Version              int                   `json:"version"`
+	ConcertId            uuid.UUID             `json:"concertId"`
+	GeneratedAt          time.Time             `json:"generatedAt"`
+	ExpiresAt            time.Time             `json:"expiresAt"`
+	TokenRotationSeconds int                   `json:"tokenRotationSeconds"`
+	TokenKeyId           string                `json:"tokenKeyId"`
+	Tickets              []CheckInBundleTicket `json:"tickets"`
+}
+
+// SignedCheckInBundle est le bundle tel que téléchargé : la signature Ed25519 porte sur les
+// octets exacts du champ bundle et se vérifie avec la clé publique de /checkin/bundle-key
+type SignedCheckInBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"`
+	KeyId     string          `json:"keyId"`
+}
+
+type CheckInScan struct {
+	Token     string    `json:"token"`
+	ScannedAt time.Time `json:"scannedAt"`
+}
+
+type CheckInSyncRequest struct {
+	ConcertId     uuid.UUID     `json:"concertId"`
+	DeviceId      string        `json:"deviceId"`
+	BundleVersion int           `json:"bundleVersion"`
+	Scans         []CheckInScan `json:"scans"`
+}
+
+type CheckInSyncResult struct {
+	TicketId    *uuid.UUID `json:"ticketId,omitempty"`
+	ScannedAt   time.Time  `json:"scannedAt"`
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	CheckedInAt *time.Time `json:"checkedInAt,omitempty"`
+	DeviceId    string     `json:"deviceId,omitempty"`
+}
+
+// checkInSigningKey dérive de la clé de signature des tickets la clé Ed25519 qui signe les bundles
+// et les tokens de QR code. Seule la clé publique quitte le serveur.
+func checkInSigningKey() ed25519.PrivateKey {
+	seed := sha256.Sum256(append([]byte("checkin-bundle:"), config.TicketSigningKey...))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func bundleKeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// buildCheckInBundle liste les tickets valides du concert et enregistre une nouvelle version de bundle
+func buildCheckInBundle(db *gorm.DB, concert *models.Concert, generatedByID uuid.UUID) (*CheckInBundle, error) {
+	var bundle *CheckInBundle
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Verrouille le concert pour numéroter les versions sans trou ni doublon
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", concert.ID).First(concert).Error; err != nil {
+			return err
+		}
+
+		var tickets []models.Ticket
+		if err := tx.Preload("ConcertCategory").Preload("ConcertCategory.Category").Preload("CheckIn").
+			Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+			Where("concert_categories.concert_id = ? AND tickets.status = ?", concert.ID, models.TicketValid).
+			Order("tickets.id").
+			Find(&tickets).Error; err != nil {
+			return err
+		}
+
+		var lastVersion int
+		if err := tx.Model(&models.CheckInBundle{}).Select("COALESCE(MAX(version), 0)").
+			Where("concert_id = ?", concert.ID).Scan(&lastVersion).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		bundle = &CheckInBundle{
+			Format:               checkInBundleFormat,
+			Version:              lastVersion + 1,
+			ConcertId:            concert.ID,
+			GeneratedAt:          now,
+			ExpiresAt:            concert.Date.Add(checkInBundleValidity).UTC(),
+			TokenRotationSeconds: int(getTicketTokenRotation().Seconds()),
+			TokenKeyId:           bundleKeyID(checkInSigningKey().Public().(ed25519.PublicKey)),
+			Tickets:              make([]CheckInBundleTicket, 0, len(tickets)),
+		}
+		for _, ticket := range tickets {
+			entry := CheckInBundleTicket{
+				TicketId:          ticket.ID,
+				UserId:            ticket.UserId,
+				ConcertCategoryId: ticket.ConcertCategoryId,
+				Category:          ticket.ConcertCategory.Category.Name,
+			}
+			if ticket.CheckIn != nil {
+				entry.CheckedInAt = &ticket.CheckIn.ScannedAt
+			}
+			bundle.Tickets = append(bundle.Tickets, entry)
+		}
+
+		return tx.Create(&models.CheckInBundle{
+			ID:            uuid.New(),
+			ConcertId:     concert.ID,
+			Version:       bundle.Version,
+			GeneratedById: generatedByID,
+			TicketCount:   len(bundle.Tickets),
+			ExpiresAt:     bundle.ExpiresAt,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// signCheckInBundle sérialise le bundle et le signe avec la clé Ed25519 des bundles
+func signCheckInBundle(bundle *CheckInBundle) (*SignedCheckInBundle, error) {
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := checkInSigningKey()
+	return &SignedCheckInBundle{
+		Bundle:    raw,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, raw)),
+		KeyId:     bundleKeyID(privateKey.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+// checkInScanWindow renvoie la période où un scan hors ligne fait avec un bundle est recevable :
+// pas avant la génération du bundle ni avant le jour du concert, pas après l'expiration du bundle
+// ni dans le futur, avec la tolérance checkInClockSkew sur l'horloge de l'appareil
+func checkInScanWindow(concert *models.Concert, bundleGeneratedAt time.Time) (time.Time, time.Time) {
+	date := concert.Date
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	if bundleGeneratedAt.After(from) {
+		from = bundleGeneratedAt
+	}
+	to := date.Add(checkInBundleValidity)
+	if now := time.Now(); now.Before(to) {
+		to = now
+	}
+	return from.Add(-checkInClockSkew), to.Add(checkInClockSkew)
+}
+
+// syncCheckInScan rejoue un scan hors ligne à l'heure où il a été fait, si cette heure est dans la
+// fenêtre du bundle utilisé (voir checkInScanWindow)
+func syncCheckInScan(db *gorm.DB, scan CheckInScan, concert *models.Concert, bundleGeneratedAt time.Time, scannedByID uuid.UUID, deviceID string) CheckInSyncResult {
+	result := CheckInSyncResult{ScannedAt: scan.ScannedAt}
+
+	from, to := checkInScanWindow(concert, bundleGeneratedAt)
+	if scan.ScannedAt.Before(from) || scan.ScannedAt.After(to) {
+		result.Status = "rejected"
+		_, result.Reason = checkInRejection(errInvalidScanTime)
+		return result
+	}
+
+	payload, err := verifyTicketToken(scan.Token, scan.ScannedAt)
+	if err != nil {
+		result.Status = "rejected"
+		_, result.Reason = checkInRejection(err)
+		return result
+	}
+	result.TicketId = &payload.TicketId
+
+	checkIn, replaced, err := performCheckIn(db, payload, concert.ID, scannedByID, scan.ScannedAt, deviceID, models.CheckInOffline)
+	switch {
+	case err == nil:
+		result.Status = "accepted"
+		if replaced {
+			// Le ticket avait déjà été validé plus tard sur un autre appareil : ce scan, plus ancien, est retenu
+			result.Reason = "earlier_than_existing_scan"
+		}
+	case errors.Is(err, errAlreadyCheckedIn):
+		result.Status = "duplicate"
+		result.Reason = "already_checked_in"
+	default:
+		result.Status = "rejected"
+		_, result.Reason = checkInRejection(err)
+		return result
+	}
+	result.CheckedInAt = &checkIn.ScannedAt
+	result.DeviceId = checkIn.DeviceId
+	return result
+}
+
+// @Summary		Télécharge le bundle de scan hors ligne
+// @Description	Renvoie la liste signée et versionnée des tickets valides d'un concert de l'organisation, pour valider les entrées sans connexion
+// @ID				export-checkin-bundle
+// @Tags			Concerts
+// @Produce		json
+// @Param			id	path		string	true	"ID du concert"	format(uuid)
+// @Success		200	{object}	SignedCheckInBundle
+// @Failure		401	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/organization/concerts/{id}/checkin-bundle [get]
+// @Security		Bearer
+func ExportCheckInBundle(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	// Même périmètre que GetConcertByOrganizationID : seuls les concerts de l'organisation
+	var concert models.Concert
+	query := db.Where("id = ?", c.Param("id"))
+	if user.Role != "admin" {
+		query = query.Where("organization_id = ?", user.OrganizationId)
+	}
+	if err := query.First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if concert.Status == models.ConcertCancelled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Concert has been cancelled"})
+	}
+
+	bundle, err := buildCheckInBundle(db, &concert, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to build check-in bundle"})
+	}
+
+	signed, err := signCheckInBundle(bundle)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to sign check-in bundle"})
+	}
+
+	c.Logger().Infof("event=CheckInBundleExported concert_id=%s version=%d tickets=%d user_id=%s timestamp=%s", concert.ID, bundle.Version, len(bundle.Tickets), user.ID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, signed)
+}
+
+// @Summary		Clé publique des bundles hors ligne
+// @Description	Renvoie la clé publique Ed25519 qui vérifie la signature des bundles de scan et des QR codes des tickets
+// @ID				get-checkin-bundle-key
+// @Tags			Tickets
+// @Produce		json
+// @Success		200	{object}	map[string]string
+// @Router			/checkin/bundle-key [get]
+// @Security		Bearer
+func GetCheckInBundleKey(c echo.Context) error {
+	publicKey := checkInSigningKey().Public().(ed25519.PublicKey)
+	return c.JSON(http.StatusOK, map[string]string{
+		"algorithm": "ed25519",
+		"keyId":     bundleKeyID(publicKey),
+		"publicKey": base64.StdEncoding.EncodeToString(publicKey),
+	})
+}
+
+// @Summary		Synchronise les scans hors ligne
+// @Description	Rejoue un lot de scans faits hors ligne avec la version de bundle indiquée. Un scan antérieur à la génération de ce bundle, hors du jour du concert ou dans le futur est refusé. Si un ticket a été scanné sur plusieurs appareils, le scan le plus ancien est retenu et les autres sont signalés comme doublons.
+// @ID				sync-checkins
+// @Tags			Tickets
+// @Accept			json
+// @Produce		json
+// @Param			body	body		CheckInSyncRequest	true	"Scans de l'appareil"
+// @Success		200		{object}	map[string]interface{}
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/checkin/sync [post]
+// @Security		Bearer
+func SyncCheckIns(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var reqBody CheckInSyncRequest
+	if err := c.Bind(&reqBody); err != nil || reqBody.ConcertId == uuid.Nil || reqBody.DeviceId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if len(reqBody.Scans) > maxCheckInSyncBatch {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Too many scans in one batch"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	var concert models.Concert
+	if err := db.Where("id = ?", reqBody.ConcertId).First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if user.Role != "admin" && concert.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Concert does not belong to your organization"})
+	}
+
+	var bundle models.CheckInBundle
+	if err := db.Where("concert_id = ? AND version = ?", concert.ID, reqBody.BundleVersion).First(&bundle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": errUnknownBundle.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	results := make([]CheckInSyncResult, 0, len(reqBody.Scans))
+	counts := map[string]int{"accepted": 0, "duplicate": 0, "rejected": 0}
+	for _, scan := range reqBody.Scans {
+		result := syncCheckInScan(db, scan, &concert, bundle.CreatedAt, user.ID, reqBody.DeviceId)
+		counts[result.Status]++
+		results = append(results, result)
+	}
+
+	var latestVersion int
+	if err := db.Model(&models.CheckInBundle{}).Select("COALESCE(MAX(version), 0)").
+		Where("concert_id = ?", concert.ID).Scan(&latestVersion).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	c.Logger().Infof("event=CheckInsSynced concert_id=%s device_id=%s accepted=%d duplicates=%d rejected=%d timestamp=%s", concert.ID, reqBody.DeviceId, counts["accepted"], counts["duplicate"], counts["rejected"], time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"bundleVersion":       reqBody.BundleVersion,
+		"latestBundleVersion": latestVersion,
+		"accepted":            counts["accepted"],
+		"duplicates":          counts["duplicate"],
+		"rejected":            counts["rejected"],
+		"results":             results,
+	})
+}
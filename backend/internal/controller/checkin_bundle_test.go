@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"testing"
+	"time"
+	"weezemaster/internal/models"
+)
+
+func TestCheckInScanWindow(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name        string
+		concertDate time.Time
+		generatedAt time.Time
+		scannedAt   time.Time
+		want        bool
+	}{
+		{"during the concert", now.Add(-time.Hour), now.Add(-3 * time.Hour), now.Add(-30 * time.Minute), true},
+		{"before the bundle was generated", now.Add(-time.Hour), now.Add(-2 * time.Hour), now.Add(-3 * time.Hour), false},
+		{"within the clock skew before generation", now.Add(-time.Hour), now.Add(-2 * time.Hour), now.Add(-2*time.Hour - time.Minute), true},
+		{"the day before the concert", now.Add(-time.Hour), now.AddDate(0, 0, -3), now.Add(-time.Hour).AddDate(0, 0, -1), false},
+		{"in the future", now.Add(-time.Hour), now.Add(-2 * time.Hour), now.Add(time.Hour), false},
+		{"after the bundle expired", now.Add(-48 * time.Hour), now.Add(-49 * time.Hour), now.Add(-time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := checkInScanWindow(&models.Concert{Date: tt.concertDate}, tt.generatedAt)
+			got := !tt.scannedAt.Before(from) && !tt.scannedAt.After(to)
+			if got != tt.want {
+				t.Errorf("scan at %s in window [%s, %s] = %v, want %v", tt.scannedAt, from, to, got, tt.want)
+			}
+		})
+	}
+}
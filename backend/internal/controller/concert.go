@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	"weezemaster/internal/database"
@@ -18,23 +19,8 @@ import (
 	"gorm.io/gorm"
 )
 
-// @Summary		Récupère tous les concerts
-// @Description	Récupère tous les concerts
-// @ID				get-all-concerts
-// @Tags			Concerts
-// @Produce		json
-// @Success		200	{array}		models.Concert
-// @Failure		500	{object}	string
-// @Router			/concerts [get]
-func GetAllConcerts(c echo.Context) error {
-	db := database.GetDB()
-	var concerts []models.Concert
-	db.Preload("Interests").Preload("Artist").Find(&concerts)
-	return c.JSON(http.StatusOK, concerts)
-}
-
 // @Summary		Récupère un concert
-// @Description	Récupère un concert par ID
+// @Description	Récupère un concert par ID. Un brouillon n'est visible que de son organisation et des administrateurs.
 // @ID				get-concert
 // @Tags			Concerts
 // @Produce		json
@@ -45,29 +31,14 @@ func GetAllConcerts(c echo.Context) error {
 // @Router			/concerts/{id} [get]
 func GetConcert(c echo.Context) error {
 	db := database.GetDB()
-	authHeader := c.Request().Header.Get("Authorization")
-	var userID uuid.UUID
-
-	if authHeader != "" {
-		tokenString := authHeader
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			tokenString = authHeader[7:]
-		}
-
-		claims, err := verifyToken(tokenString)
-		if err == nil {
-			userIdFromToken, ok := claims["id"].(string)
-			if ok {
-				userID, _ = uuid.Parse(userIdFromToken)
-			}
-		}
-	}
+	userID := optionalUserID(c)
 
 	var concert models.Concert
 	if err := db.
 		Preload("Interests").
 		Preload("Organization").
 		Preload("Artist").
+		Preload("Venue").
 		Preload("ConcertCategories").
 		Preload("ConcertCategories.Category").
 		Preload("ConcertCategories.Tickets", "EXISTS (SELECT 1 FROM ticket_listings WHERE tickets.id = ticket_listings.ticket_id)").
@@ -76,15 +47,41 @@ func GetConcert(c echo.Context) error {
 			func(db *gorm.DB) *gorm.DB {
 				if userID != uuid.Nil {
 					return db.Joins("JOIN tickets ON tickets.id = ticket_listings.ticket_id").
-						Where("ticket_listings.status = ? AND tickets.user_id != ?", "available", userID)
+						Where("ticket_listings.status = ? AND tickets.user_id != ?", models.ListingAvailable, userID)
 				}
-				return db.Where("ticket_listings.status = ?", "available")
+				return db.Where("ticket_listings.status = ?", models.ListingAvailable)
 			}).Where("id = ?", c.Param("id")).First(&concert).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(http.StatusNotFound, map[string]string{"message": "Concert not found"})
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
 	}
+	// Un brouillon n'est visible que de son organisation
+	if concert.Status == models.ConcertDraft && !canManageConcert(c, db, &concert) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Concert not found"})
+	}
+
+	// Chaque annonce porte la réputation de son vendeur, le détenteur du ticket
+	var sellerIDs []uuid.UUID
+	for _, concertCategory := range concert.ConcertCategories {
+		for _, ticket := range concertCategory.Tickets {
+			sellerIDs = append(sellerIDs, ticket.UserId)
+		}
+	}
+	reputations, err := reputationsFor(db, sellerIDs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
+	for _, concertCategory := range concert.ConcertCategories {
+		for _, ticket := range concertCategory.Tickets {
+			if ticket.TicketListings == nil {
+				continue
+			}
+			for k := range *ticket.TicketListings {
+				(*ticket.TicketListings)[k].SellerReputation = reputations[ticket.UserId]
+			}
+		}
+	}
 
 	return c.JSON(http.StatusOK, concert)
 }
@@ -97,16 +94,39 @@ type RequestPayloadConcert struct {
 	Date          string `json:"date"`
 	InterestIDs   []int  `json:"InterestIDs"`
 	CategoriesIDs []struct {
-		ID     int     `json:"id"`
-		Places int     `json:"places"`
-		Price  float64 `json:"price"`
+		ID     int          `json:"id"`
+		Places int          `json:"places"`
+		Price  models.Money `json:"price"`
 	} `json:"CategoriesIDs"`
 }
 
 type Category struct {
-	ID     int     `json:"id"`
-	Places int     `json:"places"`
-	Price  float64 `json:"price"`
+	ID     int          `json:"id"`
+	Places int          `json:"places"`
+	Price  models.Money `json:"price"`
+	// Période de vente propre à la catégorie, à l'heure locale de la salle
+	SalesOpenAt  string `json:"salesOpenAt"`
+	SalesCloseAt string `json:"salesCloseAt"`
+}
+
+// parseRefundPolicy lit la politique de remboursement du form-data, en gardant les valeurs
+// actuelles pour les champs absents
+func parseRefundPolicy(c echo.Context, refundsEnabled bool, refundCutoffHours int) (bool, int, error) {
+	if v := c.FormValue("refundsEnabled"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, 0, fmt.Errorf("invalid refundsEnabled value")
+		}
+		refundsEnabled = enabled
+	}
+	if v := c.FormValue("refundCutoffHours"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours < 0 {
+			return false, 0, fmt.Errorf("invalid refundCutoffHours value")
+		}
+		refundCutoffHours = hours
+	}
+	return refundsEnabled, refundCutoffHours, nil
 }
 
 // @Summary		Créé un concert
@@ -115,6 +135,13 @@ type Category struct {
 // @Tags			Concerts
 // @Produce		json
 // @Param			name	formData	string	true	"Nom du concert"
+// @Param			venueId	formData	string	false	"ID d'une salle de l'organisation ; à défaut, location crée ou reprend une salle de ce nom"
+// @Param			date	formData	string	true	"Date au format 2006-01-02 15:04, à l'heure locale de la salle"
+// @Param			refundsEnabled		formData	bool	false	"Remboursements autorisés (true par défaut)"
+// @Param			refundCutoffHours	formData	int		false	"Délai en heures avant le concert au-delà duquel les remboursements sont refusés (48 par défaut)"
+// @Param			status			formData	string	false	"draft, published ou on_sale (par défaut) ; un concert mis en vente avec une ouverture future reste publié jusqu'à salesOpenAt"
+// @Param			salesOpenAt		formData	string	false	"Ouverture de la vente au format 2006-01-02 15:04, à l'heure locale de la salle"
+// @Param			salesCloseAt	formData	string	false	"Fermeture de la vente, au plus tard le début du concert"
 // @Success		201		{object}	models.Concert
 // @Failure		400		{object}	string
 // @Failure		401		{object}	string
@@ -129,7 +156,6 @@ func CreateConcert(c echo.Context) error {
 	// Extraire les champs du form-data
 	name := c.FormValue("name")
 	description := c.FormValue("description")
-	location := c.FormValue("location")
 	dateStr := c.FormValue("date")
 	interestIDs := c.FormValue("InterestIDs")
 	categoriesIDs := c.FormValue("CategoriesIDs")
@@ -137,7 +163,20 @@ func CreateConcert(c echo.Context) error {
 	fmt.Println(artistId)
 	fmt.Println(uuid.MustParse(artistId))
 
-	date, _ := time.Parse("2006-01-02 15:04", dateStr)
+	refundsEnabled, refundCutoffHours, err := parseRefundPolicy(c, true, 48)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Sans statut, le concert est mis en vente comme avant le cycle de vie : les clients qui ne
+	// connaissent pas les brouillons créent des concerts visibles et achetables
+	status := c.FormValue("status")
+	if status == "" {
+		status = models.ConcertOnSale
+	}
+	if status != models.ConcertDraft && status != models.ConcertPublished && status != models.ConcertOnSale {
+		return echo.NewHTTPError(http.StatusBadRequest, "status must be draft, published or on_sale")
+	}
 
 	token, ok := c.Get("user").(*jwt.Token)
 	if !ok {
@@ -159,6 +198,36 @@ func CreateConcert(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "User not found")
 	}
 
+	// La date est saisie à l'heure locale de la salle
+	venue, err := resolveConcertVenue(c, db, user.OrganizationId, nil)
+	if err != nil {
+		return concertVenueError(err)
+	}
+	if venue == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "venueId or location is required")
+	}
+	date, _ := parseConcertDate(dateStr, venue)
+
+	salesOpenAt, err := parseSalesDate(c.FormValue("salesOpenAt"), venue, nil)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	salesCloseAt, err := parseSalesDate(c.FormValue("salesCloseAt"), venue, nil)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := validateSalesWindow(salesOpenAt, salesCloseAt, date); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	// Une vente programmée plus tard ouvre via le sweeper du cycle de vie
+	if status == models.ConcertOnSale {
+		if now := time.Now(); salesOpenAt == nil {
+			salesOpenAt = &now
+		} else if salesOpenAt.After(now) {
+			status = models.ConcertPublished
+		}
+	}
+
 	// Récupérer le fichier image depuis le form-data
 	file, err := c.FormFile("image")
 	if err != nil {
@@ -221,15 +290,21 @@ func CreateConcert(c echo.Context) error {
 
 	// Créer un nouvel objet Concert
 	concert := models.Concert{
-		ID:             uuid.New(),
-		Name:           name,
-		Description:    description,
-		Location:       location,
-		Date:           date,
-		Image:          fileName,
-		OrganizationId: user.OrganizationId,
-		ArtistId:       uuid.MustParse(artistId),
-		Artist:         &artist,
+		ID:                uuid.New(),
+		Name:              name,
+		Description:       description,
+		Location:          venueLabel(venue),
+		Date:              date,
+		Image:             fileName,
+		OrganizationId:    user.OrganizationId,
+		ArtistId:          uuid.MustParse(artistId),
+		Artist:            &artist,
+		RefundsEnabled:    refundsEnabled,
+		RefundCutoffHours: refundCutoffHours,
+		VenueId:           &venue.ID,
+		Status:            status,
+		SalesOpenAt:       salesOpenAt,
+		SalesCloseAt:      salesCloseAt,
 	}
 
 	// Récupérer les objets Interest correspondant aux IDs
@@ -249,12 +324,28 @@ func CreateConcert(c echo.Context) error {
 	// Gérer les catégories
 	var concertCategories []models.ConcertCategory
 	for _, cat := range categories {
+		if !cat.Price.IsPositive() {
+			return echo.NewHTTPError(http.StatusBadRequest, "Category price must be positive")
+		}
+		categoryOpenAt, err := parseSalesDate(cat.SalesOpenAt, venue, nil)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		categoryCloseAt, err := parseSalesDate(cat.SalesCloseAt, venue, nil)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := validateSalesWindow(categoryOpenAt, categoryCloseAt, date); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		category := models.ConcertCategory{
 			ID:               uuid.New(),
 			ConcertId:        concert.ID,
 			CategoryId:       cat.ID,
 			Price:            cat.Price,
 			AvailableTickets: cat.Places,
+			SalesOpenAt:      categoryOpenAt,
+			SalesCloseAt:     categoryCloseAt,
 		}
 		concertCategories = append(concertCategories, category)
 	}
@@ -263,6 +354,14 @@ func CreateConcert(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create concert: "+err.Error())
 	}
 
+	// GORM remplace les valeurs nulles par les valeurs par défaut des colonnes à la création
+	if err := db.Model(&concert).Updates(map[string]interface{}{
+		"refunds_enabled":     refundsEnabled,
+		"refund_cutoff_hours": refundCutoffHours,
+	}).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save refund policy: "+err.Error())
+	}
+
 	// Enregistrer les catégories associées au concert
 	if err := db.Create(&concertCategories).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create concert categories: "+err.Error())
@@ -303,6 +402,10 @@ func CreateConcert(c echo.Context) error {
 // @Produce		json
 // @Param			id		path		string	true	"ID du concert"	format(uuid)
 // @Param			name	formData	string	false	"Nom du concert"
+// @Param			refundsEnabled		formData	bool	false	"Remboursements autorisés"
+// @Param			refundCutoffHours	formData	int		false	"Délai en heures avant le concert au-delà duquel les remboursements sont refusés"
+// @Param			salesOpenAt		formData	string	false	"Ouverture de la vente au format 2006-01-02 15:04, à l'heure locale de la salle"
+// @Param			salesCloseAt	formData	string	false	"Fermeture de la vente, au plus tard le début du concert"
 // @Success		200		{object}	models.Concert
 // @Failure		400		{object}	string
 // @Failure		404		{object}	string
@@ -319,15 +422,38 @@ func UpdateConcert(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	// Le statut ne change que par les transitions de POST /concerts/{id}/status
+	status := concert.Status
 	if err := c.Bind(&concert); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	concert.Status = status
 
 	name := c.FormValue("name")
-	location := c.FormValue("location")
 	dateStr := c.FormValue("date")
 
-	date, _ := time.Parse("2006-01-02 15:04", dateStr)
+	venue, err := resolveConcertVenue(c, db, concert.OrganizationId, concert.VenueId)
+	if err != nil {
+		return concertVenueError(err)
+	}
+	date, _ := parseConcertDate(dateStr, venue)
+
+	refundsEnabled, refundCutoffHours, err := parseRefundPolicy(c, concert.RefundsEnabled, concert.RefundCutoffHours)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	salesOpenAt, err := parseSalesDate(c.FormValue("salesOpenAt"), venue, concert.SalesOpenAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	salesCloseAt, err := parseSalesDate(c.FormValue("salesCloseAt"), venue, concert.SalesCloseAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := validateSalesWindow(salesOpenAt, salesCloseAt, date); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
 	// Vérifier si une nouvelle image est fournie
 	file, err := c.FormFile("image")
@@ -392,8 +518,15 @@ func UpdateConcert(c echo.Context) error {
 	}
 
 	concert.Name = name
-	concert.Location = location
+	if venue != nil {
+		concert.VenueId = &venue.ID
+		concert.Location = venueLabel(venue)
+	}
 	concert.Date = date
+	concert.RefundsEnabled = refundsEnabled
+	concert.RefundCutoffHours = refundCutoffHours
+	concert.SalesOpenAt = salesOpenAt
+	concert.SalesCloseAt = salesCloseAt
 
 	if err := db.Save(&concert).Error; err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
@@ -482,7 +615,7 @@ func GetConcertsByArtistID(c echo.Context) error {
 	id := c.Param("id")
 
 	var concerts []models.Concert
-	if result := db.Where("artist_id = ?", id).Find(&concerts); result.Error != nil {
+	if result := db.Where("artist_id = ? AND status <> ?", id, models.ConcertDraft).Find(&concerts); result.Error != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error retrieving concerts"})
 	}
 
@@ -0,0 +1,433 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errConcertAlreadyCancelled = errors.New("concert is already cancelled")
+var errCancellationRunning = errors.New("concert cancellation is already running")
+
+// Nombre de tickets remboursés entre deux mises à jour de la progression du job
+const cancellationBatchSize = 50
+
+// Jobs d'annulation en cours d'exécution dans ce processus
+var runningCancellations sync.Map
+
+// startConcertCancellation annule un concert et crée le job de remboursement de ses tickets.
+// Les annonces sont retirées, les conversations de revente supprimées et les holds libérés dans
+// la même transaction. Un job terminé en échec est relancé au lieu d'être recréé.
+func startConcertCancellation(db *gorm.DB, concertID, requestedByID uuid.UUID, reason string) (*models.ConcertCancellation, error) {
+	var job models.ConcertCancellation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var concert models.Concert
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", concertID).First(&concert).Error; err != nil {
+			return err
+		}
+
+		if concert.Status == models.ConcertCancelled {
+			if err := tx.Where("concert_id = ?", concert.ID).First(&job).Error; err != nil {
+				return err
+			}
+			switch job.Status {
+			case models.ConcertCancellationRunning:
+				return errCancellationRunning
+			case models.ConcertCancellationCompleted:
+				return errConcertAlreadyCancelled
+			}
+			job.Status = models.ConcertCancellationRunning
+			job.FailedTickets = 0
+			job.LastError = ""
+			job.CompletedAt = nil
+			job.UpdatedAt = time.Now()
+			return tx.Save(&job).Error
+		}
+
+		if !canTransitionConcert(concert.Status, models.ConcertCancelled) {
+			return errInvalidConcertTransition
+		}
+
+		now := time.Now()
+		concert.Status = models.ConcertCancelled
+		concert.CancelledAt = &now
+		concert.UpdatedAt = now
+		if err := tx.Save(&concert).Error; err != nil {
+			return err
+		}
+
+		ticketIDs := tx.Model(&models.Ticket{}).Select("tickets.id").
+			Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+			Where("concert_categories.concert_id = ?", concert.ID)
+		listingIDs := tx.Model(&models.TicketListing{}).Select("id").Where("ticket_id IN (?)", ticketIDs)
+		conversationIDs := tx.Model(&models.Conversation{}).Select("id").Where("ticket_listing_id IN (?)", listingIDs)
+
+		if err := tx.Where("conversation_id IN (?)", conversationIDs).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("conversation_id IN (?)", conversationIDs).Delete(&models.Offer{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ticket_listing_id IN (?)", listingIDs).Delete(&models.Conversation{}).Error; err != nil {
+			return err
+		}
+		if err := withdrawListings(tx.Where("ticket_id IN (?)", ticketIDs)); err != nil {
+			return err
+		}
+		if err := cancelTicketTransfers(tx.Where("ticket_id IN (?)", ticketIDs)); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TicketHold{}).
+			Where("concert_id = ? AND status = ?", concert.ID, models.TicketHoldActive).
+			Updates(map[string]interface{}{"status": models.TicketHoldReleased, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		// Les acheteurs en revente sont remboursés : les vendeurs ne toucheront rien
+		if err := reverseConcertEscrows(tx, concert.ID); err != nil {
+			return err
+		}
+
+		var totalTickets int64
+		if err := tx.Model(&models.Ticket{}).
+			Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+			Where("concert_categories.concert_id = ? AND tickets.status = ?", concert.ID, models.TicketValid).
+			Count(&totalTickets).Error; err != nil {
+			return err
+		}
+
+		job = models.ConcertCancellation{
+			ID:            uuid.New(),
+			ConcertId:     concert.ID,
+			RequestedById: requestedByID,
+			Reason:        reason,
+			Status:        models.ConcertCancellationRunning,
+			TotalTickets:  int(totalTickets),
+			StartedAt:     now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		return tx.Create(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// runConcertCancellation rembourse tous les tickets valides d'un concert annulé. Le job reprend
+// là où il s'était arrêté : les demandes de remboursement déjà ouvertes sont réutilisées et
+// un ticket revendu est remboursé à chacun de ses acheteurs successifs.
+func runConcertCancellation(jobID uuid.UUID) {
+	if _, running := runningCancellations.LoadOrStore(jobID, true); running {
+		return
+	}
+	defer runningCancellations.Delete(jobID)
+
+	db := database.GetDB()
+	ctx := context.Background()
+
+	var job models.ConcertCancellation
+	if err := db.Preload("Concert").Where("id = ?", jobID).First(&job).Error; err != nil {
+		fmt.Println("Erreur lors de la récupération du job d'annulation :", err)
+		return
+	}
+
+	failed := make(map[uuid.UUID]bool)
+	for {
+		query := db.Model(&models.Ticket{}).
+			Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+			Where("concert_categories.concert_id = ? AND tickets.status = ?", job.ConcertId, models.TicketValid)
+		if len(failed) > 0 {
+			ids := make([]uuid.UUID, 0, len(failed))
+			for id := range failed {
+				ids = append(ids, id)
+			}
+			query = query.Where("tickets.id NOT IN ?", ids)
+		}
+
+		var tickets []models.Ticket
+		if err := query.Limit(cancellationBatchSize).Find(&tickets).Error; err != nil {
+			job.LastError = err.Error()
+			break
+		}
+		if len(tickets) == 0 {
+			break
+		}
+
+		for _, ticket := range tickets {
+			request, err := refundCancelledTicket(ctx, db, &job, &ticket)
+			if err != nil {
+				failed[ticket.ID] = true
+				job.FailedTickets++
+				job.LastError = fmt.Sprintf("ticket %s: %v", ticket.ID, err)
+				continue
+			}
+			job.RefundedTickets++
+			notifyConcertCancelled(db, &job.Concert, ticket.UserId, request)
+		}
+
+		if err := db.Model(&job).Updates(map[string]interface{}{
+			"refunded_tickets": job.RefundedTickets,
+			"failed_tickets":   job.FailedTickets,
+			"last_error":       job.LastError,
+			"updated_at":       time.Now(),
+		}).Error; err != nil {
+			fmt.Println("Erreur lors de la mise à jour du job d'annulation :", err)
+		}
+	}
+
+	now := time.Now()
+	job.Status = models.ConcertCancellationCompleted
+	if job.FailedTickets > 0 || job.LastError != "" {
+		job.Status = models.ConcertCancellationFailed
+	}
+	if err := db.Model(&job).Updates(map[string]interface{}{
+		"status":           job.Status,
+		"refunded_tickets": job.RefundedTickets,
+		"failed_tickets":   job.FailedTickets,
+		"last_error":       job.LastError,
+		"completed_at":     &now,
+		"updated_at":       now,
+	}).Error; err != nil {
+		fmt.Println("Erreur lors de la clôture du job d'annulation :", err)
+	}
+	fmt.Printf("Annulation du concert %s : %d remboursement(s), %d échec(s)\n", job.ConcertId, job.RefundedTickets, job.FailedTickets)
+}
+
+// refundCancelledTicket rembourse un ticket d'un concert annulé, en reprenant la demande
+// de remboursement déjà ouverte pour ce ticket s'il en existe une
+func refundCancelledTicket(ctx context.Context, db *gorm.DB, job *models.ConcertCancellation, ticket *models.Ticket) (*models.RefundRequest, error) {
+	var request models.RefundRequest
+	err := db.Where("ticket_id = ? AND status IN ?", ticket.ID,
+		[]string{models.RefundRequestPending, models.RefundRequestApproved, models.RefundRequestFailed}).
+		First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		created, err := createRefundRequest(db, ticket.ID, job.RequestedById, "Concert annulé", true)
+		if err != nil {
+			return nil, err
+		}
+		request = *created
+	} else if err != nil {
+		return nil, err
+	}
+
+	if request.CancellationId == nil {
+		request.CancellationId = &job.ID
+		if err := db.Model(&request).Update("cancellation_id", job.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Une demande restée approuvée a été interrompue : elle est reprise sans nouvelle approbation
+	if request.Status == models.RefundRequestApproved {
+		if err := executeRefund(ctx, db, &request); err != nil {
+			return nil, err
+		}
+		return &request, nil
+	}
+	return processRefundRequest(ctx, db, request.ID, job.RequestedById)
+}
+
+// notifyConcertCancelled prévient le détenteur d'un ticket de l'annulation du concert et de son
+// remboursement, par notification push et par email
+func notifyConcertCancelled(db *gorm.DB, concert *models.Concert, userID uuid.UUID, request *models.RefundRequest) {
+	amount := request.Amount.String()
+
+	data := map[string]string{
+		"concert_id": concert.ID.String(),
+		"refund_id":  request.ID.String(),
+	}
+	notification := map[string]string{
+		"title": "Concert annulé",
+		"body":  fmt.Sprintf("Le concert \"%s\" est annulé. Votre ticket vous a été remboursé (%s).", concert.Name, amount),
+	}
+	if err := SendFCMNotification(userTopic(userID), data, notification); err != nil {
+		fmt.Printf("Failed to send cancellation notification to user %s: %v\n", userID, err)
+	}
+
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		fmt.Printf("Failed to find user %s for cancellation email: %v\n", userID, err)
+		return
+	}
+	html := `<!DOCTYPE html>
+<html lang="fr">
+  <body>
+    <h1>Weezemaster</h1>
+    <p>Bonjour ` + user.Firstname + `,</p>
+    <p>Nous sommes au regret de vous informer que le concert <strong>` + concert.Name + `</strong> prévu le ` + concert.Date.Format("02/01/2006 15:04") + ` est annulé.</p>
+    <p>Votre ticket vous a été remboursé : <strong>` + amount + `</strong>.</p>
+    <p>À bientôt sur <strong>Weezemaster</strong>.</p>
+  </body>
+</html>`
+	if err := sendEmail(user.Email, "Weezemaster - Concert annulé", html); err != nil {
+		fmt.Printf("Failed to send cancellation email to user %s: %v\n", userID, err)
+	}
+}
+
+// ResumeConcertCancellations relance au démarrage les jobs d'annulation interrompus
+func ResumeConcertCancellations() {
+	db := database.GetDB()
+
+	var jobs []models.ConcertCancellation
+	if err := db.Where("status = ?", models.ConcertCancellationRunning).Find(&jobs).Error; err != nil {
+		fmt.Println("Erreur lors de la récupération des jobs d'annulation :", err)
+		return
+	}
+	for _, job := range jobs {
+		go runConcertCancellation(job.ID)
+	}
+}
+
+// @Summary		Annule un concert
+// @Description	Annule un concert, retire ses annonces et rembourse tous les détenteurs de tickets en arrière-plan. Relance un job d'annulation terminé en échec.
+// @ID				cancel-concert
+// @Tags			Concerts
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID du concert"	format(uuid)
+// @Param			body	body		object	false	"reason"
+// @Success		202		{object}	models.ConcertCancellation
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/concerts/{id}/cancel [post]
+// @Security		Bearer
+func CancelConcert(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var reqBody struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	var concert models.Concert
+	if err := db.Where("id = ?", c.Param("id")).First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if user.Role != "admin" && concert.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Concert does not belong to your organization"})
+	}
+
+	job, err := startConcertCancellation(db, concert.ID, user.ID, reqBody.Reason)
+	if err != nil {
+		if errors.Is(err, errConcertAlreadyCancelled) || errors.Is(err, errCancellationRunning) || errors.Is(err, errInvalidConcertTransition) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel concert"})
+	}
+
+	go runConcertCancellation(job.ID)
+
+	c.Logger().Infof("event=ConcertCancelled concert_id=%s user_id=%s timestamp=%s", concert.ID, user.ID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// @Summary		Progression de l'annulation d'un concert
+// @Description	Renvoie l'état du job de remboursement d'un concert annulé et le nombre de tickets restant à rembourser
+// @ID				get-concert-cancellation
+// @Tags			Concerts
+// @Produce		json
+// @Param			id	path		string	true	"ID du concert"	format(uuid)
+// @Success		200	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/concerts/{id}/cancellation [get]
+// @Security		Bearer
+func GetConcertCancellation(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	var job models.ConcertCancellation
+	if err := db.Preload("Concert").Where("concert_id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert cancellation not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if user.Role != "admin" && job.Concert.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Concert does not belong to your organization"})
+	}
+
+	var remaining int64
+	if err := db.Model(&models.Ticket{}).
+		Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+		Where("concert_categories.concert_id = ? AND tickets.status = ?", job.ConcertId, models.TicketValid).
+		Count(&remaining).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"cancellation":     job,
+		"remainingTickets": remaining,
+	})
+}
@@ -0,0 +1,336 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	catalogDefaultLimit = 20
+	catalogMaxLimit     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor value")
+
+// catalogSort décrit un tri du catalogue : l'expression triée et le sens par défaut
+type catalogSort struct {
+	expr string
+	desc bool
+}
+
+// La popularité est le nombre de places vendues, le prix celui de la catégorie la moins chère dans
+// la devise du catalogue : les montants de devises différentes ne sont pas comparés entre eux
+var catalogSorts = map[string]catalogSort{
+	"date":       {expr: "concerts.date", desc: false},
+	"popularity": {expr: "COALESCE(concert_stats.popularity, 0)", desc: true},
+	"price":      {expr: "COALESCE(concert_stats.min_price, 0)", desc: false},
+}
+
+// catalogCursor repère le dernier concert d'une page : la valeur triée et l'ID, qui départage
+// les égalités
+type catalogCursor struct {
+	Key string    `json:"k"`
+	ID  uuid.UUID `json:"id"`
+}
+
+func encodeCatalogCursor(cursor catalogCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCatalogCursor(value string) (*catalogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor catalogCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, errInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// catalogRow est une ligne de la requête paginée, avant le chargement des concerts
+type catalogRow struct {
+	ID         uuid.UUID
+	Date       time.Time
+	Popularity int64
+	MinPrice   int64
+}
+
+func (r catalogRow) key(sort string) string {
+	switch sort {
+	case "popularity":
+		return strconv.FormatInt(r.Popularity, 10)
+	case "price":
+		return strconv.FormatInt(r.MinPrice, 10)
+	}
+	return r.Date.UTC().Format(time.RFC3339Nano)
+}
+
+func parseCatalogKey(sort, key string) (interface{}, error) {
+	if sort == "date" {
+		t, err := time.Parse(time.RFC3339Nano, key)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		return t, nil
+	}
+	n, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	return n, nil
+}
+
+// ConcertCatalogPage est une page du catalogue. NextCursor est vide sur la dernière page.
+type ConcertCatalogPage struct {
+	Items      []models.Concert `json:"items"`
+	NextCursor string           `json:"nextCursor"`
+	Total      int64            `json:"total"`
+}
+
+// catalogCurrency renvoie la devise des prix du catalogue, EUR par défaut
+func catalogCurrency(c echo.Context) (string, error) {
+	value := c.QueryParam("currency")
+	if value == "" {
+		return models.DefaultCurrency, nil
+	}
+	price := models.NewMoney(0, value)
+	if err := price.Validate(); err != nil {
+		return "", fmt.Errorf("invalid currency value")
+	}
+	return price.Currency, nil
+}
+
+// catalogQuery construit la requête filtrée du catalogue. Les brouillons ne sont jamais listés ;
+// les concerts passés et annulés sont exclus sauf si includePast ou includeCancelled sont demandés.
+func catalogQuery(c echo.Context, db *gorm.DB) (*gorm.DB, error) {
+	currency, err := catalogCurrency(c)
+	if err != nil {
+		return nil, err
+	}
+	stats := db.Model(&models.ConcertCategory{}).
+		Select("concert_id, SUM(sold_tickets) AS popularity, MIN(price_amount) FILTER (WHERE price_currency = ?) AS min_price", currency).
+		Group("concert_id")
+	query := db.Table("concerts").
+		Joins("LEFT JOIN (?) AS concert_stats ON concert_stats.concert_id = concerts.id", stats).
+		Where("concerts.deleted_at IS NULL AND concerts.status <> ?", models.ConcertDraft)
+
+	if includePast, _ := strconv.ParseBool(c.QueryParam("includePast")); !includePast {
+		query = query.Where("concerts.date >= ?", time.Now())
+	}
+	if includeCancelled, _ := strconv.ParseBool(c.QueryParam("includeCancelled")); !includeCancelled {
+		query = query.Where("concerts.status <> ?", models.ConcertCancelled)
+	}
+
+	for param, column := range map[string]string{"artistId": "concerts.artist_id", "organizationId": "concerts.organization_id"} {
+		if value := c.QueryParam(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value", param)
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if value := c.QueryParam("interestId"); value != "" {
+		interestID, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interestId value")
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM concert_interests WHERE concert_interests.concert_id = concerts.id AND concert_interests.interest_id = ?)", interestID)
+	}
+
+	if value := c.QueryParam("dateFrom"); value != "" {
+		from, err := parseMarketplaceDate(value, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dateFrom value")
+		}
+		query = query.Where("concerts.date >= ?", from)
+	}
+	if value := c.QueryParam("dateTo"); value != "" {
+		to, err := parseMarketplaceDate(value, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dateTo value")
+		}
+		query = query.Where("concerts.date <= ?", to)
+	}
+	if location := strings.TrimSpace(c.QueryParam("location")); location != "" {
+		query = query.Where("concerts.location ILIKE ?", "%"+location+"%")
+	}
+
+	// Catégorie et fourchette de prix portent sur une même catégorie du concert ; les bornes
+	// sont en unités mineures, dans la devise demandée (EUR par défaut)
+	var categoryConditions []string
+	var categoryArgs []interface{}
+	if value := c.QueryParam("categoryId"); value != "" {
+		categoryID, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid categoryId value")
+		}
+		categoryConditions = append(categoryConditions, "concert_categories.category_id = ?")
+		categoryArgs = append(categoryArgs, categoryID)
+	}
+	minPrice, maxPrice := c.QueryParam("minPrice"), c.QueryParam("maxPrice")
+	if minPrice != "" || maxPrice != "" {
+		categoryConditions = append(categoryConditions, "concert_categories.price_currency = ?")
+		categoryArgs = append(categoryArgs, currency)
+		for param, operator := range map[string]string{"minPrice": ">=", "maxPrice": "<="} {
+			value := c.QueryParam(param)
+			if value == "" {
+				continue
+			}
+			amount, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("invalid %s value", param)
+			}
+			categoryConditions = append(categoryConditions, "concert_categories.price_amount "+operator+" ?")
+			categoryArgs = append(categoryArgs, amount)
+		}
+	}
+	if len(categoryConditions) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM concert_categories WHERE concert_categories.concert_id = concerts.id AND "+
+			strings.Join(categoryConditions, " AND ")+")", categoryArgs...)
+	}
+
+	return query, nil
+}
+
+// @Summary		Récupère le catalogue des concerts
+// @Description	Catalogue paginé par curseur des concerts, à venir et non annulés par défaut. Le curseur renvoyé dans nextCursor donne la page suivante avec les mêmes filtres et le même tri. Les prix sont exprimés en unités mineures.
+// @ID				get-all-concerts
+// @Tags			Concerts
+// @Produce		json
+// @Param			dateFrom			query		string	false	"Concerts à partir de cette date (2006-01-02 ou RFC 3339)"
+// @Param			dateTo				query		string	false	"Concerts jusqu'à cette date (2006-01-02 ou RFC 3339)"
+// @Param			location			query		string	false	"Lieu du concert (recherche partielle)"
+// @Param			interestId			query		int		false	"ID d'un centre d'intérêt"
+// @Param			artistId			query		string	false	"ID de l'artiste"	format(uuid)
+// @Param			organizationId		query		string	false	"ID de l'organisation"	format(uuid)
+// @Param			categoryId			query		int		false	"ID d'une catégorie proposée par le concert"
+// @Param			minPrice			query		int		false	"Prix minimum d'une catégorie, en unités mineures"
+// @Param			maxPrice			query		int		false	"Prix maximum d'une catégorie, en unités mineures"
+// @Param			currency			query		string	false	"Devise des bornes de prix et du tri par prix (EUR par défaut)"
+// @Param			includePast			query		bool	false	"Inclut les concerts passés"
+// @Param			includeCancelled	query		bool	false	"Inclut les concerts annulés"
+// @Param			sort				query		string	false	"Tri : date (par défaut), popularity ou price. Le tri par prix ne liste que les concerts ayant une catégorie dans la devise demandée."
+// @Param			order				query		string	false	"Sens du tri : asc ou desc (par défaut : asc, sauf popularity)"
+// @Param			limit				query		int		false	"Nombre de concerts par page (20 par défaut, 100 au maximum)"
+// @Param			cursor				query		string	false	"Curseur de la page suivante"
+// @Success		200					{object}	ConcertCatalogPage
+// @Failure		400					{object}	map[string]string
+// @Failure		500					{object}	map[string]string
+// @Router			/concerts [get]
+func GetAllConcerts(c echo.Context) error {
+	db := database.GetDB()
+
+	sortName := c.QueryParam("sort")
+	if sortName == "" {
+		sortName = "date"
+	}
+	sort, ok := catalogSorts[sortName]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid sort value"})
+	}
+	switch c.QueryParam("order") {
+	case "":
+	case "asc":
+		sort.desc = false
+	case "desc":
+		sort.desc = true
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid order value"})
+	}
+	limit, err := parsePositiveQueryInt(c, "limit", catalogDefaultLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if limit > catalogMaxLimit {
+		limit = catalogMaxLimit
+	}
+
+	query, err := catalogQuery(c, db)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if sortName == "price" {
+		query = query.Where("concert_stats.min_price IS NOT NULL")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve concerts"})
+	}
+
+	direction, comparison := "ASC", ">"
+	if sort.desc {
+		direction, comparison = "DESC", "<"
+	}
+	page := query
+	if value := c.QueryParam("cursor"); value != "" {
+		cursor, err := decodeCatalogCursor(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		key, err := parseCatalogKey(sortName, cursor.Key)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		page = page.Where(fmt.Sprintf("(%s, concerts.id) %s (?, ?)", sort.expr, comparison), key, cursor.ID)
+	}
+
+	// Une ligne de plus que la page indique s'il reste des concerts après celle-ci
+	var rows []catalogRow
+	if err := page.
+		Select("concerts.id, concerts.date, COALESCE(concert_stats.popularity, 0) AS popularity, COALESCE(concert_stats.min_price, 0) AS min_price").
+		Order(fmt.Sprintf("%s %s, concerts.id %s", sort.expr, direction, direction)).
+		Limit(limit + 1).
+		Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve concerts"})
+	}
+
+	response := ConcertCatalogPage{Items: []models.Concert{}, Total: total}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		response.NextCursor = encodeCatalogCursor(catalogCursor{Key: last.key(sortName), ID: last.ID})
+	}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var concerts []models.Concert
+	if err := db.Preload("Interests").Preload("Artist").Preload("Venue").Where("id IN ?", ids).Find(&concerts).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve concerts"})
+	}
+
+	// Les concerts sont rendus dans l'ordre de la page
+	byID := make(map[uuid.UUID]models.Concert, len(concerts))
+	for _, concert := range concerts {
+		byID[concert.ID] = concert
+	}
+	for _, id := range ids {
+		if concert, ok := byID[id]; ok {
+			response.Items = append(response.Items, concert)
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidConcertTransition = errors.New("this status change is not allowed for the concert")
+var errSalesNotOpen = errors.New("ticket sales are not open for this concert")
+var errSalesClosed = errors.New("ticket sales are closed for this concert")
+var errInvalidSalesWindow = errors.New("sales must open before they close, and close before the concert")
+var errConcertInPast = errors.New("the concert date has passed")
+
+// Intervalle entre deux passages du sweeper du cycle de vie des concerts
+const concertLifecycleInterval = time.Minute
+
+// concertTransitions liste les changements de statut autorisés pour un concert
+var concertTransitions = map[string][]string{
+	models.ConcertDraft:     {models.ConcertPublished, models.ConcertOnSale, models.ConcertCancelled},
+	models.ConcertPublished: {models.ConcertDraft, models.ConcertOnSale, models.ConcertPostponed, models.ConcertCancelled, models.ConcertCompleted},
+	models.ConcertOnSale:    {models.ConcertPublished, models.ConcertSoldOut, models.ConcertPostponed, models.ConcertCancelled, models.ConcertCompleted},
+	models.ConcertSoldOut:   {models.ConcertOnSale, models.ConcertPostponed, models.ConcertCancelled, models.ConcertCompleted},
+	models.ConcertPostponed: {models.ConcertPublished, models.ConcertOnSale, models.ConcertCancelled},
+}
+
+// organizerConcertStatuses sont les statuts qu'un organisateur peut demander directement.
+// sold_out et completed sont atteints automatiquement ; l'annulation passe par
+// POST /concerts/{id}/cancel, qui rembourse les tickets.
+var organizerConcertStatuses = map[string]bool{
+	models.ConcertDraft:     true,
+	models.ConcertPublished: true,
+	models.ConcertOnSale:    true,
+	models.ConcertPostponed: true,
+}
+
+func canTransitionConcert(from, to string) bool {
+	for _, allowed := range concertTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionConcert fait passer un concert dans un nouveau statut si la transition est autorisée.
+// Mettre un concert en vente ouvre la vente immédiatement si elle était programmée plus tard.
+func transitionConcert(tx *gorm.DB, concert *models.Concert, to string) error {
+	if !canTransitionConcert(concert.Status, to) {
+		return errInvalidConcertTransition
+	}
+	now := time.Now()
+	concert.Status = to
+	if to == models.ConcertOnSale && (concert.SalesOpenAt == nil || concert.SalesOpenAt.After(now)) {
+		concert.SalesOpenAt = &now
+	}
+	concert.UpdatedAt = now
+	return tx.Model(&models.Concert{}).Where("id = ?", concert.ID).Updates(map[string]interface{}{
+		"status":        concert.Status,
+		"sales_open_at": concert.SalesOpenAt,
+		"updated_at":    concert.UpdatedAt,
+	}).Error
+}
+
+// salesWindow renvoie la période de vente d'une catégorie : la sienne, à défaut celle du concert.
+// La vente ferme au plus tard au début du concert.
+func salesWindow(concert *models.Concert, concertCategory *models.ConcertCategory) (*time.Time, time.Time) {
+	opensAt := concert.SalesOpenAt
+	closesAt := concert.SalesCloseAt
+	if concertCategory != nil {
+		if concertCategory.SalesOpenAt != nil {
+			opensAt = concertCategory.SalesOpenAt
+		}
+		if concertCategory.SalesCloseAt != nil {
+			closesAt = concertCategory.SalesCloseAt
+		}
+	}
+	if closesAt == nil || closesAt.After(concert.Date) {
+		return opensAt, concert.Date
+	}
+	return opensAt, *closesAt
+}
+
+func validateSalesWindow(opensAt, closesAt *time.Time, date time.Time) error {
+	if opensAt != nil && closesAt != nil && !opensAt.Before(*closesAt) {
+		return errInvalidSalesWindow
+	}
+	if closesAt != nil && closesAt.After(date) {
+		return errInvalidSalesWindow
+	}
+	return nil
+}
+
+// parseSalesDate lit une date d'ouverture ou de fermeture de la vente, saisie à l'heure locale
+// de la salle. Une valeur vide garde la date actuelle.
+func parseSalesDate(value string, venue *models.Venue, current *time.Time) (*time.Time, error) {
+	if value == "" {
+		return current, nil
+	}
+	date, err := parseConcertDate(value, venue)
+	if err != nil {
+		return nil, errInvalidSalesWindow
+	}
+	return &date, nil
+}
+
+// checkConcertOpen vérifie que la vente d'une catégorie est ouverte avant de vendre ou bloquer une
+// place : concert en vente ou complet, et période de vente en cours. Un utilisateur dont le hold
+// sur la catégorie est encore actif a commencé à payer avant la fermeture et peut terminer son
+// achat ; uuid.Nil n'accorde pas ce délai.
+func checkConcertOpen(tx *gorm.DB, concertCategory *models.ConcertCategory, userID uuid.UUID) error {
+	var concert models.Concert
+	if err := tx.Select("id", "status", "date", "sales_open_at", "sales_close_at").
+		Where("id = ?", concertCategory.ConcertId).First(&concert).Error; err != nil {
+		return err
+	}
+	switch concert.Status {
+	case models.ConcertCancelled:
+		return errConcertCancelled
+	case models.ConcertOnSale, models.ConcertSoldOut:
+	default:
+		return errSalesNotOpen
+	}
+
+	now := time.Now()
+	opensAt, closesAt := salesWindow(&concert, concertCategory)
+	if opensAt != nil && now.Before(*opensAt) {
+		return errSalesNotOpen
+	}
+	if now.Before(closesAt) {
+		return nil
+	}
+	if userID != uuid.Nil {
+		var holds int64
+		if err := tx.Model(&models.TicketHold{}).
+			Where("user_id = ? AND concert_category_id = ? AND status = ? AND expires_at > ?",
+				userID, concertCategory.ID, models.TicketHoldActive, now).
+			Count(&holds).Error; err != nil {
+			return err
+		}
+		if holds > 0 {
+			return nil
+		}
+	}
+	return errSalesClosed
+}
+
+// syncSoldOut passe en sold_out les concerts en vente dont toutes les places sont vendues, et
+// remet en vente ceux qui ont de nouveau des places, par exemple après un remboursement.
+// concertID restreint la mise à jour à un concert.
+func syncSoldOut(tx *gorm.DB, concertID *uuid.UUID) error {
+	remaining := "EXISTS (SELECT 1 FROM concert_categories WHERE concert_categories.concert_id = concerts.id " +
+		"AND concert_categories.deleted_at IS NULL AND COALESCE(concert_categories.sold_tickets, 0) < concert_categories.available_tickets)"
+	hasCategories := "EXISTS (SELECT 1 FROM concert_categories WHERE concert_categories.concert_id = concerts.id AND concert_categories.deleted_at IS NULL)"
+
+	scope := func() *gorm.DB {
+		query := tx.Model(&models.Concert{})
+		if concertID != nil {
+			query = query.Where("id = ?", *concertID)
+		}
+		return query
+	}
+	if err := scope().Where("status = ? AND "+hasCategories+" AND NOT "+remaining, models.ConcertOnSale).
+		Updates(map[string]interface{}{"status": models.ConcertSoldOut, "updated_at": time.Now()}).Error; err != nil {
+		return err
+	}
+	return scope().Where("status = ? AND "+remaining, models.ConcertSoldOut).
+		Updates(map[string]interface{}{"status": models.ConcertOnSale, "updated_at": time.Now()}).Error
+}
+
+// advanceConcertLifecycles ouvre les ventes programmées, termine les concerts commencés et tient
+// à jour les concerts complets, puis laisse entrer les files d'attente des ventes ouvertes
+func advanceConcertLifecycles() {
+	db := database.GetDB()
+	now := time.Now()
+
+	var opening []models.Concert
+	if err := db.Where("status = ? AND sales_open_at <= ? AND date > ?", models.ConcertPublished, now, now).Find(&opening).Error; err != nil {
+		fmt.Println("Erreur lors de la récupération des ventes à ouvrir :", err)
+		return
+	}
+	for _, concert := range opening {
+		if err := transitionConcert(db, &concert, models.ConcertOnSale); err != nil {
+			fmt.Printf("Erreur lors de l'ouverture de la vente du concert %s : %v\n", concert.ID, err)
+			continue
+		}
+		fmt.Printf("Vente ouverte pour le concert %s\n", concert.ID)
+	}
+
+	res := db.Model(&models.Concert{}).
+		Where("status IN ? AND date <= ?", []string{models.ConcertPublished, models.ConcertOnSale, models.ConcertSoldOut}, now).
+		Updates(map[string]interface{}{"status": models.ConcertCompleted, "updated_at": now})
+	if res.Error != nil {
+		fmt.Println("Erreur lors de la clôture des concerts passés :", res.Error)
+	} else if res.RowsAffected > 0 {
+		fmt.Printf("%d concert(s) terminé(s)\n", res.RowsAffected)
+	}
+
+	if err := syncSoldOut(db, nil); err != nil {
+		fmt.Println("Erreur lors de la mise à jour des concerts complets :", err)
+	}
+
+	admitOpenQueues()
+}
+
+// StartConcertLifecycleSweeper lance en arrière-plan l'avancement périodique du cycle de vie des concerts
+func StartConcertLifecycleSweeper() {
+	ticker := time.NewTicker(concertLifecycleInterval)
+	go func() {
+		for range ticker.C {
+			advanceConcertLifecycles()
+		}
+	}()
+}
+
+// queueOpening indique si la file d'attente d'un concert peut laisser entrer. Elle renvoie la date
+// d'ouverture de la vente quand la salle d'attente est ouverte avant la vente, nil si la vente est
+// ouverte, et une erreur si la vente est fermée.
+func queueOpening(concertID string) (*time.Time, error) {
+	db := database.GetDB()
+
+	var concert models.Concert
+	if err := db.Where("id = ?", concertID).First(&concert).Error; err != nil {
+		return nil, err
+	}
+	switch concert.Status {
+	case models.ConcertOnSale, models.ConcertSoldOut:
+		if _, closesAt := salesWindow(&concert, nil); !time.Now().Before(closesAt) {
+			return nil, errSalesClosed
+		}
+		return nil, nil
+	case models.ConcertPublished:
+		if concert.SalesOpenAt != nil && concert.Date.After(time.Now()) {
+			return concert.SalesOpenAt, nil
+		}
+	case models.ConcertCancelled:
+		return nil, errConcertCancelled
+	case models.ConcertCompleted:
+		return nil, errSalesClosed
+	}
+	return nil, errSalesNotOpen
+}
+
+// canManageConcert indique si l'appelant authentifié est administrateur ou membre de
+// l'organisation du concert
+func canManageConcert(c echo.Context, db *gorm.DB, concert *models.Concert) bool {
+	userID := optionalUserID(c)
+	if userID == uuid.Nil {
+		return false
+	}
+	var user models.User
+	if err := db.Select("id", "role", "organization_id").Where("id = ?", userID).First(&user).Error; err != nil {
+		return false
+	}
+	return user.Role == "admin" || (user.OrganizationId != uuid.Nil && user.OrganizationId == concert.OrganizationId)
+}
+
+// @Summary		Change le statut d'un concert
+// @Description	Fait passer un concert en draft, published, on_sale ou postponed selon les transitions autorisées. Un concert publié est annoncé sans être en vente ; sa vente ouvre à salesOpenAt si elle est programmée. Mettre en vente ouvre la vente immédiatement. sold_out et completed sont automatiques et l'annulation passe par POST /concerts/{id}/cancel.
+// @ID				update-concert-status
+// @Tags			Concerts
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID du concert"	format(uuid)
+// @Param			body	body		object	true	"status"
+// @Success		200		{object}	models.Concert
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/concerts/{id}/status [post]
+// @Security		Bearer
+func UpdateConcertStatus(c echo.Context) error {
+	db := database.GetDB()
+
+	user, role, err := venueUser(c, db)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	var reqBody struct {
+		Status string `json:"status"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if !organizerConcertStatuses[reqBody.Status] {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be draft, published, on_sale or postponed"})
+	}
+
+	var concert models.Concert
+	if err := db.Where("id = ?", c.Param("id")).First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if role != "admin" && concert.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Concert does not belong to your organization"})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", concert.ID).First(&concert).Error; err != nil {
+			return err
+		}
+		if reqBody.Status != models.ConcertDraft && reqBody.Status != models.ConcertPostponed && !concert.Date.After(time.Now()) {
+			return errConcertInPast
+		}
+		if err := transitionConcert(tx, &concert, reqBody.Status); err != nil {
+			return err
+		}
+		// Un concert remis en vente peut être complet
+		if concert.Status == models.ConcertOnSale {
+			if err := syncSoldOut(tx, &concert.ID); err != nil {
+				return err
+			}
+			return tx.Where("id = ?", concert.ID).First(&concert).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidConcertTransition) || errors.Is(err, errConcertInPast) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update concert status"})
+	}
+
+	if concert.Status == models.ConcertOnSale {
+		go admitFromQueue(concert.ID.String())
+	}
+
+	c.Logger().Infof("event=ConcertStatusChanged concert_id=%s status=%s user_id=%s timestamp=%s", concert.ID, concert.Status, user.ID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, concert)
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"weezemaster/internal/models"
+)
+
+var concertStatuses = []string{
+	models.ConcertDraft,
+	models.ConcertPublished,
+	models.ConcertOnSale,
+	models.ConcertSoldOut,
+	models.ConcertPostponed,
+	models.ConcertCancelled,
+	models.ConcertCompleted,
+}
+
+func TestCanTransitionConcert(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.ConcertDraft, models.ConcertPublished, true},
+		{models.ConcertDraft, models.ConcertOnSale, true},
+		{models.ConcertDraft, models.ConcertCancelled, true},
+		{models.ConcertDraft, models.ConcertSoldOut, false},
+		{models.ConcertDraft, models.ConcertCompleted, false},
+		{models.ConcertDraft, models.ConcertPostponed, false},
+		{models.ConcertPublished, models.ConcertDraft, true},
+		{models.ConcertPublished, models.ConcertOnSale, true},
+		{models.ConcertPublished, models.ConcertSoldOut, false},
+		{models.ConcertOnSale, models.ConcertSoldOut, true},
+		{models.ConcertOnSale, models.ConcertPublished, true},
+		{models.ConcertOnSale, models.ConcertDraft, false},
+		{models.ConcertSoldOut, models.ConcertOnSale, true},
+		{models.ConcertSoldOut, models.ConcertCompleted, true},
+		{models.ConcertSoldOut, models.ConcertPublished, false},
+		{models.ConcertPostponed, models.ConcertOnSale, true},
+		{models.ConcertPostponed, models.ConcertCompleted, false},
+		{models.ConcertCancelled, models.ConcertOnSale, false},
+		{models.ConcertCancelled, models.ConcertDraft, false},
+		{models.ConcertCompleted, models.ConcertOnSale, false},
+		{"", models.ConcertOnSale, false},
+		{models.ConcertDraft, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			if got := canTransitionConcert(tt.from, tt.to); got != tt.want {
+				t.Errorf("canTransitionConcert(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+// Un concert annulé ou terminé ne change plus de statut, et aucun statut ne mène à lui-même
+func TestConcertTransitionsInvariants(t *testing.T) {
+	for _, from := range concertStatuses {
+		for _, to := range concertStatuses {
+			allowed := canTransitionConcert(from, to)
+			if allowed && (from == models.ConcertCancelled || from == models.ConcertCompleted) {
+				t.Errorf("%s is final but can move to %s", from, to)
+			}
+			if allowed && from == to {
+				t.Errorf("%s can move to itself", from)
+			}
+		}
+	}
+}
+
+func TestSalesWindow(t *testing.T) {
+	date := time.Date(2030, 6, 1, 20, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		value := date.AddDate(0, 0, days)
+		return &value
+	}
+
+	tests := []struct {
+		name         string
+		concert      models.Concert
+		category     *models.ConcertCategory
+		wantOpensAt  *time.Time
+		wantClosesAt time.Time
+	}{
+		{"no window closes at the concert", models.Concert{Date: date}, nil, nil, date},
+		{"concert window", models.Concert{Date: date, SalesOpenAt: at(-30), SalesCloseAt: at(-1)}, nil, at(-30), *at(-1)},
+		{"close after the concert is capped", models.Concert{Date: date, SalesCloseAt: at(2)}, nil, nil, date},
+		{"category overrides the concert", models.Concert{Date: date, SalesOpenAt: at(-30), SalesCloseAt: at(-1)},
+			&models.ConcertCategory{SalesOpenAt: at(-10), SalesCloseAt: at(-5)}, at(-10), *at(-5)},
+		{"category without window inherits", models.Concert{Date: date, SalesOpenAt: at(-30)},
+			&models.ConcertCategory{}, at(-30), date},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opensAt, closesAt := salesWindow(&tt.concert, tt.category)
+			if (opensAt == nil) != (tt.wantOpensAt == nil) || (opensAt != nil && !opensAt.Equal(*tt.wantOpensAt)) {
+				t.Errorf("opensAt = %v, want %v", opensAt, tt.wantOpensAt)
+			}
+			if !closesAt.Equal(tt.wantClosesAt) {
+				t.Errorf("closesAt = %v, want %v", closesAt, tt.wantClosesAt)
+			}
+		})
+	}
+}
+
+func TestValidateSalesWindow(t *testing.T) {
+	date := time.Date(2030, 6, 1, 20, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		value := date.AddDate(0, 0, days)
+		return &value
+	}
+
+	tests := []struct {
+		name     string
+		opensAt  *time.Time
+		closesAt *time.Time
+		wantErr  bool
+	}{
+		{"no window", nil, nil, false},
+		{"open only", at(-30), nil, false},
+		{"valid window", at(-30), at(-1), false},
+		{"close at the concert", at(-30), &date, false},
+		{"open after close", at(-1), at(-30), true},
+		{"open equals close", at(-1), at(-1), true},
+		{"close after the concert", nil, at(1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSalesWindow(tt.opensAt, tt.closesAt, date)
+			if tt.wantErr && !errors.Is(err, errInvalidSalesWindow) {
+				t.Errorf("validateSalesWindow() = %v, want errInvalidSalesWindow", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("validateSalesWindow() = %v, want nil", err)
+			}
+		})
+	}
+}
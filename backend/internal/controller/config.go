@@ -2,14 +2,107 @@ package controller
 
 import (
 	"fmt"
+	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"weezemaster/internal/config"
 
 	"github.com/labstack/echo/v4"
 )
 
+// Plafond des durées et limites de weezemaster.config
+const maxConfigValue = 1000000
+
+// configBounds borne une valeur entière de weezemaster.config
+type configBounds struct {
+	min, max int64
+}
+
+// configurableKeys liste les clés de weezemaster.config modifiables par un administrateur et leurs
+// bornes. Les durées et limites sont strictement positives, les taux en points de base vont de
+// 0 à 10000 (100 %).
+var configurableKeys = map[string]configBounds{
+	"CONCERTS_MAX_USERS_BEFORE_QUEUE":  {1, maxConfigValue},
+	"TICKET_HOLD_DURATION_MINUTES":     {1, maxConfigValue},
+	"MAX_TICKETS_PER_USER_PER_CONCERT": {1, maxConfigValue},
+	"TICKET_QR_ROTATION_SECONDS":       {1, maxConfigValue},
+	"PLATFORM_FEE_PRIMARY_BPS":         {0, 10000},
+	"PLATFORM_FEE_RESALE_BPS":          {0, 10000},
+	"ORGANIZER_FEE_RESALE_BPS":         {0, 10000},
+	"OFFER_EXPIRY_HOURS":               {1, maxConfigValue},
+	"TICKET_TRANSFER_EXPIRY_HOURS":     {1, maxConfigValue},
+	"RESALE_MIN_HOLD_HOURS":            {1, maxConfigValue},
+	"LISTING_EXPIRY_OFFSET_HOURS":      {1, maxConfigValue},
+	"WAITLIST_WINDOW_MINUTES":          {1, maxConfigValue},
+	"RATING_WINDOW_DAYS":               {1, maxConfigValue},
+	"FRAUD_MAX_PURCHASES_PER_WINDOW":   {1, maxConfigValue},
+	"FRAUD_PURCHASE_WINDOW_MINUTES":    {1, maxConfigValue},
+	"FRAUD_MIN_ACCOUNT_AGE_MINUTES":    {1, maxConfigValue},
+	"FRAUD_APPROVAL_VALIDITY_HOURS":    {1, maxConfigValue},
+}
+
+// Les commissions doivent figurer dans weezemaster.config : une clé absente les désactiverait
+var requiredConfigKeys = []string{"PLATFORM_FEE_PRIMARY_BPS", "PLATFORM_FEE_RESALE_BPS", "ORGANIZER_FEE_RESALE_BPS"}
+
+// parseConfigValue convertit une valeur de configuration et vérifie ses bornes
+func parseConfigValue(key, raw string) (int64, error) {
+	bounds, ok := configurableKeys[key]
+	if !ok {
+		return 0, fmt.Errorf("unknown configuration key %s", key)
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil || value < bounds.min || value > bounds.max {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", key, bounds.min, bounds.max)
+	}
+	return value, nil
+}
+
+// ValidateConfig vérifie au démarrage les valeurs chargées de weezemaster.config, pour qu'une
+// faute de frappe empêche le serveur de démarrer au lieu de fausser les limites ou les commissions
+func ValidateConfig() error {
+	for _, key := range requiredConfigKeys {
+		if _, exists := config.Get(key); !exists {
+			return fmt.Errorf("%s is missing", key)
+		}
+	}
+	for key := range configurableKeys {
+		raw, exists := config.Get(key)
+		if !exists {
+			continue
+		}
+		if _, err := parseConfigValue(key, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getConfigInt lit une valeur entière de weezemaster.config, ou renvoie la valeur par défaut si
+// la clé est absente. Les valeurs sont validées au démarrage et à l'écriture.
+func getConfigInt(key string, defaultValue int) int {
+	raw, exists := config.Get(key)
+	if !exists {
+		return defaultValue
+	}
+	value, err := parseConfigValue(key, raw)
+	if err != nil {
+		log.Printf("Invalid configuration, using the default value %d: %v", defaultValue, err)
+		return defaultValue
+	}
+	return int(value)
+}
+
+// getConfigBasisPoints lit un taux en points de base (0 à 10000) de weezemaster.config. Une clé
+// absente ou invalide est une erreur : les commissions ne retombent jamais à 0 en silence.
+func getConfigBasisPoints(key string) (int64, error) {
+	raw, exists := config.Get(key)
+	if !exists {
+		return 0, fmt.Errorf("%s is missing", key)
+	}
+	return parseConfigValue(key, raw)
+}
+
 // @Summary		Récupérer la valeur d'une configuration
 // @Description	Récupérer la valeur d'une configuration
 // @ID				get-config
@@ -24,16 +117,11 @@ import (
 // @Security		Bearer
 func GetConfigValue(c echo.Context) error {
 	key := c.Param("key")
-	if key != "CONCERTS_MAX_USERS_BEFORE_QUEUE" {
+	if _, ok := configurableKeys[key]; !ok {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid configuration key"})
 	}
 
-	err := config.LoadConfig("../../cmd/weezemaster/config/weezemaster.config")
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load configuration"})
-	}
-
-	value, exists := config.Config[key]
+	value, exists := config.Get(key)
 	if !exists {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Configuration key not found"})
 	}
@@ -42,7 +130,7 @@ func GetConfigValue(c echo.Context) error {
 }
 
 // @Summary		Mettre à jour la valeur d'une configuration
-// @Description	Mettre à jour la valeur d'une configuration
+// @Description	Mettre à jour la valeur d'une configuration. La valeur doit être un entier dans les bornes de la clé : strictement positive pour les durées et limites, de 0 à 10000 pour les commissions en points de base.
 // @ID				update-config
 // @Tags			Configurations
 // @Accept			json
@@ -56,7 +144,7 @@ func GetConfigValue(c echo.Context) error {
 // @Security		Bearer
 func UpdateConfigValue(c echo.Context) error {
 	key := c.Param("key")
-	if key != "CONCERTS_MAX_USERS_BEFORE_QUEUE" {
+	if _, ok := configurableKeys[key]; !ok {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid configuration key"})
 	}
 
@@ -69,27 +157,15 @@ func UpdateConfigValue(c echo.Context) error {
 	if !exists || value == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Value is required"})
 	}
-
-	err := config.LoadConfig("../../cmd/weezemaster/config/weezemaster.config")
+	parsed, err := parseConfigValue(key, value)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load configuration"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	value = strconv.FormatInt(parsed, 10)
 
-	config.Config[key] = value
-
-	err = writeConfigToFile("../../cmd/weezemaster/config/weezemaster.config", config.Config)
-	if err != nil {
+	if err := config.Set(key, value); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to write configuration to file"})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Configuration updated successfully"})
 }
-
-func writeConfigToFile(filePath string, config map[string]string) error {
-	var sb strings.Builder
-	for key, value := range config {
-		sb.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-	}
-
-	return os.WriteFile(filePath, []byte(sb.String()), 0644)
-}
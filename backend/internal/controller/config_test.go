@@ -0,0 +1,33 @@
+package controller
+
+import "testing"
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		key, raw string
+		want     int64
+		wantErr  bool
+	}{
+		{"PLATFORM_FEE_PRIMARY_BPS", "300", 300, false},
+		{"PLATFORM_FEE_PRIMARY_BPS", "0", 0, false},
+		{"PLATFORM_FEE_PRIMARY_BPS", "10000", 10000, false},
+		{"PLATFORM_FEE_PRIMARY_BPS", " 250 ", 250, false},
+		{"PLATFORM_FEE_PRIMARY_BPS", "10001", 0, true},
+		{"PLATFORM_FEE_RESALE_BPS", "-1", 0, true},
+		{"ORGANIZER_FEE_RESALE_BPS", "2OO", 0, true},
+		{"ORGANIZER_FEE_RESALE_BPS", "2.5", 0, true},
+		{"TICKET_HOLD_DURATION_MINUTES", "10", 10, false},
+		{"TICKET_HOLD_DURATION_MINUTES", "0", 0, true},
+		{"CONCERTS_MAX_USERS_BEFORE_QUEUE", "1000001", 0, true},
+		{"UNKNOWN_KEY", "1", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.raw, func(t *testing.T) {
+			got, err := parseConfigValue(tt.key, tt.raw)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseConfigValue(%q, %q) = %d, %v, want %d, error %v", tt.key, tt.raw, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
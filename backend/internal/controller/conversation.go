@@ -68,6 +68,11 @@ func GetConversation(c echo.Context) error {
 
 	concert := conversation.TicketListing.Ticket.ConcertCategory.Concert
 
+	reputations, err := reputationsFor(db, []uuid.UUID{conversation.BuyerId, conversation.SellerId})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
 	response := map[string]interface{}{
 		"ID":         conversation.ID,
 		"Messages":   conversation.Messages,
@@ -85,6 +90,9 @@ func GetConversation(c echo.Context) error {
 			"Image":    concert.Image,
 		},
 		"TicketListing": conversation.TicketListing,
+		// Chaque partie voit ainsi la réputation de l'autre
+		"BuyerReputation":  reputations[conversation.BuyerId],
+		"SellerReputation": reputations[conversation.SellerId],
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -151,7 +159,15 @@ func CreateConversation(c echo.Context) error {
 		Price:           ticketListing.Price,
 	}
 
-	if err := db.Create(conversation).Error; err != nil {
+	// La négociation s'ouvre sur une offre du vendeur au prix de l'annonce
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return err
+		}
+		_, err := createOffer(tx, conversation.ID, ticket.UserId, ticketListing.Price, nil)
+		return err
+	})
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create conversation: "+err.Error())
 	}
 
@@ -169,7 +185,7 @@ type CheckConversationResponse struct {
 	SellerId        string           `json:"seller_id"`
 	TicketListingId string           `json:"ticket_listing_id"`
 	Messages        []models.Message `json:"messages"`
-	Price           float64          `json:"price"`
+	Price           models.Money     `json:"price"`
 }
 
 // @Summary		Vérifie si une conversation existe
@@ -205,44 +221,3 @@ func CheckConversation(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]string{"ID": conversation.ID.String()})
 }
-
-// @Summary		Met à jour une conversation
-// @Description	Met à jour une conversation
-// @ID				update-conversation
-// @Tags			Conversations
-// @Accept			json
-// @Produce		json
-// @Param			id	path		string	true	"ID de la conversation"	format(uuid)
-// @Param			conversation	body		float64	true	"Price"
-// @Success		200				{object}	models.Conversation
-// @Failure		400				{object}	string
-// @Failure		401				{object}	string
-// @Failure		404				{object}	string
-// @Failure		500				{object}	string
-// @Router			/conversations/{id} [patch]
-// @Security		Bearer
-func UpdateConversation(c echo.Context) error {
-	db := database.GetDB()
-	id := c.Param("id")
-
-	var input struct {
-		Price float64 `json:"price"`
-	}
-
-	if err := c.Bind(&input); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Failed to bind input: "+err.Error())
-	}
-
-	var conversation models.Conversation
-	if err := db.Where("id = ?", id).First(&conversation).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-
-	conversation.Price = input.Price
-
-	if err := db.Save(&conversation).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-
-	return c.JSON(http.StatusOK, conversation)
-}
@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/ledger"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const escrowReleaseInterval = 5 * time.Minute
+
+// holdResaleFunds place sous séquestre la part du vendeur dans une revente, commissions déduites,
+// jusqu'à la date du concert
+func holdResaleFunds(tx *gorm.DB, sale *models.Sale, ticket *models.Ticket) error {
+	var concertCategory models.ConcertCategory
+	if err := tx.Preload("Concert").Where("id = ?", ticket.ConcertCategoryId).First(&concertCategory).Error; err != nil {
+		return err
+	}
+
+	rules, err := currentFeeRules()
+	if err != nil {
+		return err
+	}
+	_, _, sellerNet := ledger.ResaleSplit(sale.FinalPrice, rules.PlatformResaleBps, rules.OrganizerResaleBps)
+
+	escrow := models.Escrow{
+		ID:           uuid.New(),
+		SaleId:       sale.ID,
+		TicketId:     ticket.ID,
+		ConcertId:    concertCategory.ConcertId,
+		SellerId:     sale.SellerId,
+		BuyerId:      sale.BuyerId,
+		Amount:       sellerNet,
+		Status:       models.EscrowHeld,
+		ReleaseAfter: concertCategory.Concert.Date,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	return tx.Create(&escrow).Error
+}
+
+// releaseEscrows libère au profit des vendeurs les séquestres encore retenus de la requête donnée
+// et crédite leur solde dans le grand livre
+func releaseEscrows(tx *gorm.DB, query *gorm.DB, reason string) (int, error) {
+	var escrows []models.Escrow
+	if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", models.EscrowHeld).
+		Find(&escrows).Error; err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, escrow := range escrows {
+		if err := tx.Model(&models.Escrow{}).Where("id = ?", escrow.ID).
+			Updates(map[string]interface{}{
+				"status":         models.EscrowReleased,
+				"released_at":    now,
+				"release_reason": reason,
+				"updated_at":     now,
+			}).Error; err != nil {
+			return 0, err
+		}
+		if escrow.Amount.IsZero() {
+			continue
+		}
+		if _, err := ledger.Post(tx, ledger.Posting{
+			Kind:        models.LedgerEscrowRelease,
+			Reference:   escrow.ID.String(),
+			Description: "Escrow release for sale " + escrow.SaleId.String(),
+			Lines:       ledger.EscrowReleaseLines(escrow.SellerId, escrow.Amount),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(escrows), nil
+}
+
+// reverseEscrows rend aux acheteurs les séquestres encore retenus de la requête donnée et reporte
+// la part du vendeur sur le compte de l'acheteur dans le grand livre. Un séquestre déjà libéré
+// n'est pas modifié.
+func reverseEscrows(tx *gorm.DB, query *gorm.DB, reason string) error {
+	var escrows []models.Escrow
+	if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", models.EscrowHeld).
+		Find(&escrows).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, escrow := range escrows {
+		if err := tx.Model(&models.Escrow{}).Where("id = ?", escrow.ID).
+			Updates(map[string]interface{}{
+				"status":         models.EscrowReversed,
+				"reversed_at":    now,
+				"reverse_reason": reason,
+				"updated_at":     now,
+			}).Error; err != nil {
+			return err
+		}
+		if escrow.Amount.IsZero() {
+			continue
+		}
+		if _, err := ledger.Post(tx, ledger.Posting{
+			Kind:        models.LedgerEscrowReversal,
+			Reference:   escrow.ID.String(),
+			Description: "Escrow reversal for sale " + escrow.SaleId.String(),
+			Lines:       ledger.EscrowReversalLines(escrow.SellerId, escrow.BuyerId, escrow.Amount),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseTicketEscrows libère les séquestres des reventes successives d'un ticket scanné à l'entrée
+func releaseTicketEscrows(tx *gorm.DB, ticketID uuid.UUID) error {
+	_, err := releaseEscrows(tx, tx.Where("ticket_id = ?", ticketID), models.EscrowReleaseCheckIn)
+	return err
+}
+
+func reverseSaleEscrow(tx *gorm.DB, saleID uuid.UUID, reason string) error {
+	return reverseEscrows(tx, tx.Where("sale_id = ?", saleID), reason)
+}
+
+func reverseTicketEscrows(tx *gorm.DB, ticketID uuid.UUID, reason string) error {
+	return reverseEscrows(tx, tx.Where("ticket_id = ?", ticketID), reason)
+}
+
+func reverseConcertEscrows(tx *gorm.DB, concertID uuid.UUID) error {
+	return reverseEscrows(tx, tx.Where("concert_id = ?", concertID), models.EscrowReverseConcertCancelled)
+}
+
+// releaseDueEscrows libère les séquestres dont le concert a eu lieu. Les concerts annulés sont
+// ignorés : leurs séquestres sont annulés par le job d'annulation.
+func releaseDueEscrows() {
+	db := database.GetDB()
+
+	var released int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("release_after <= ?", time.Now()).
+			Where("concert_id IN (?)", tx.Model(&models.Concert{}).Select("id").Where("status <> ?", models.ConcertCancelled))
+		var err error
+		released, err = releaseEscrows(tx, query, models.EscrowReleaseConcertDate)
+		return err
+	})
+	if err != nil {
+		fmt.Println("Erreur lors de la libération des séquestres :", err)
+		return
+	}
+	if released > 0 {
+		fmt.Printf("%d séquestre(s) libéré(s)\n", released)
+	}
+}
+
+// StartEscrowReleaser lance en arrière-plan la libération périodique des séquestres arrivés à échéance
+func StartEscrowReleaser() {
+	ticker := time.NewTicker(escrowReleaseInterval)
+	go func() {
+		for range ticker.C {
+			releaseDueEscrows()
+		}
+	}()
+}
+
+type escrowTotal struct {
+	Status   string
+	Currency string
+	Amount   int64
+}
+
+// @Summary		Séquestre des reventes du vendeur
+// @Description	Renvoie les montants des reventes de l'utilisateur encore retenus jusqu'au concert (pending) et ceux déjà libérés (released), par devise, ainsi que le détail des séquestres
+// @ID				get-seller-escrow
+// @Tags			Ticket listing
+// @Produce		json
+// @Param			status	query		string	false	"Filtre sur le statut (held, released, reversed)"
+// @Success		200		{object}	map[string]interface{}
+// @Failure		401		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/user/escrow [get]
+// @Security		Bearer
+func GetSellerEscrow(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var totals []escrowTotal
+	if err := db.Model(&models.Escrow{}).
+		Select("status, currency, SUM(amount) AS amount").
+		Where("seller_id = ? AND status IN ?", userID, []string{models.EscrowHeld, models.EscrowReleased}).
+		Group("status, currency").
+		Scan(&totals).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to compute escrow totals"})
+	}
+
+	pending := []models.Money{}
+	released := []models.Money{}
+	for _, total := range totals {
+		amount := models.NewMoney(total.Amount, total.Currency)
+		if total.Status == models.EscrowHeld {
+			pending = append(pending, amount)
+		} else {
+			released = append(released, amount)
+		}
+	}
+
+	query := db.Preload("Concert").Where("seller_id = ?", userID)
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var escrows []models.Escrow
+	if err := query.Order("created_at DESC").Find(&escrows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve escrows"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"pending":  pending,
+		"released": released,
+		"escrows":  escrows,
+	})
+}
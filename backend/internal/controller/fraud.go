@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/fraud"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errFraudBlocked = errors.New("this action was blocked by our anti-fraud checks")
+var errFraudHeld = errors.New("this action is on hold pending a manual review")
+var errFraudReviewClosed = errors.New("this review has already been decided")
+
+// fraudEngine assemble les règles anti-fraude à partir de weezemaster.config
+func fraudEngine() *fraud.Engine {
+	return fraud.NewEngine(
+		fraud.MaxTicketsPerConcert{Limit: getMaxTicketsPerConcert()},
+		fraud.PurchaseVelocity{
+			Limit:  getConfigInt("FRAUD_MAX_PURCHASES_PER_WINDOW", 5),
+			Window: time.Duration(getConfigInt("FRAUD_PURCHASE_WINDOW_MINUTES", 60)) * time.Minute,
+		},
+		fraud.NewAccount{MinAge: time.Duration(getConfigInt("FRAUD_MIN_ACCOUNT_AGE_MINUTES", 10)) * time.Minute},
+		fraud.MinHoldBeforeResale{MinHold: getResaleMinHoldDuration()},
+	)
+}
+
+// Durée pendant laquelle une action approuvée par un administrateur peut être renouvelée
+func getFraudApprovalValidity() time.Duration {
+	return time.Duration(getConfigInt("FRAUD_APPROVAL_VALIDITY_HOURS", 24)) * time.Hour
+}
+
+// screenAttempt évalue une action avec le moteur anti-fraude. Une décision d'administrateur
+// déjà prise sur la même action prévaut : approuvée, elle laisse passer l'utilisateur ; refusée,
+// elle le bloque ; en attente, elle le fait patienter sans ouvrir un nouvel examen.
+func screenAttempt(db *gorm.DB, attempt fraud.Attempt) (*models.FraudReview, error) {
+	if attempt.At.IsZero() {
+		attempt.At = time.Now()
+	}
+
+	var previous models.FraudReview
+	err := db.Where("user_id = ? AND checkpoint = ? AND subject_id = ? AND status IN ?",
+		attempt.UserID, attempt.Checkpoint, attempt.SubjectID(),
+		[]string{models.FraudReviewPending, models.FraudReviewApproved, models.FraudReviewRejected}).
+		Order("created_at DESC").First(&previous).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		switch previous.Status {
+		case models.FraudReviewPending:
+			return &previous, errFraudHeld
+		case models.FraudReviewRejected:
+			return &previous, errFraudBlocked
+		case models.FraudReviewApproved:
+			if previous.ReviewedAt != nil && attempt.At.Sub(*previous.ReviewedAt) < getFraudApprovalValidity() {
+				return nil, nil
+			}
+		}
+	}
+
+	verdict, err := fraudEngine().Evaluate(db, attempt)
+	if err != nil {
+		return nil, err
+	}
+	if verdict.Outcome == models.FraudAllow {
+		return nil, nil
+	}
+
+	review := models.FraudReview{
+		ID:         uuid.New(),
+		UserId:     attempt.UserID,
+		Checkpoint: attempt.Checkpoint,
+		SubjectId:  attempt.SubjectID(),
+		ConcertId:  attempt.ConcertID,
+		Quantity:   attempt.Quantity,
+		Outcome:    verdict.Outcome,
+		Rule:       verdict.Rule,
+		Reason:     verdict.Reason,
+		Status:     models.FraudReviewPending,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if review.Quantity == 0 {
+		review.Quantity = 1
+	}
+	if verdict.Outcome == models.FraudBlock {
+		review.Status = models.FraudReviewBlocked
+	}
+	if err := db.Create(&review).Error; err != nil {
+		return nil, err
+	}
+	fmt.Printf("Anti-fraude : %s de l'utilisateur %s (%s) par la règle %s : %s\n", review.Outcome, review.UserId, review.Checkpoint, review.Rule, review.Reason)
+
+	if review.Status == models.FraudReviewBlocked {
+		return &review, errFraudBlocked
+	}
+	return &review, errFraudHeld
+}
+
+// reservationAttempt décrit la réservation de places d'une catégorie
+func reservationAttempt(db *gorm.DB, userID, concertCategoryID uuid.UUID, quantity int) (fraud.Attempt, error) {
+	var concertCategory models.ConcertCategory
+	if err := db.Where("id = ?", concertCategoryID).First(&concertCategory).Error; err != nil {
+		return fraud.Attempt{}, err
+	}
+	return fraud.Attempt{
+		Checkpoint:        models.FraudCheckpointReservation,
+		UserID:            userID,
+		ConcertID:         concertCategory.ConcertId,
+		ConcertCategoryID: concertCategory.ID,
+		Quantity:          quantity,
+	}, nil
+}
+
+// resalePurchaseAttempt décrit l'achat d'une annonce de revente
+func resalePurchaseAttempt(db *gorm.DB, userID, listingID uuid.UUID) (fraud.Attempt, error) {
+	var listing models.TicketListing
+	if err := db.Preload("Ticket.ConcertCategory").Where("id = ?", listingID).First(&listing).Error; err != nil {
+		return fraud.Attempt{}, err
+	}
+	return fraud.Attempt{
+		Checkpoint:        models.FraudCheckpointResalePurchase,
+		UserID:            userID,
+		ConcertID:         listing.Ticket.ConcertCategory.ConcertId,
+		ConcertCategoryID: listing.Ticket.ConcertCategoryId,
+		TicketID:          listing.TicketId,
+		ListingID:         listing.ID,
+		Quantity:          1,
+	}, nil
+}
+
+// fraudResponse écrit la réponse d'une action retenue (202, avec l'identifiant de l'examen) ou
+// bloquée (403) par screenAttempt
+func fraudResponse(c echo.Context, review *models.FraudReview, err error) error {
+	switch {
+	case errors.Is(err, errFraudHeld):
+		return c.JSON(http.StatusAccepted, map[string]string{
+			"status":   models.FraudHold,
+			"reviewId": review.ID.String(),
+			"error":    err.Error(),
+		})
+	case errors.Is(err, errFraudBlocked):
+		message := err.Error()
+		if review != nil && review.Reason != "" {
+			message += ": " + review.Reason
+		}
+		return c.JSON(http.StatusForbidden, map[string]string{"error": message})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to run anti-fraud checks"})
+}
+
+// notifyFraudDecision prévient l'utilisateur de la décision prise sur son action retenue
+func notifyFraudDecision(review *models.FraudReview) {
+	body := "Votre demande a été validée, vous pouvez la renouveler."
+	if review.Status == models.FraudReviewRejected {
+		body = "Votre demande n'a pas été acceptée après vérification."
+	}
+	data := map[string]string{
+		"fraud_review_id": review.ID.String(),
+		"status":          review.Status,
+	}
+	notification := map[string]string{
+		"title": "Vérification terminée",
+		"body":  body,
+	}
+	if err := SendFCMNotification(userTopic(review.UserId), data, notification); err != nil {
+		fmt.Printf("Failed to send fraud review notification to user %s: %v\n", review.UserId, err)
+	}
+}
+
+// decideFraudReview clôt un examen en attente
+func decideFraudReview(db *gorm.DB, reviewID string, status string, adminID *uuid.UUID, note string) (*models.FraudReview, error) {
+	var review models.FraudReview
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", reviewID).First(&review).Error; err != nil {
+			return err
+		}
+		if review.Status != models.FraudReviewPending {
+			return errFraudReviewClosed
+		}
+		now := time.Now()
+		review.Status = status
+		review.ReviewedById = adminID
+		review.ReviewedAt = &now
+		review.ReviewNote = note
+		review.UpdatedAt = now
+		return tx.Save(&review).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// @Summary		File d'examen anti-fraude
+// @Description	Liste les actions retenues ou bloquées par le moteur anti-fraude, les plus récentes en premier. Par défaut, seules les actions en attente d'examen sont renvoyées.
+// @ID				get-fraud-reviews
+// @Tags			Fraud
+// @Produce		json
+// @Param			status	query		string	false	"pending (par défaut), approved, rejected ou blocked"
+// @Success		200		{array}		models.FraudReview
+// @Failure		401		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/fraud/reviews [get]
+// @Security		Bearer
+func GetFraudReviews(c echo.Context) error {
+	db := database.GetDB()
+
+	status := c.QueryParam("status")
+	if status == "" {
+		status = models.FraudReviewPending
+	}
+
+	var reviews []models.FraudReview
+	if err := db.Preload("User").Where("status = ?", status).Order("created_at DESC").Find(&reviews).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve fraud reviews"})
+	}
+
+	return c.JSON(http.StatusOK, reviews)
+}
+
+func decideFraudReviewHandler(c echo.Context, status string) error {
+	db := database.GetDB()
+
+	var reqBody struct {
+		Note string `json:"note"`
+	}
+	_ = c.Bind(&reqBody)
+
+	adminID := adminIDFromContext(c)
+	review, err := decideFraudReview(db, c.Param("id"), status, adminID, reqBody.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Fraud review not found"})
+		case errors.Is(err, errFraudReviewClosed):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update fraud review"})
+	}
+
+	go notifyFraudDecision(review)
+
+	c.Logger().Infof("event=FraudReviewDecided review_id=%s user_id=%s status=%s timestamp=%s", review.ID, review.UserId, review.Status, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, review)
+}
+
+// @Summary		Approuve une action retenue
+// @Description	Autorise l'utilisateur à renouveler l'action retenue pendant FRAUD_APPROVAL_VALIDITY_HOURS heures
+// @ID				approve-fraud-review
+// @Tags			Fraud
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID de l'examen"	format(uuid)
+// @Param			body	body		object	false	"note (optionnelle)"
+// @Success		200		{object}	models.FraudReview
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/fraud/reviews/{id}/approve [post]
+// @Security		Bearer
+func ApproveFraudReview(c echo.Context) error {
+	return decideFraudReviewHandler(c, models.FraudReviewApproved)
+}
+
+// @Summary		Refuse une action retenue
+// @Description	Refuse l'action retenue ; les tentatives suivantes de l'utilisateur sur le même objet sont bloquées
+// @ID				reject-fraud-review
+// @Tags			Fraud
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID de l'examen"	format(uuid)
+// @Param			body	body		object	false	"note (optionnelle)"
+// @Success		200		{object}	models.FraudReview
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/fraud/reviews/{id}/reject [post]
+// @Security		Bearer
+func RejectFraudReview(c echo.Context) error {
+	return decideFraudReviewHandler(c, models.FraudReviewRejected)
+}
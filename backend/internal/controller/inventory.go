@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"errors"
+	"time"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errConcertCancelled = errors.New("concert has been cancelled")
+
+// lockConcertCategory récupère une catégorie de concert en verrouillant sa ligne (SELECT ... FOR UPDATE)
+// jusqu'à la fin de la transaction
+func lockConcertCategory(tx *gorm.DB, concertCategoryID uuid.UUID) (*models.ConcertCategory, error) {
+	var concertCategory models.ConcertCategory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", concertCategoryID).
+		First(&concertCategory).Error; err != nil {
+		return nil, err
+	}
+	return &concertCategory, nil
+}
+
+// reserveInventory décrémente de façon atomique le stock d'une catégorie de concert.
+// La ligne est verrouillée le temps de la transaction et les places bloquées par les holds
+// des autres utilisateurs sont décomptées ; errNoTicketsAvailable est renvoyée si la catégorie
+// est épuisée.
+func reserveInventory(tx *gorm.DB, concertCategoryID, userID uuid.UUID, quantity int) (*models.ConcertCategory, error) {
+	concertCategory, err := lockConcertCategory(tx, concertCategoryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConcertOpen(tx, concertCategory, userID); err != nil {
+		return nil, err
+	}
+
+	held, err := heldTickets(tx, concertCategoryID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if concertCategory.SoldTickets+held+quantity > concertCategory.AvailableTickets {
+		return nil, errNoTicketsAvailable
+	}
+
+	// La condition sur sold_tickets reste une garantie même si le verrou venait à manquer
+	res := tx.Model(&models.ConcertCategory{}).
+		Where("id = ? AND COALESCE(sold_tickets, 0) + ? <= available_tickets", concertCategoryID, quantity).
+		Updates(map[string]interface{}{
+			"sold_tickets": gorm.Expr("COALESCE(sold_tickets, 0) + ?", quantity),
+			"updated_at":   time.Now(),
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errNoTicketsAvailable
+	}
+
+	concertCategory.SoldTickets += quantity
+	if err := syncSoldOut(tx, &concertCategory.ConcertId); err != nil {
+		return nil, err
+	}
+	return concertCategory, nil
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"weezemaster/internal/models"
+	"weezemaster/internal/testdb"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TestReserveInventoryNeverOversells lance plus de réservations concurrentes que de places et
+// vérifie que le stock vendu ne dépasse jamais la jauge de la catégorie
+func TestReserveInventoryNeverOversells(t *testing.T) {
+	const capacity = 10
+	const buyers = 50
+
+	db := testdb.Open(t, &models.Concert{}, &models.ConcertCategory{}, &models.TicketHold{})
+
+	concert := models.Concert{
+		ID:             uuid.New(),
+		Name:           "Concert",
+		Location:       "Paris",
+		Date:           time.Now().AddDate(0, 1, 0),
+		OrganizationId: uuid.New(),
+		ArtistId:       uuid.New(),
+		Status:         models.ConcertOnSale,
+	}
+	if err := db.Create(&concert).Error; err != nil {
+		t.Fatalf("create concert: %v", err)
+	}
+	concertCategory := models.ConcertCategory{
+		ID:               uuid.New(),
+		ConcertId:        concert.ID,
+		CategoryId:       1,
+		AvailableTickets: capacity,
+		Price:            models.NewMoney(5000, models.DefaultCurrency),
+	}
+	if err := db.Omit("Concert", "Category").Create(&concertCategory).Error; err != nil {
+		t.Fatalf("create concert category: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, soldOut := 0, 0
+	start := make(chan struct{})
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := reserveInventory(tx, concertCategory.ID, uuid.New(), 1)
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, errNoTicketsAvailable):
+				soldOut++
+			default:
+				t.Errorf("reserveInventory: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var stored models.ConcertCategory
+	if err := db.Where("id = ?", concertCategory.ID).First(&stored).Error; err != nil {
+		t.Fatalf("load concert category: %v", err)
+	}
+	if stored.SoldTickets > capacity {
+		t.Fatalf("sold %d tickets for a capacity of %d", stored.SoldTickets, capacity)
+	}
+	if stored.SoldTickets != reserved {
+		t.Errorf("sold_tickets = %d, but %d reservations succeeded", stored.SoldTickets, reserved)
+	}
+	if reserved != capacity || soldOut != buyers-capacity {
+		t.Errorf("got %d reservations and %d sold out errors, want %d and %d", reserved, soldOut, capacity, buyers-capacity)
+	}
+
+	var storedConcert models.Concert
+	if err := db.Select("id", "status").Where("id = ?", concert.ID).First(&storedConcert).Error; err != nil {
+		t.Fatalf("load concert: %v", err)
+	}
+	if storedConcert.Status != models.ConcertSoldOut {
+		t.Errorf("concert status = %q, want %q", storedConcert.Status, models.ConcertSoldOut)
+	}
+}
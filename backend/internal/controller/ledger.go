@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/ledger"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var errInsufficientBalance = errors.New("insufficient balance for this payout")
+
+// currentFeeRules lit les commissions en vigueur dans weezemaster.config. Une commission absente
+// ou invalide fait échouer l'écriture en cours plutôt que de l'enregistrer sans commission.
+func currentFeeRules() (ledger.FeeRules, error) {
+	var rules ledger.FeeRules
+	for key, bps := range map[string]*int64{
+		"PLATFORM_FEE_PRIMARY_BPS": &rules.PlatformPrimaryBps,
+		"PLATFORM_FEE_RESALE_BPS":  &rules.PlatformResaleBps,
+		"ORGANIZER_FEE_RESALE_BPS": &rules.OrganizerResaleBps,
+	} {
+		value, err := getConfigBasisPoints(key)
+		if err != nil {
+			return ledger.FeeRules{}, err
+		}
+		*bps = value
+	}
+	return rules, nil
+}
+
+// concertOrganizationID renvoie l'organisation qui vend une catégorie de concert
+func concertOrganizationID(tx *gorm.DB, concertCategoryID uuid.UUID) (uuid.UUID, error) {
+	var concertCategory models.ConcertCategory
+	if err := tx.Preload("Concert").Where("id = ?", concertCategoryID).First(&concertCategory).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return concertCategory.Concert.OrganizationId, nil
+}
+
+// recordPaymentInLedger passe les écritures d'un paiement honoré : vente de l'organisateur,
+// commande ou revente, commissions comprises
+func recordPaymentInLedger(tx *gorm.DB, payment *models.Payment) error {
+	rules, err := currentFeeRules()
+	if err != nil {
+		return err
+	}
+	posting := ledger.Posting{Reference: payment.ID.String()}
+
+	switch payment.ItemType {
+	case models.PaymentItemConcertCategory:
+		organizationID, err := concertOrganizationID(tx, payment.ItemId)
+		if err != nil {
+			return err
+		}
+		posting.Kind = models.LedgerPrimarySale
+		posting.Description = "Ticket sale"
+		posting.PlatformFeeBps = rules.PlatformPrimaryBps
+		posting.Lines = ledger.PrimarySaleLines(payment.UserId, organizationID, payment.Amount, rules.PlatformPrimaryBps)
+	case models.PaymentItemOrder:
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", payment.ItemId).Find(&items).Error; err != nil {
+			return err
+		}
+		posting.Kind = models.LedgerPrimarySale
+		posting.Description = "Order " + payment.ItemId.String()
+		posting.PlatformFeeBps = rules.PlatformPrimaryBps
+		for _, item := range items {
+			organizationID, err := concertOrganizationID(tx, item.ConcertCategoryId)
+			if err != nil {
+				return err
+			}
+			amount := item.UnitPrice.Mul(int64(item.Quantity))
+			posting.Lines = append(posting.Lines, ledger.PrimarySaleLines(payment.UserId, organizationID, amount, rules.PlatformPrimaryBps)...)
+		}
+	case models.PaymentItemTicketListing, models.PaymentItemConversation:
+		var sale models.Sale
+		if err := tx.Preload("TicketSold.Ticket").Where("id = ?", payment.SaleId).First(&sale).Error; err != nil {
+			return err
+		}
+		organizationID, err := concertOrganizationID(tx, sale.TicketSold.Ticket.ConcertCategoryId)
+		if err != nil {
+			return err
+		}
+		posting.Kind = models.LedgerResale
+		posting.Description = "Resale " + sale.ID.String()
+		posting.PlatformFeeBps = rules.PlatformResaleBps
+		posting.OrganizerFeeBps = rules.OrganizerResaleBps
+		posting.Lines = ledger.ResaleLines(payment.UserId, sale.SellerId, organizationID, payment.Amount,
+			rules.PlatformResaleBps, rules.OrganizerResaleBps, models.LedgerAccountSellerEscrow)
+	default:
+		return fmt.Errorf("unknown payment item type %q", payment.ItemType)
+	}
+
+	_, err = ledger.Post(tx, posting)
+	return err
+}
+
+// refundedSellerAccount renvoie le compte sur lequel reprendre la part du vendeur d'une revente
+// remboursée : son solde si le séquestre a été libéré, le compte de l'acheteur si l'annulation du
+// séquestre la lui a déjà rendue, sinon le séquestre
+func refundedSellerAccount(tx *gorm.DB, saleID uuid.UUID) (string, error) {
+	var escrow models.Escrow
+	err := tx.Where("sale_id = ?", saleID).First(&escrow).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.LedgerAccountSellerEscrow, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch escrow.Status {
+	case models.EscrowReleased:
+		return models.LedgerAccountSeller, nil
+	case models.EscrowReversed:
+		_, err := ledger.FindTransaction(tx, models.LedgerEscrowReversal, escrow.ID.String())
+		if err == nil {
+			return models.LedgerAccountBuyer, nil
+		}
+		// Séquestre annulé avant que son annulation ne soit passée au grand livre
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+	return models.LedgerAccountSellerEscrow, nil
+}
+
+// recordRefundInLedger contrepasse la part remboursée de l'écriture du paiement, aux taux de
+// commission appliqués lors de la vente. Un paiement antérieur au grand livre n'a rien à contrepasser.
+func recordRefundInLedger(tx *gorm.DB, request *models.RefundRequest, ticket *models.Ticket) error {
+	var payment models.Payment
+	if err := tx.Where("id = ?", request.PaymentId).First(&payment).Error; err != nil {
+		return err
+	}
+
+	kind := models.LedgerPrimarySale
+	if request.SaleId != nil {
+		kind = models.LedgerResale
+	}
+	original, err := ledger.FindTransaction(tx, kind, payment.ID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	organizationID, err := concertOrganizationID(tx, ticket.ConcertCategoryId)
+	if err != nil {
+		return err
+	}
+
+	var lines []ledger.Line
+	if request.SaleId != nil {
+		var sale models.Sale
+		if err := tx.Where("id = ?", request.SaleId).First(&sale).Error; err != nil {
+			return err
+		}
+		sellerAccount, err := refundedSellerAccount(tx, sale.ID)
+		if err != nil {
+			return err
+		}
+		lines = ledger.ResaleRefundLines(payment.UserId, sale.SellerId, organizationID, request.Amount,
+			original.PlatformFeeBps, original.OrganizerFeeBps, sellerAccount)
+	} else {
+		lines = ledger.Reverse(ledger.PrimarySaleLines(payment.UserId, organizationID, request.Amount, original.PlatformFeeBps))
+	}
+
+	_, err = ledger.Post(tx, ledger.Posting{
+		Kind:            models.LedgerRefund,
+		Reference:       request.ID.String(),
+		Description:     "Refund of payment " + payment.ID.String(),
+		PlatformFeeBps:  original.PlatformFeeBps,
+		OrganizerFeeBps: original.OrganizerFeeBps,
+		Lines:           lines,
+	})
+	return err
+}
+
+// @Summary		Rapport de rapprochement du grand livre
+// @Description	Vérifie que les écritures s'équilibrent (total nul par devise) et compare la trésorerie du grand livre aux paiements encaissés
+// @ID				get-ledger-reconciliation
+// @Tags			Ledger
+// @Produce		json
+// @Success		200	{object}	ledger.Report
+// @Failure		500	{object}	map[string]string
+// @Router			/ledger/reconciliation [get]
+// @Security		Bearer
+func GetLedgerReconciliation(c echo.Context) error {
+	db := database.GetDB()
+
+	report, err := ledger.Reconcile(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to build reconciliation report"})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// @Summary		Enregistre un versement
+// @Description	Enregistre le versement à un vendeur ou à une organisation d'une partie de son solde
+// @ID				create-ledger-payout
+// @Tags			Ledger
+// @Accept			json
+// @Produce		json
+// @Param			body	body		object	true	"accountType (seller ou organization), ownerId et amount"
+// @Success		201		{object}	models.LedgerTransaction
+// @Failure		400		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/ledger/payouts [post]
+// @Security		Bearer
+func CreatePayout(c echo.Context) error {
+	db := database.GetDB()
+
+	var reqBody struct {
+		AccountType string       `json:"accountType"`
+		OwnerId     uuid.UUID    `json:"ownerId"`
+		Amount      models.Money `json:"amount"`
+	}
+	if err := c.Bind(&reqBody); err != nil || reqBody.OwnerId == uuid.Nil || !reqBody.Amount.IsPositive() {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if reqBody.AccountType != models.LedgerAccountSeller && reqBody.AccountType != models.LedgerAccountOrganization {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Payouts are only possible to sellers and organizations"})
+	}
+
+	var transaction *models.LedgerTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		available, err := ledger.AvailableBalance(tx, reqBody.AccountType, reqBody.OwnerId, reqBody.Amount.Currency)
+		if err != nil {
+			return err
+		}
+		if available.Amount < reqBody.Amount.Amount {
+			return errInsufficientBalance
+		}
+
+		transaction, err = ledger.Post(tx, ledger.Posting{
+			Kind:        models.LedgerPayout,
+			Reference:   uuid.New().String(),
+			Description: "Payout to " + reqBody.AccountType + " " + reqBody.OwnerId.String(),
+			Lines:       ledger.PayoutLines(reqBody.AccountType, reqBody.OwnerId, reqBody.Amount),
+		})
+		return err
+	})
+	if errors.Is(err, errInsufficientBalance) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record payout"})
+	}
+
+	c.Logger().Infof("event=PayoutRecorded account_type=%s owner_id=%s amount=%s transaction_id=%s timestamp=%s", reqBody.AccountType, reqBody.OwnerId, reqBody.Amount, transaction.ID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, transaction)
+}
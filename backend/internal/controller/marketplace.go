@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	marketplaceDefaultPageSize = 20
+	marketplaceMaxPageSize     = 100
+)
+
+// marketplaceSorts associe les tris acceptés par la recherche à leur clause ORDER BY
+var marketplaceSorts = map[string]string{
+	"price_asc":  "ticket_listings.price_amount ASC, ticket_listings.created_at DESC",
+	"price_desc": "ticket_listings.price_amount DESC, ticket_listings.created_at DESC",
+	"recent":     "ticket_listings.created_at DESC",
+}
+
+// MarketplacePage est une page de résultats de la recherche d'annonces
+type MarketplacePage struct {
+	Items    []models.TicketListing `json:"items"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"pageSize"`
+	Total    int64                  `json:"total"`
+}
+
+// optionalUserID renvoie l'utilisateur authentifié s'il y a un token valide, uuid.Nil sinon.
+// Sert aux routes publiques qui adaptent leur réponse à l'appelant.
+func optionalUserID(c echo.Context) uuid.UUID {
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return uuid.Nil
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return uuid.Nil
+	}
+	id, ok := claims["id"].(string)
+	if !ok {
+		return uuid.Nil
+	}
+	userID, _ := uuid.Parse(id)
+	return userID
+}
+
+// parseMarketplaceDate accepte une date seule (2006-01-02) ou une date RFC 3339. Une date seule
+// utilisée comme borne haute couvre toute la journée.
+func parseMarketplaceDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+func parsePositiveQueryInt(c echo.Context, name string, fallback int) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s value", name)
+	}
+	return n, nil
+}
+
+// marketplaceQuery construit la recherche des annonces disponibles à partir des filtres de la
+// requête. Les tickets de l'appelant sont exclus, comme sur la page d'un concert.
+func marketplaceQuery(c echo.Context, db *gorm.DB, userID uuid.UUID) (*gorm.DB, error) {
+	query := db.Model(&models.TicketListing{}).
+		Joins("JOIN tickets ON tickets.id = ticket_listings.ticket_id").
+		Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+		Joins("JOIN concerts ON concerts.id = concert_categories.concert_id").
+		Where("ticket_listings.status = ?", models.ListingAvailable).
+		Where("concerts.status <> ? AND concerts.date > ?", models.ConcertCancelled, time.Now())
+	if userID != uuid.Nil {
+		query = query.Where("tickets.user_id <> ?", userID)
+	}
+
+	for param, column := range map[string]string{"concertId": "concerts.id", "artistId": "concerts.artist_id"} {
+		if value := c.QueryParam(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value", param)
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if value := c.QueryParam("categoryId"); value != "" {
+		categoryID, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid categoryId value")
+		}
+		query = query.Where("concert_categories.category_id = ?", categoryID)
+	}
+
+	if value := c.QueryParam("dateFrom"); value != "" {
+		from, err := parseMarketplaceDate(value, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dateFrom value")
+		}
+		query = query.Where("concerts.date >= ?", from)
+	}
+	if value := c.QueryParam("dateTo"); value != "" {
+		to, err := parseMarketplaceDate(value, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dateTo value")
+		}
+		query = query.Where("concerts.date <= ?", to)
+	}
+	if location := strings.TrimSpace(c.QueryParam("location")); location != "" {
+		query = query.Where("concerts.location ILIKE ?", "%"+location+"%")
+	}
+
+	// Les bornes de prix sont en unités mineures, dans la devise demandée (EUR par défaut)
+	minPrice, maxPrice := c.QueryParam("minPrice"), c.QueryParam("maxPrice")
+	if minPrice != "" || maxPrice != "" {
+		currency := models.DefaultCurrency
+		if value := c.QueryParam("currency"); value != "" {
+			currency = strings.ToUpper(value)
+		}
+		query = query.Where("ticket_listings.price_currency = ?", currency)
+		if minPrice != "" {
+			amount, err := strconv.ParseInt(minPrice, 10, 64)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("invalid minPrice value")
+			}
+			query = query.Where("ticket_listings.price_amount >= ?", amount)
+		}
+		if maxPrice != "" {
+			amount, err := strconv.ParseInt(maxPrice, 10, 64)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("invalid maxPrice value")
+			}
+			query = query.Where("ticket_listings.price_amount <= ?", amount)
+		}
+	}
+
+	return query, nil
+}
+
+// @Summary		Recherche d'annonces de revente
+// @Description	Recherche paginée parmi toutes les annonces disponibles des concerts à venir. Les tickets de l'appelant authentifié sont exclus. Les prix sont exprimés en unités mineures.
+// @ID				search-ticket-listings
+// @Tags			Ticket listing
+// @Produce		json
+// @Param			concertId	query		string	false	"ID du concert"	format(uuid)
+// @Param			artistId	query		string	false	"ID de l'artiste"	format(uuid)
+// @Param			categoryId	query		int		false	"ID de la catégorie"
+// @Param			dateFrom	query		string	false	"Concerts à partir de cette date (2006-01-02 ou RFC 3339)"
+// @Param			dateTo		query		string	false	"Concerts jusqu'à cette date (2006-01-02 ou RFC 3339)"
+// @Param			location	query		string	false	"Lieu du concert (recherche partielle)"
+// @Param			minPrice	query		int		false	"Prix minimum en unités mineures"
+// @Param			maxPrice	query		int		false	"Prix maximum en unités mineures"
+// @Param			currency	query		string	false	"Devise des bornes de prix (EUR par défaut)"
+// @Param			sort		query		string	false	"Tri : price_asc, price_desc ou recent (par défaut)"
+// @Param			page		query		int		false	"Numéro de page, à partir de 1"
+// @Param			pageSize	query		int		false	"Taille de page (20 par défaut, 100 au maximum)"
+// @Success		200			{object}	MarketplacePage
+// @Failure		400			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/ticketlisting/search [get]
+func SearchTicketListings(c echo.Context) error {
+	db := database.GetDB()
+	userID := optionalUserID(c)
+
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "recent"
+	}
+	order, ok := marketplaceSorts[sort]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid sort value"})
+	}
+	page, err := parsePositiveQueryInt(c, "page", 1)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	pageSize, err := parsePositiveQueryInt(c, "pageSize", marketplaceDefaultPageSize)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if pageSize > marketplaceMaxPageSize {
+		pageSize = marketplaceMaxPageSize
+	}
+
+	query, err := marketplaceQuery(c, db, userID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search ticket listings"})
+	}
+
+	listings := []models.TicketListing{}
+	if err := query.
+		Preload("Ticket.ConcertCategory.Category").
+		Preload("Ticket.ConcertCategory.Concert.Artist").
+		Order(order).
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&listings).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search ticket listings"})
+	}
+
+	items := make([]*models.TicketListing, len(listings))
+	for i := range listings {
+		items[i] = &listings[i]
+	}
+	if err := attachSellerReputations(db, items); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search ticket listings"})
+	}
+
+	return c.JSON(http.StatusOK, MarketplacePage{
+		Items:    listings,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	})
+}
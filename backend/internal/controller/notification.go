@@ -7,6 +7,8 @@ import (
 	"weezemaster/internal/config"
 
 	"firebase.google.com/go/messaging"
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
 )
 
 func sanitizeTopicName(topic string) string {
@@ -37,3 +39,25 @@ func SendFCMNotification(topic string, data map[string]string, notification map[
 	fmt.Printf("Successfully sent message: %s\n", response)
 	return nil
 }
+
+// userTopic renvoie le topic FCM propre à un utilisateur
+func userTopic(userID uuid.UUID) string {
+	return "user_" + userID.String()
+}
+
+// sendEmail envoie un email HTML depuis l'adresse de contact
+func sendEmail(to, subject, html string) error {
+	client := resend.NewClient(config.ResendApiKey)
+
+	params := &resend.SendEmailRequest{
+		From:    config.ContactEmail,
+		To:      []string{to},
+		Html:    html,
+		Subject: subject,
+	}
+
+	if _, err := client.Emails.Send(params); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,465 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNotConversationParty = errors.New("only the buyer or the seller can negotiate in this conversation")
+var errOfferNotYourTurn = errors.New("you cannot respond to your own offer")
+var errOfferPendingFromCounterpart = errors.New("respond to the pending offer first")
+var errOfferNotPending = errors.New("offer is no longer pending")
+var errOfferAboveCap = errors.New("offer exceeds the original ticket price")
+var errInvalidOfferPrice = errors.New("offer price must be positive")
+var errNegotiationClosed = errors.New("an offer has already been accepted in this conversation")
+var errNoAcceptedOffer = errors.New("no accepted offer for this conversation")
+
+// Réponses possibles à une offre
+const (
+	offerAccept  = "accept"
+	offerReject  = "reject"
+	offerCounter = "counter"
+)
+
+func getOfferExpiry() time.Duration {
+	return time.Duration(getConfigInt("OFFER_EXPIRY_HOURS", 24)) * time.Hour
+}
+
+// offerErrorStatus traduit une erreur de négociation en code HTTP
+func offerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNotConversationParty):
+		return http.StatusForbidden
+	case errors.Is(err, errOfferAboveCap), errors.Is(err, errInvalidOfferPrice), errors.Is(err, models.ErrCurrencyMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, errOfferNotYourTurn), errors.Is(err, errOfferPendingFromCounterpart), errors.Is(err, errOfferNotPending),
+		errors.Is(err, errNegotiationClosed), errors.Is(err, errListingNotAvailable):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// lockNegotiation verrouille la conversation pour sérialiser les offres, vérifie que l'utilisateur
+// en est l'acheteur ou le vendeur et fait expirer l'offre en attente si son délai est dépassé
+func lockNegotiation(tx *gorm.DB, conversationID, userID uuid.UUID) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", conversationID).First(&conversation).Error; err != nil {
+		return nil, err
+	}
+	if userID != conversation.BuyerId && userID != conversation.SellerId {
+		return nil, errNotConversationParty
+	}
+
+	var ticketListing models.TicketListing
+	if err := tx.Where("id = ?", conversation.TicketListingId).First(&ticketListing).Error; err != nil {
+		return nil, err
+	}
+	if ticketListing.Status != models.ListingAvailable {
+		return nil, errListingNotAvailable
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.Offer{}).
+		Where("conversation_id = ? AND status = ? AND expires_at <= ?", conversation.ID, models.OfferPending, now).
+		Updates(map[string]interface{}{"status": models.OfferExpired, "updated_at": now}).Error; err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// checkOfferPrice vérifie qu'une offre ne dépasse pas le prix d'origine du ticket
+func checkOfferPrice(tx *gorm.DB, conversation *models.Conversation, price models.Money) error {
+	if !price.IsPositive() {
+		return errInvalidOfferPrice
+	}
+
+	var ticketListing models.TicketListing
+	if err := tx.Preload("Ticket.ConcertCategory").Where("id = ?", conversation.TicketListingId).First(&ticketListing).Error; err != nil {
+		return err
+	}
+	cmp, err := price.Cmp(ticketListing.Ticket.ConcertCategory.Price)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return errOfferAboveCap
+	}
+	return nil
+}
+
+func createOffer(tx *gorm.DB, conversationID, proposerID uuid.UUID, price models.Money, counterOf *uuid.UUID) (*models.Offer, error) {
+	offer := models.Offer{
+		ID:             uuid.New(),
+		ConversationId: conversationID,
+		ProposerId:     proposerID,
+		Price:          price,
+		Status:         models.OfferPending,
+		CounterOfId:    counterOf,
+		ExpiresAt:      time.Now().Add(getOfferExpiry()),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if err := tx.Create(&offer).Error; err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// makeOffer propose un prix dans une conversation. L'offre remplace celle que l'utilisateur avait
+// encore en attente ; si l'offre en attente vient de l'autre partie, il doit d'abord y répondre.
+func makeOffer(db *gorm.DB, conversationID, userID uuid.UUID, price models.Money) (*models.Offer, error) {
+	var offer *models.Offer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		conversation, err := lockNegotiation(tx, conversationID, userID)
+		if err != nil {
+			return err
+		}
+
+		var accepted int64
+		if err := tx.Model(&models.Offer{}).Where("conversation_id = ? AND status = ?", conversation.ID, models.OfferAccepted).Count(&accepted).Error; err != nil {
+			return err
+		}
+		if accepted > 0 {
+			return errNegotiationClosed
+		}
+
+		var pending models.Offer
+		err = tx.Where("conversation_id = ? AND status = ?", conversation.ID, models.OfferPending).First(&pending).Error
+		if err == nil {
+			if pending.ProposerId != userID {
+				return errOfferPendingFromCounterpart
+			}
+			pending.Status = models.OfferWithdrawn
+			pending.UpdatedAt = time.Now()
+			if err := tx.Save(&pending).Error; err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := checkOfferPrice(tx, conversation, price); err != nil {
+			return err
+		}
+		offer, err = createOffer(tx, conversation.ID, userID, price, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// respondToOffer accepte, refuse ou contre une offre en attente de l'autre partie. Une offre
+// acceptée fixe le prix de la conversation ; une contre-offre renvoie la nouvelle offre.
+func respondToOffer(db *gorm.DB, offerID, userID uuid.UUID, action string, counterPrice models.Money) (*models.Offer, error) {
+	var result *models.Offer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var offer models.Offer
+		if err := tx.Where("id = ?", offerID).First(&offer).Error; err != nil {
+			return err
+		}
+		conversation, err := lockNegotiation(tx, offer.ConversationId, userID)
+		if err != nil {
+			return err
+		}
+		// Relit l'offre après le verrou : elle a pu expirer ou recevoir une réponse entre-temps
+		if err := tx.Where("id = ?", offerID).First(&offer).Error; err != nil {
+			return err
+		}
+		if offer.Status != models.OfferPending {
+			return errOfferNotPending
+		}
+		if offer.ProposerId == userID {
+			return errOfferNotYourTurn
+		}
+
+		now := time.Now()
+		offer.RespondedAt = &now
+		offer.UpdatedAt = now
+
+		switch action {
+		case offerAccept:
+			offer.Status = models.OfferAccepted
+			conversation.Price = offer.Price
+			conversation.UpdatedAt = now
+			if err := tx.Save(conversation).Error; err != nil {
+				return err
+			}
+		case offerReject:
+			offer.Status = models.OfferRejected
+		case offerCounter:
+			if err := checkOfferPrice(tx, conversation, counterPrice); err != nil {
+				return err
+			}
+			offer.Status = models.OfferCountered
+		}
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		result = &offer
+		if action == offerCounter {
+			result, err = createOffer(tx, conversation.ID, userID, counterPrice, &offer.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// acceptedOffer renvoie l'offre acceptée d'une conversation, seul prix auquel le ticket peut être acheté
+func acceptedOffer(tx *gorm.DB, conversationID uuid.UUID) (*models.Offer, error) {
+	var offer models.Offer
+	if err := tx.Where("conversation_id = ? AND status = ?", conversationID, models.OfferAccepted).First(&offer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNoAcceptedOffer
+		}
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// broadcastOffer prévient les participants connectés au chat d'un changement d'offre
+func broadcastOffer(offer *models.Offer) {
+	message, err := json.Marshal(PriceUpdatePayload{
+		ConversationID: offer.ConversationId.String(),
+		NewPrice:       offer.Price,
+		OfferID:        offer.ID.String(),
+		ProposerID:     offer.ProposerId.String(),
+		Status:         offer.Status,
+	})
+	if err != nil {
+		fmt.Println("Erreur lors de la sérialisation de l'offre: ", err)
+		return
+	}
+	broadcastMessage(offer.ConversationId.String(), message)
+}
+
+// authenticatedUserID lit l'identifiant de l'utilisateur authentifié. En cas d'échec, la réponse
+// d'erreur est déjà écrite et l'identifiant renvoyé est uuid.Nil.
+func authenticatedUserID(c echo.Context) (uuid.UUID, error) {
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return uuid.Nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return uuid.Nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userIdFromToken, ok := claims["id"].(string)
+	if !ok {
+		return uuid.Nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	userID, err := uuid.Parse(userIdFromToken)
+	if err != nil {
+		return uuid.Nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid user ID format"})
+	}
+	return userID, nil
+}
+
+// @Summary		Offres d'une conversation
+// @Description	Renvoie l'historique des offres d'une conversation, la plus récente en premier
+// @ID				get-conversation-offers
+// @Tags			Conversations
+// @Produce		json
+// @Param			id	path		string	true	"ID de la conversation"	format(uuid)
+// @Success		200	{array}		models.Offer
+// @Failure		401	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/conversations/{id}/offers [get]
+// @Security		Bearer
+func GetConversationOffers(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	var conversation models.Conversation
+	if err := db.Where("id = ?", c.Param("id")).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if userID != conversation.BuyerId && userID != conversation.SellerId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": errNotConversationParty.Error()})
+	}
+
+	var offers []models.Offer
+	if err := db.Where("conversation_id = ?", conversation.ID).Order("created_at DESC").Find(&offers).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve offers"})
+	}
+
+	// Les offres échues sont présentées comme expirées même si personne n'y a encore touché
+	for i := range offers {
+		if offers[i].Status == models.OfferPending && !offers[i].ExpiresAt.After(time.Now()) {
+			offers[i].Status = models.OfferExpired
+		}
+	}
+
+	return c.JSON(http.StatusOK, offers)
+}
+
+// @Summary		Fait une offre
+// @Description	Propose un prix dans une conversation de revente. Le prix ne peut pas dépasser le prix d'origine du ticket. Une offre en attente de l'autre partie doit d'abord recevoir une réponse.
+// @ID				create-offer
+// @Tags			Conversations
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string			true	"ID de la conversation"	format(uuid)
+// @Param			body	body		models.Money	true	"price"
+// @Success		201		{object}	models.Offer
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/conversations/{id}/offers [post]
+// @Security		Bearer
+func CreateOffer(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	conversationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid conversation ID"})
+	}
+
+	var reqBody struct {
+		Price models.Money `json:"price"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	offer, err := makeOffer(db, conversationID, userID, reqBody.Price)
+	if err != nil {
+		return c.JSON(offerErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	broadcastOffer(offer)
+	c.Logger().Infof("event=OfferCreated offer_id=%s conversation_id=%s proposer_id=%s price=%s timestamp=%s", offer.ID, conversationID, userID, offer.Price, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, offer)
+}
+
+// respondToOfferHandler regroupe le traitement des réponses à une offre
+func respondToOfferHandler(c echo.Context, action string) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid offer ID"})
+	}
+
+	var reqBody struct {
+		Price models.Money `json:"price"`
+	}
+	if action == offerCounter {
+		if err := c.Bind(&reqBody); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+
+	offer, err := respondToOffer(db, offerID, userID, action, reqBody.Price)
+	if err != nil {
+		return c.JSON(offerErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	broadcastOffer(offer)
+	c.Logger().Infof("event=OfferAnswered offer_id=%s action=%s user_id=%s timestamp=%s", offerID, action, userID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, offer)
+}
+
+// @Summary		Accepte une offre
+// @Description	Accepte l'offre en attente de l'autre partie. L'acheteur peut ensuite payer le ticket à ce prix.
+// @ID				accept-offer
+// @Tags			Conversations
+// @Produce		json
+// @Param			id	path		string	true	"ID de l'offre"	format(uuid)
+// @Success		200	{object}	models.Offer
+// @Failure		401	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/offers/{id}/accept [post]
+// @Security		Bearer
+func AcceptOffer(c echo.Context) error {
+	return respondToOfferHandler(c, offerAccept)
+}
+
+// @Summary		Refuse une offre
+// @Description	Refuse l'offre en attente de l'autre partie
+// @ID				reject-offer
+// @Tags			Conversations
+// @Produce		json
+// @Param			id	path		string	true	"ID de l'offre"	format(uuid)
+// @Success		200	{object}	models.Offer
+// @Failure		401	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/offers/{id}/reject [post]
+// @Security		Bearer
+func RejectOffer(c echo.Context) error {
+	return respondToOfferHandler(c, offerReject)
+}
+
+// @Summary		Fait une contre-offre
+// @Description	Répond à l'offre en attente de l'autre partie par un nouveau prix
+// @ID				counter-offer
+// @Tags			Conversations
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string			true	"ID de l'offre"	format(uuid)
+// @Param			body	body		models.Money	true	"price"
+// @Success		200		{object}	models.Offer
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/offers/{id}/counter [post]
+// @Security		Bearer
+func CounterOffer(c echo.Context) error {
+	return respondToOfferHandler(c, offerCounter)
+}
@@ -0,0 +1,482 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/fraud"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errTicketCapExceeded = errors.New("ticket limit per concert exceeded")
+var errOrderNotPending = errors.New("order is not pending")
+
+type OrderItemRequest struct {
+	ConcertCategoryId uuid.UUID `json:"concertCategoryId"`
+	Quantity          int       `json:"quantity"`
+}
+
+type CreateOrderRequest struct {
+	Items []OrderItemRequest `json:"items"`
+}
+
+func getMaxTicketsPerConcert() int {
+	return getConfigInt("MAX_TICKETS_PER_USER_PER_CONCERT", 6)
+}
+
+// checkTicketCaps vérifie que l'utilisateur ne dépasse pas le nombre maximum de tickets par concert,
+// en additionnant les tickets qu'il possède déjà et ceux de la commande
+func checkTicketCaps(db *gorm.DB, userID uuid.UUID, items []models.OrderItem) error {
+	requested := make(map[uuid.UUID]int)
+	for _, item := range items {
+		requested[item.ConcertCategory.ConcertId] += item.Quantity
+	}
+
+	maxTickets := getMaxTicketsPerConcert()
+	for concertID, quantity := range requested {
+		var owned int64
+		if err := db.Model(&models.Ticket{}).
+			Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+			Where("tickets.user_id = ? AND concert_categories.concert_id = ? AND tickets.status = ?", userID, concertID, models.TicketValid).
+			Count(&owned).Error; err != nil {
+			return err
+		}
+		if int(owned)+quantity > maxTickets {
+			return errTicketCapExceeded
+		}
+	}
+	return nil
+}
+
+// orderAttempts décrit, pour l'anti-fraude, la réservation des places de chaque ligne d'une commande.
+// Les lignes doivent être chargées avec leur catégorie.
+func orderAttempts(userID uuid.UUID, items []models.OrderItem) []fraud.Attempt {
+	attempts := make([]fraud.Attempt, 0, len(items))
+	for _, item := range items {
+		attempts = append(attempts, fraud.Attempt{
+			Checkpoint:        models.FraudCheckpointReservation,
+			UserID:            userID,
+			ConcertID:         item.ConcertCategory.ConcertId,
+			ConcertCategoryID: item.ConcertCategoryId,
+			Quantity:          item.Quantity,
+		})
+	}
+	return attempts
+}
+
+// holdOrderItems bloque les places de chaque ligne d'une commande en attente de l'utilisateur, dans
+// une seule transaction : soit toutes les lignes sont bloquées, soit aucune. En placement numéroté,
+// les places de chaque catégorie doivent avoir été choisies au préalable (voir holdSeats).
+func holdOrderItems(db *gorm.DB, userID, orderID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Preload("Items").Where("id = ? AND user_id = ? AND status = ?", orderID, userID, models.OrderPending).
+			First(&order).Error; err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			if _, err := holdTickets(tx, userID, item.ConcertCategoryId, item.Quantity, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// releaseOrderHolds libère les holds de l'utilisateur sur les catégories d'une commande
+func releaseOrderHolds(db *gorm.DB, userID, orderID uuid.UUID) error {
+	var categoryIDs []uuid.UUID
+	if err := db.Model(&models.OrderItem{}).Where("order_id = ?", orderID).Pluck("concert_category_id", &categoryIDs).Error; err != nil {
+		return err
+	}
+	for _, categoryID := range categoryIDs {
+		if err := releaseTicketHold(db, userID, categoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fulfillOrder émet tous les tickets d'une commande dans une seule transaction :
+// soit toutes les places sont décomptées et les tickets créés, soit aucun ne l'est.
+func fulfillOrder(db *gorm.DB, orderID uuid.UUID) (*models.Order, error) {
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Verrouille la commande pour éviter une double émission des tickets
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return err
+		}
+		if order.Status != models.OrderPending {
+			return errOrderNotPending
+		}
+
+		if err := tx.Preload("ConcertCategory").Where("order_id = ?", order.ID).Find(&order.Items).Error; err != nil {
+			return err
+		}
+
+		if err := checkTicketCaps(tx, order.UserId, order.Items); err != nil {
+			return err
+		}
+
+		for i, item := range order.Items {
+			if _, err := reserveInventory(tx, item.ConcertCategoryId, order.UserId, item.Quantity); err != nil {
+				return err
+			}
+			seats, err := takeHeldSeats(tx, order.UserId, item.ConcertCategoryId, item.Quantity)
+			if err != nil {
+				return err
+			}
+
+			for n := 0; n < item.Quantity; n++ {
+				ticket := models.Ticket{
+					ID:                uuid.New(),
+					CreatedAt:         time.Now(),
+					UpdatedAt:         time.Now(),
+					UserId:            order.UserId,
+					ConcertCategoryId: item.ConcertCategoryId,
+					MaxPrice:          item.UnitPrice,
+					OrderItemId:       &order.Items[i].ID,
+					Status:            models.TicketValid,
+				}
+				if n < len(seats) {
+					ticket.ConcertSeatId = &seats[n].ID
+				}
+				if err := tx.Create(&ticket).Error; err != nil {
+					return err
+				}
+				if ticket.ConcertSeatId != nil {
+					if err := sellSeat(tx, *ticket.ConcertSeatId, ticket.ID); err != nil {
+						return err
+					}
+				}
+				if err := recordOwnership(tx, models.TicketOwnership{
+					TicketId: ticket.ID,
+					UserId:   order.UserId,
+					Source:   models.OwnershipPrimary,
+					Price:    item.UnitPrice,
+				}); err != nil {
+					return err
+				}
+				order.Items[i].Tickets = append(order.Items[i].Tickets, ticket)
+			}
+
+			if err := consumeTicketHold(tx, order.UserId, item.ConcertCategoryId, item.Quantity); err != nil {
+				return err
+			}
+			if err := convertWaitlistAlerts(tx, order.UserId, tx.Where("concert_category_id = ?", item.ConcertCategoryId)); err != nil {
+				return err
+			}
+		}
+
+		order.Status = models.OrderCompleted
+		order.UpdatedAt = time.Now()
+		return tx.Omit("Items").Save(&order).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// @Summary		Créé une commande
+// @Description	Créé une commande regroupant plusieurs catégories de concert et calcule son montant total
+// @ID				create-order
+// @Tags			Orders
+// @Accept			json
+// @Produce		json
+// @Param			body	body		CreateOrderRequest	true	"Catégories et quantités"
+// @Success		201		{object}	models.Order
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/orders [post]
+// @Security		Bearer
+func CreateOrder(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var reqBody CreateOrderRequest
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if len(reqBody.Items) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Order must contain at least one item"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	order := models.Order{
+		ID:        uuid.New(),
+		UserId:    user.ID,
+		Status:    models.OrderPending,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	// Regroupe les lignes portant sur la même catégorie
+	quantities := make(map[uuid.UUID]int)
+	var categoryIDs []uuid.UUID
+	for _, item := range reqBody.Items {
+		if item.Quantity <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid quantity"})
+		}
+		if _, exists := quantities[item.ConcertCategoryId]; !exists {
+			categoryIDs = append(categoryIDs, item.ConcertCategoryId)
+		}
+		quantities[item.ConcertCategoryId] += item.Quantity
+	}
+
+	for _, categoryID := range categoryIDs {
+		var concertCategory models.ConcertCategory
+		if err := db.Where("id = ?", categoryID).First(&concertCategory).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert category not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+
+		item := models.OrderItem{
+			ID:                uuid.New(),
+			OrderId:           order.ID,
+			ConcertCategoryId: concertCategory.ID,
+			ConcertCategory:   concertCategory,
+			Quantity:          quantities[categoryID],
+			UnitPrice:         concertCategory.Price,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
+		order.Items = append(order.Items, item)
+		if len(order.Items) == 1 {
+			order.TotalAmount = models.NewMoney(0, concertCategory.Price.Currency)
+		}
+		total, err := order.TotalAmount.Add(concertCategory.Price.Mul(int64(item.Quantity)))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "All categories of an order must share the same currency"})
+		}
+		order.TotalAmount = total
+	}
+
+	if err := checkTicketCaps(db, user.ID, order.Items); err != nil {
+		if errors.Is(err, errTicketCapExceeded) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Ticket limit per concert exceeded"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	for _, attempt := range orderAttempts(user.ID, order.Items) {
+		if review, err := screenAttempt(db, attempt); err != nil {
+			return fraudResponse(c, review, err)
+		}
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(&order).Error; err != nil {
+			return err
+		}
+		return tx.Omit("ConcertCategory").Create(&order.Items).Error
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+	}
+
+	c.Logger().Infof("event=OrderCreated order_id=%s user_id=%s timestamp=%s", order.ID, user.ID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, order)
+}
+
+// @Summary		Récupère une commande
+// @Description	Récupère une commande de l'utilisateur par ID
+// @ID				get-order
+// @Tags			Orders
+// @Produce		json
+// @Param			id	path		string	true	"ID de la commande"	format(uuid)
+// @Success		200	{object}	models.Order
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/orders/{id} [get]
+// @Security		Bearer
+func GetOrder(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var order models.Order
+	if err := db.Preload("Items.ConcertCategory.Concert").Preload("Items.ConcertCategory.Category").Preload("Items.Tickets").
+		Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+// @Summary		Valide une commande
+// @Description	Renvoie la commande honorée par un paiement confirmé : tous ses tickets sont émis en une seule transaction, ou aucun. Sans paiement abouti, la commande n'est pas validée.
+// @ID				checkout-order
+// @Tags			Orders
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID de la commande"	format(uuid)
+// @Param			body	body		object	false	"paymentIntentId"
+// @Success		200		{object}	models.Order
+// @Failure		401		{object}	map[string]string
+// @Failure		402		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Failure		502		{object}	map[string]string
+// @Router			/orders/{id}/checkout [post]
+// @Security		Bearer
+func CheckoutOrder(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userIdFromToken, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	userID, err := uuid.Parse(userIdFromToken)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid user ID format"})
+	}
+
+	var reqBody struct {
+		PaymentIntentId string `json:"paymentIntentId"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	var order models.Order
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// La commande n'est honorée qu'à partir d'un paiement confirmé, par le webhook ou le prestataire
+	payment, status, err := confirmPayment(c.Request().Context(), db, userID, models.PaymentItemOrder, order.ID, reqBody.PaymentIntentId)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	var fulfilled models.Order
+	if err := db.Preload("Items.Tickets").Where("id = ?", payment.OrderId).First(&fulfilled).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Order not found"})
+	}
+
+	c.Logger().Infof("event=OrderCompleted order_id=%s user_id=%s timestamp=%s", fulfilled.ID, fulfilled.UserId, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, fulfilled)
+}
+
+// @Summary		Annule une commande
+// @Description	Annule une commande en attente de l'utilisateur
+// @ID				cancel-order
+// @Tags			Orders
+// @Produce		json
+// @Param			id	path	string	true	"ID de la commande"	format(uuid)
+// @Success		204
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/orders/{id} [delete]
+// @Security		Bearer
+func CancelOrder(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	res := db.Model(&models.Order{}).
+		Where("id = ? AND user_id = ? AND status = ?", c.Param("id"), userID, models.OrderPending).
+		Updates(map[string]interface{}{"status": models.OrderCancelled, "updated_at": time.Now()})
+	if res.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel order"})
+	}
+	if res.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Pending order not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+	"weezemaster/internal/models"
+	"weezemaster/internal/testdb"
+
+	"github.com/google/uuid"
+)
+
+// Une commande en placement numéroté sur deux catégories : chaque catégorie a son propre hold et
+// chaque ticket émis reçoit une des places choisies dans sa catégorie
+func TestFulfillOrderWithSeatsInSeveralCategories(t *testing.T) {
+	db := testdb.Open(t, &models.Concert{}, &models.ConcertCategory{}, &models.TicketHold{}, &models.ConcertSeat{},
+		&models.Ticket{}, &models.Order{}, &models.OrderItem{}, &models.TicketOwnership{}, &models.WaitlistAlert{})
+
+	price := models.NewMoney(4000, models.DefaultCurrency)
+	categories, seatsByCategory := createSeatedConcert(t, db, 2, 3, price)
+
+	userID := uuid.New()
+	first, second := categories[0], categories[1]
+	chosen := map[uuid.UUID][]uuid.UUID{
+		first.ID:  seatsByCategory[first.ID][:2],
+		second.ID: seatsByCategory[second.ID][1:2],
+	}
+
+	firstHold, err := holdTickets(db, userID, first.ID, 2, chosen[first.ID])
+	if err != nil {
+		t.Fatalf("hold first category: %v", err)
+	}
+	secondHold, err := holdTickets(db, userID, second.ID, 1, chosen[second.ID])
+	if err != nil {
+		t.Fatalf("hold second category: %v", err)
+	}
+	if firstHold.ID == secondHold.ID {
+		t.Fatalf("both categories share hold %s", firstHold.ID)
+	}
+
+	// Le paiement d'une seule place de la première catégorie conserve les deux places choisies
+	if _, err := holdTicketForCheckout(db, userID, first.ID); err != nil {
+		t.Fatalf("hold for checkout: %v", err)
+	}
+
+	var active int64
+	if err := db.Model(&models.TicketHold{}).Where("user_id = ? AND status = ?", userID, models.TicketHoldActive).
+		Count(&active).Error; err != nil {
+		t.Fatalf("count holds: %v", err)
+	}
+	if active != 2 {
+		t.Fatalf("%d active holds, want one per category", active)
+	}
+
+	order := models.Order{ID: uuid.New(), UserId: userID, Status: models.OrderPending, TotalAmount: price.Mul(3)}
+	if err := db.Omit("Items", "User").Create(&order).Error; err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	for _, concertCategory := range categories {
+		item := models.OrderItem{
+			ID:                uuid.New(),
+			OrderId:           order.ID,
+			ConcertCategoryId: concertCategory.ID,
+			Quantity:          len(chosen[concertCategory.ID]),
+			UnitPrice:         price,
+		}
+		if err := db.Omit("ConcertCategory").Create(&item).Error; err != nil {
+			t.Fatalf("create order item: %v", err)
+		}
+	}
+
+	if _, err := fulfillOrder(db, order.ID); err != nil {
+		t.Fatalf("fulfillOrder: %v", err)
+	}
+
+	var tickets []models.Ticket
+	if err := db.Where("user_id = ?", userID).Find(&tickets).Error; err != nil {
+		t.Fatalf("load tickets: %v", err)
+	}
+	if len(tickets) != 3 {
+		t.Fatalf("%d tickets issued, want 3", len(tickets))
+	}
+	for _, ticket := range tickets {
+		if ticket.ConcertSeatId == nil {
+			t.Errorf("ticket %s has no seat", ticket.ID)
+			continue
+		}
+		if !slices.Contains(chosen[ticket.ConcertCategoryId], *ticket.ConcertSeatId) {
+			t.Errorf("ticket %s got seat %s, which was not chosen in its category", ticket.ID, *ticket.ConcertSeatId)
+		}
+	}
+
+	var held int64
+	if err := db.Model(&models.ConcertSeat{}).Where("hold_id IS NOT NULL").Count(&held).Error; err != nil {
+		t.Fatalf("count held seats: %v", err)
+	}
+	if held != 0 {
+		t.Errorf("%d seats still held after the order", held)
+	}
+}
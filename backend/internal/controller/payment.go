@@ -1,21 +1,20 @@
 package controller
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"strconv"
 	"strings"
-	"weezemaster/internal/config"
+	"time"
 
 	"weezemaster/internal/database"
+	"weezemaster/internal/fraud"
 	"weezemaster/internal/models"
+	"weezemaster/internal/payments"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/shopspring/decimal"
 
 	"gorm.io/gorm"
 )
@@ -24,15 +23,16 @@ type CreatePaymentIntentRequest struct {
 	ID string `json:"id"`
 }
 
-func GetAmountById(id string) (int64, error) {
+// GetAmountById renvoie le montant que l'utilisateur doit payer pour l'élément identifié par son préfixe
+func GetAmountById(id string, userID uuid.UUID) (models.Money, error) {
 	if id == "" {
-		return 0, errors.New("UUID cannot be empty")
+		return models.Money{}, errors.New("UUID cannot be empty")
 	}
 	fmt.Println("ID: ", id)
 
 	parts := strings.SplitN(id, "_", 2)
 	if len(parts) != 2 {
-		return 0, errors.New("invalid ID format")
+		return models.Money{}, errors.New("invalid ID format")
 	}
 
 	prefix, idStr := parts[0], parts[1]
@@ -44,40 +44,45 @@ func GetAmountById(id string) (int64, error) {
 		var concertCategory models.ConcertCategory
 		if err := db.Where("id = ?", idStr).First(&concertCategory).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return 0, errors.New("no ConcertCategory found with the given UUID")
+				return models.Money{}, errors.New("no ConcertCategory found with the given UUID")
 			}
-			return 0, err
+			return models.Money{}, err
 		}
-		priceDecimal := decimal.NewFromFloat(concertCategory.Price)
-		amountDecimal := priceDecimal.Mul(decimal.NewFromInt(100))
-		amount := amountDecimal.IntPart()
-		return amount, nil
+		return concertCategory.Price, nil
 	case "tl":
 		var ticketListing models.TicketListing
 		if err := db.Where("id = ?", idStr).First(&ticketListing).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return 0, errors.New("no TicketListing found with the given UUID")
+				return models.Money{}, errors.New("no TicketListing found with the given UUID")
 			}
-			return 0, err
+			return models.Money{}, err
 		}
-		priceDecimal := decimal.NewFromFloat(ticketListing.Price)
-		amountDecimal := priceDecimal.Mul(decimal.NewFromInt(100))
-		amount := amountDecimal.IntPart()
-		return amount, nil
+		return ticketListing.Price, nil
 	case "cv":
 		var conversation models.Conversation
 		if err := db.Where("id = ?", idStr).First(&conversation).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return 0, errors.New("no Conversation found with the given UUID")
+				return models.Money{}, errors.New("no Conversation found with the given UUID")
 			}
-			return 0, err
+			return models.Money{}, err
 		}
-		priceDecimal := decimal.NewFromFloat(conversation.Price)
-		amountDecimal := priceDecimal.Mul(decimal.NewFromInt(100))
-		amount := amountDecimal.IntPart()
-		return amount, nil
+		// Une revente négociée ne se paie qu'au prix de l'offre acceptée
+		offer, err := acceptedOffer(db, conversation.ID)
+		if err != nil {
+			return models.Money{}, err
+		}
+		return offer.Price, nil
+	case "or":
+		var order models.Order
+		if err := db.Where("id = ? AND user_id = ? AND status = ?", idStr, userID, models.OrderPending).First(&order).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.Money{}, errors.New("no pending Order found with the given UUID")
+			}
+			return models.Money{}, err
+		}
+		return order.TotalAmount, nil
 	default:
-		return 0, errors.New("unknown ID prefix")
+		return models.Money{}, errors.New("unknown ID prefix")
 	}
 }
 
@@ -88,45 +93,268 @@ func GetAmountById(id string) (int64, error) {
 // @Accept			json
 // @Produce		json
 // @Param			body	body		CreatePaymentIntentRequest	true	"Request body"
-// @Success		200		{object}	map[string]string
+// @Success		200		{object}	payments.Intent
 // @Failure		400		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
+// @Failure		502		{object}	map[string]string
 // @Router			/create-payment-intent [post]
 // @Security		Bearer
 func CreatePaymentIntent(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userIdFromToken, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	userID, err := uuid.Parse(userIdFromToken)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid user ID format"})
+	}
+
 	req := new(CreatePaymentIntentRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	amount, _ := GetAmountById(req.ID)
+	amount, err := GetAmountById(req.ID, userID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
-	urlStr := "https://api.stripe.com/v1/payment_intents"
+	prefix, idStr, _ := strings.Cut(req.ID, "_")
+	itemID, err := uuid.Parse(idStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
 
-	data := url.Values{}
-	data.Set("amount", strconv.FormatInt(amount, 10))
-	data.Set("currency", "eur")
-	data.Set("payment_method_types[]", "card")
+	if status, review, err := screenCheckout(db, userID, prefix, itemID); err != nil {
+		if status != 0 {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+		return fraudResponse(c, review, err)
+	}
+
+	// Le démarrage du paiement d'une catégorie de concert bloque une place pour l'utilisateur
+	if prefix == models.PaymentItemConcertCategory {
+		if status, err := holdTicketForCheckout(db, userID, itemID); err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+	}
+
+	// Le paiement d'une commande bloque les places de toutes ses lignes
+	if prefix == models.PaymentItemOrder {
+		if err := holdOrderItems(db, userID, itemID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Pending order not found"})
+			}
+			status, err := holdErrorStatus(err)
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+	}
 
-	request, err := http.NewRequestWithContext(c.Request().Context(), "POST", urlStr, strings.NewReader(data.Encode()))
+	// Le paiement d'une revente réserve l'annonce à l'acheteur le temps du paiement
+	if prefix == models.PaymentItemTicketListing || prefix == models.PaymentItemConversation {
+		if status, err := reserveListingForCheckout(db, userID, prefix, itemID); err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+	}
+
+	paymentID := uuid.New()
+	provider := payments.GetProvider()
+
+	intent, err := provider.CreateIntent(c.Request().Context(), payments.CreateIntentParams{
+		Amount: amount,
+		Metadata: map[string]string{
+			"payment_id": paymentID.String(),
+			"user_id":    userID.String(),
+			"item":       req.ID,
+		},
+	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to create payment intent"})
+	}
+
+	payment := models.Payment{
+		ID:                paymentID,
+		Provider:          provider.Name(),
+		ProviderPaymentId: intent.ID,
+		Status:            models.PaymentPending,
+		Amount:            amount,
+		UserId:            userID,
+		ItemType:          prefix,
+		ItemId:            itemID,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record payment"})
 	}
 
-	request.Header.Set("Authorization", "Bearer "+config.StripeSecretKey)
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.JSON(http.StatusOK, intent)
+}
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+// screenCheckout passe le démarrage d'un paiement au moteur anti-fraude : une réservation pour une
+// catégorie de concert ou pour chaque ligne d'une commande, un achat en revente pour une annonce
+// ou une conversation. Un statut non nul accompagne une erreur hors anti-fraude.
+func screenCheckout(db *gorm.DB, userID uuid.UUID, prefix string, itemID uuid.UUID) (int, *models.FraudReview, error) {
+	var attempt fraud.Attempt
+	var err error
+	switch prefix {
+	case models.PaymentItemOrder:
+		// Contrôlée à sa création, la commande l'est de nouveau au paiement, qui peut venir plus tard
+		var items []models.OrderItem
+		if err := db.Preload("ConcertCategory").Where("order_id = ?", itemID).Find(&items).Error; err != nil {
+			return http.StatusInternalServerError, nil, errors.New("Failed to run anti-fraud checks")
+		}
+		for _, attempt := range orderAttempts(userID, items) {
+			if review, err := screenAttempt(db, attempt); err != nil {
+				return 0, review, err
+			}
+		}
+		return 0, nil, nil
+	case models.PaymentItemConcertCategory:
+		attempt, err = reservationAttempt(db, userID, itemID, 1)
+	case models.PaymentItemTicketListing, models.PaymentItemConversation:
+		listingID := itemID
+		if prefix == models.PaymentItemConversation {
+			var conversation models.Conversation
+			if err := db.Where("id = ?", itemID).First(&conversation).Error; err != nil {
+				return http.StatusNotFound, nil, errors.New("Conversation not found")
+			}
+			listingID = conversation.TicketListingId
+		}
+		attempt, err = resalePurchaseAttempt(db, userID, listingID)
+	default:
+		return 0, nil, nil
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return http.StatusNotFound, nil, errors.New("Item not found")
+		}
+		return http.StatusInternalServerError, nil, errors.New("Failed to run anti-fraud checks")
 	}
-	defer response.Body.Close()
 
-	body, err := io.ReadAll(response.Body)
+	review, err := screenAttempt(db, attempt)
+	return 0, review, err
+}
+
+// reserveListingForCheckout réserve l'annonce achetée directement ou via une conversation
+func reserveListingForCheckout(db *gorm.DB, userID uuid.UUID, prefix string, itemID uuid.UUID) (int, error) {
+	listingID := itemID
+	if prefix == models.PaymentItemConversation {
+		var conversation models.Conversation
+		if err := db.Where("id = ?", itemID).First(&conversation).Error; err != nil {
+			return http.StatusNotFound, errors.New("Conversation not found")
+		}
+		if conversation.BuyerId != userID {
+			return http.StatusForbidden, errNotConversationParty
+		}
+		listingID = conversation.TicketListingId
+	}
+
+	if _, err := reserveListing(db, listingID, userID); err != nil {
+		switch {
+		case errors.Is(err, errListingReserved), errors.Is(err, errListingNotAvailable), errors.Is(err, errBuyerIsOwner):
+			return http.StatusConflict, err
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return http.StatusNotFound, errors.New("Ticket listing not found")
+		}
+		return http.StatusInternalServerError, errors.New("Failed to reserve ticket listing")
+	}
+	return http.StatusOK, nil
+}
+
+// holdTicketForCheckout bloque la place payée. Un hold déjà posé par l'utilisateur sur la catégorie,
+// avec les places qu'il a choisies, est conservé et prolongé.
+func holdTicketForCheckout(db *gorm.DB, userID, concertCategoryID uuid.UUID) (int, error) {
+	quantity := 1
+	var existing models.TicketHold
+	if err := db.Where("user_id = ? AND concert_category_id = ? AND status = ? AND expires_at > ?",
+		userID, concertCategoryID, models.TicketHoldActive, time.Now()).First(&existing).Error; err == nil && existing.Quantity > quantity {
+		quantity = existing.Quantity
+	}
+
+	if _, err := holdTickets(db, userID, concertCategoryID, quantity, nil); err != nil {
+		return holdErrorStatus(err)
+	}
+
+	return http.StatusOK, nil
+}
+
+// holdErrorStatus traduit une erreur de holdTickets en statut et message de réponse
+func holdErrorStatus(err error) (int, error) {
+	if errors.Is(err, errNoTicketsAvailable) {
+		return http.StatusConflict, errors.New("No tickets available for this category")
+	}
+	if errors.Is(err, errSeatSelectionRequired) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, errConcertCancelled) {
+		return http.StatusConflict, errors.New("Concert has been cancelled")
+	}
+	if errors.Is(err, errSalesNotOpen) || errors.Is(err, errSalesClosed) {
+		return http.StatusConflict, err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound, errors.New("Concert category not found")
+	}
+	return http.StatusInternalServerError, errors.New("Failed to hold tickets")
+}
+
+// confirmPayment retrouve le paiement d'un utilisateur pour un élément et s'assure qu'il a été honoré.
+// Si le webhook n'est pas encore arrivé, le statut est vérifié directement auprès du prestataire
+// et le paiement est honoré de la même façon, de manière idempotente.
+func confirmPayment(ctx context.Context, db *gorm.DB, userID uuid.UUID, itemType string, itemID uuid.UUID, paymentIntentID string) (*models.Payment, int, error) {
+	query := db.Where("user_id = ? AND item_type = ? AND item_id = ?", userID, itemType, itemID)
+	if paymentIntentID != "" {
+		query = query.Where("provider_payment_id = ?", paymentIntentID)
+	}
+
+	var payment models.Payment
+	if err := query.Order("created_at DESC").First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, http.StatusPaymentRequired, errors.New("Payment required")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+
+	switch payment.Status {
+	case models.PaymentSucceeded:
+		return &payment, http.StatusOK, nil
+	case models.PaymentRefunded:
+		return nil, http.StatusConflict, errors.New("Payment has been refunded")
+	case models.PaymentFulfillmentFailed:
+		return nil, http.StatusConflict, errors.New("Payment could not be fulfilled and will be refunded")
+	}
+
+	status, err := payments.GetProvider().FetchStatus(ctx, payment.ProviderPaymentId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return nil, http.StatusBadGateway, errors.New("Failed to check payment status")
+	}
+	if status != payments.StatusSucceeded {
+		return nil, http.StatusPaymentRequired, errors.New("Payment not confirmed")
 	}
 
-	return c.JSON(http.StatusOK, json.RawMessage(body))
+	fulfilled, err := fulfillPayment(db, payment.ProviderPaymentId)
+	if err != nil {
+		return nil, fulfillmentErrorStatus(err), err
+	}
+	return fulfilled, http.StatusOK, nil
 }
@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var errNotSaleParty = errors.New("you are not a party to this sale")
+var errRatingWindowClosed = errors.New("the rating window for this sale has closed")
+var errSaleNotRateable = errors.New("this sale can no longer be rated")
+var errAlreadyRated = errors.New("you have already rated this sale")
+var errInvalidRating = errors.New("score must be between 1 and 5 and the review at most 500 characters")
+
+// Longueur maximale d'un avis, en caractères
+const maxRatingCommentLength = 500
+
+// Nombre d'avis récents renvoyés avec la réputation d'un utilisateur
+const recentRatingsLimit = 10
+
+func getRatingWindow() time.Duration {
+	return time.Duration(getConfigInt("RATING_WINDOW_DAYS", 14)) * 24 * time.Hour
+}
+
+func ratingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNotSaleParty):
+		return http.StatusForbidden
+	case errors.Is(err, errInvalidRating):
+		return http.StatusBadRequest
+	case errors.Is(err, errRatingWindowClosed), errors.Is(err, errSaleNotRateable), errors.Is(err, errAlreadyRated):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// isRatingDuplicate indique si une insertion a violé l'unicité d'une note par partie et par vente
+func isRatingDuplicate(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_rating_sale_rater"
+}
+
+// createRating enregistre la note d'une partie sur l'autre après une revente conclue, dans les
+// RATING_WINDOW_DAYS jours qui suivent la vente
+func createRating(db *gorm.DB, saleID, raterID uuid.UUID, score int, comment string) (*models.Rating, error) {
+	comment = strings.TrimSpace(comment)
+	if score < models.RatingMinScore || score > models.RatingMaxScore || utf8.RuneCountInString(comment) > maxRatingCommentLength {
+		return nil, errInvalidRating
+	}
+
+	var sale models.Sale
+	if err := db.Preload("TicketSold").Where("id = ?", saleID).First(&sale).Error; err != nil {
+		return nil, err
+	}
+
+	rating := models.Rating{
+		ID:        uuid.New(),
+		SaleId:    sale.ID,
+		RaterId:   raterID,
+		Score:     score,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	switch raterID {
+	case sale.BuyerId:
+		rating.RateeId = sale.SellerId
+		rating.RateeRole = models.RatingAsSeller
+	case sale.SellerId:
+		rating.RateeId = sale.BuyerId
+		rating.RateeRole = models.RatingAsBuyer
+	default:
+		return nil, errNotSaleParty
+	}
+
+	// Une revente remboursée ne donne plus lieu à une note
+	if sale.TicketSold.Status != models.ListingSold {
+		return nil, errSaleNotRateable
+	}
+	if time.Since(sale.CreatedAt) > getRatingWindow() {
+		return nil, errRatingWindowClosed
+	}
+
+	if err := db.Create(&rating).Error; err != nil {
+		if isRatingDuplicate(err) {
+			return nil, errAlreadyRated
+		}
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// reputationsFor calcule en une requête la réputation de plusieurs utilisateurs. Un utilisateur
+// sans note reçoit une réputation vide.
+func reputationsFor(db *gorm.DB, userIDs []uuid.UUID) (map[uuid.UUID]*models.Reputation, error) {
+	reputations := make(map[uuid.UUID]*models.Reputation, len(userIDs))
+	if len(userIDs) == 0 {
+		return reputations, nil
+	}
+	for _, id := range userIDs {
+		reputations[id] = &models.Reputation{}
+	}
+
+	var rows []struct {
+		RateeId   uuid.UUID
+		RateeRole string
+		Total     int64
+		Count     int64
+	}
+	if err := db.Model(&models.Rating{}).
+		Select("ratee_id, ratee_role, SUM(score) AS total, COUNT(*) AS count").
+		Where("ratee_id IN ?", userIDs).
+		Group("ratee_id, ratee_role").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[uuid.UUID]int64)
+	for _, row := range rows {
+		reputation := reputations[row.RateeId]
+		score := models.ReputationScore{Average: float64(row.Total) / float64(row.Count), Count: row.Count}
+		if row.RateeRole == models.RatingAsSeller {
+			reputation.AsSeller = score
+		} else {
+			reputation.AsBuyer = score
+		}
+		reputation.Count += row.Count
+		totals[row.RateeId] += row.Total
+	}
+	for id, total := range totals {
+		reputations[id].Average = float64(total) / float64(reputations[id].Count)
+	}
+	return reputations, nil
+}
+
+// reputationOf calcule la réputation d'un seul utilisateur
+func reputationOf(db *gorm.DB, userID uuid.UUID) (*models.Reputation, error) {
+	reputations, err := reputationsFor(db, []uuid.UUID{userID})
+	if err != nil {
+		return nil, err
+	}
+	return reputations[userID], nil
+}
+
+// attachSellerReputations renseigne la réputation du vendeur de chaque annonce. Le ticket des
+// annonces doit être chargé.
+func attachSellerReputations(db *gorm.DB, listings []*models.TicketListing) error {
+	sellerIDs := make([]uuid.UUID, 0, len(listings))
+	seen := make(map[uuid.UUID]bool)
+	for _, listing := range listings {
+		if !seen[listing.Ticket.UserId] {
+			seen[listing.Ticket.UserId] = true
+			sellerIDs = append(sellerIDs, listing.Ticket.UserId)
+		}
+	}
+	reputations, err := reputationsFor(db, sellerIDs)
+	if err != nil {
+		return err
+	}
+	for _, listing := range listings {
+		listing.SellerReputation = reputations[listing.Ticket.UserId]
+	}
+	return nil
+}
+
+// @Summary		Note une revente
+// @Description	Permet à l'acheteur et au vendeur d'une revente conclue de noter l'autre partie (1 à 5) avec un court avis. Une seule note par partie, dans les RATING_WINDOW_DAYS jours qui suivent la vente.
+// @ID				create-sale-rating
+// @Tags			Ratings
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID de la vente"	format(uuid)
+// @Param			body	body		object	true	"score (1 à 5) et comment (optionnel, 500 caractères au plus)"
+// @Success		201		{object}	models.Rating
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/sales/{id}/rating [post]
+// @Security		Bearer
+func CreateSaleRating(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	saleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Sale not found"})
+	}
+
+	var reqBody struct {
+		Score   int    `json:"score"`
+		Comment string `json:"comment"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	rating, err := createRating(db, saleID, userID, reqBody.Score, reqBody.Comment)
+	if err != nil {
+		status := ratingErrorStatus(err)
+		if status == http.StatusNotFound {
+			return c.JSON(status, map[string]string{"error": "Sale not found"})
+		}
+		if status == http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": "Failed to save rating"})
+		}
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	c.Logger().Infof("event=SaleRated rating_id=%s sale_id=%s rater_id=%s ratee_id=%s score=%d timestamp=%s", rating.ID, rating.SaleId, rating.RaterId, rating.RateeId, rating.Score, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, rating)
+}
+
+// @Summary		Réputation d'un utilisateur
+// @Description	Renvoie la note moyenne reçue par un utilisateur après ses reventes, en tout et par rôle, avec ses avis les plus récents
+// @ID				get-user-reputation
+// @Tags			Ratings
+// @Produce		json
+// @Param			id	path		string	true	"ID de l'utilisateur"	format(uuid)
+// @Success		200	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/users/{id}/reputation [get]
+// @Security		Bearer
+func GetUserReputation(c echo.Context) error {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	reputation, err := reputationOf(db, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to compute reputation"})
+	}
+
+	var ratings []models.Rating
+	if err := db.Preload("Rater").Where("ratee_id = ?", user.ID).
+		Order("created_at DESC").Limit(recentRatingsLimit).Find(&ratings).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve ratings"})
+	}
+
+	// Seul le prénom de l'auteur d'un avis est exposé
+	reviews := make([]map[string]interface{}, 0, len(ratings))
+	for _, rating := range ratings {
+		reviews = append(reviews, map[string]interface{}{
+			"ID":        rating.ID,
+			"RateeRole": rating.RateeRole,
+			"Score":     rating.Score,
+			"Comment":   rating.Comment,
+			"RaterName": rating.Rater.Firstname,
+			"CreatedAt": rating.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"UserId":     user.ID,
+		"Reputation": reputation,
+		"Reviews":    reviews,
+	})
+}
@@ -0,0 +1,814 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+	"weezemaster/internal/payments"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errRefundNotAllowed = errors.New("refunds are closed for this concert")
+var errRefundAlreadyRequested = errors.New("a refund is already in progress for this ticket")
+var errTicketNotValid = errors.New("ticket is no longer valid")
+var errRefundNotPending = errors.New("refund request is not pending")
+var errRefundProvider = errors.New("payment provider refused the refund")
+var errRefundTransferred = errors.New("a ticket received from another user cannot be refunded to you")
+
+// checkRefundPolicy vérifie que la politique de remboursement du concert autorise encore une demande
+func checkRefundPolicy(concert *models.Concert, now time.Time) error {
+	if !concert.RefundsEnabled {
+		return errRefundNotAllowed
+	}
+	cutoff := concert.Date.Add(-time.Duration(concert.RefundCutoffHours) * time.Hour)
+	if !now.Before(cutoff) {
+		return errRefundNotAllowed
+	}
+	return nil
+}
+
+// refundErrorStatus traduit une erreur de remboursement en code HTTP
+func refundErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errRefundNotAllowed), errors.Is(err, errRefundTransferred):
+		return http.StatusForbidden
+	case errors.Is(err, errRefundAlreadyRequested), errors.Is(err, errTicketNotValid), errors.Is(err, errRefundNotPending):
+		return http.StatusConflict
+	case errors.Is(err, errRefundProvider):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// paidAcquisition remonte l'historique des détenteurs jusqu'au dernier achat payé qui a amené le
+// ticket à son détenteur actuel : un don ou une réattribution renvoie à l'acquisition de
+// l'ancien détenteur, un retour au vendeur après remboursement à l'achat précédent du vendeur.
+// Renvoie nil pour un ticket sans historique ou créé sans paiement.
+func paidAcquisition(db *gorm.DB, ticket *models.Ticket) (*models.TicketOwnership, error) {
+	var history []models.TicketOwnership
+	if err := db.Where("ticket_id = ?", ticket.ID).Order("acquired_at DESC, created_at DESC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+
+	holder := ticket.UserId
+	for i, entry := range history {
+		if entry.UserId != holder {
+			continue
+		}
+		switch entry.Source {
+		case models.OwnershipPrimary, models.OwnershipResale:
+			return &history[i], nil
+		case models.OwnershipGift, models.OwnershipAdmin:
+			if entry.PreviousUserId == nil {
+				return nil, nil
+			}
+			holder = *entry.PreviousUserId
+		}
+	}
+	return nil, nil
+}
+
+// findTicketPayment retrouve le paiement par lequel le ticket a été payé en dernier (voir
+// paidAcquisition) et le montant remboursable. Pour un ticket acquis en revente, la vente est
+// aussi renvoyée. Un ticket émis sans paiement (fixtures, création par un administrateur) n'a
+// rien à rembourser.
+func findTicketPayment(db *gorm.DB, ticket *models.Ticket) (*models.Payment, *models.Sale, models.Money, error) {
+	acquisition, err := paidAcquisition(db, ticket)
+	if err != nil {
+		return nil, nil, models.Money{}, err
+	}
+
+	var sale models.Sale
+	switch {
+	case acquisition != nil && acquisition.Source == models.OwnershipResale && acquisition.SaleId != nil:
+		err = db.Where("id = ?", acquisition.SaleId).First(&sale).Error
+	case acquisition != nil:
+		err = gorm.ErrRecordNotFound
+	default:
+		// Ticket antérieur à l'historique des détenteurs
+		err = db.Joins("JOIN ticket_listings ON ticket_listings.id = sales.ticket_listing_id").
+			Where("ticket_listings.ticket_id = ? AND sales.buyer_id = ?", ticket.ID, ticket.UserId).
+			Order("sales.created_at DESC").
+			First(&sale).Error
+	}
+	if err == nil {
+		var payment models.Payment
+		if err := db.Where("sale_id = ? AND status = ?", sale.ID, models.PaymentSucceeded).First(&payment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &sale, models.Money{}, nil
+			}
+			return nil, nil, models.Money{}, err
+		}
+		return &payment, &sale, remainingAmount(&payment), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, models.Money{}, err
+	}
+	if acquisition == nil {
+		var entries int64
+		if err := db.Model(&models.TicketOwnership{}).Where("ticket_id = ?", ticket.ID).Count(&entries).Error; err != nil {
+			return nil, nil, models.Money{}, err
+		}
+		if entries > 0 {
+			return nil, nil, models.Money{}, nil
+		}
+	}
+
+	var payment models.Payment
+	err = db.Where("ticket_id = ? AND status = ?", ticket.ID, models.PaymentSucceeded).First(&payment).Error
+	if err == nil {
+		return &payment, nil, remainingAmount(&payment), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, models.Money{}, err
+	}
+
+	if ticket.OrderItemId != nil {
+		var orderItem models.OrderItem
+		if err := db.Where("id = ?", ticket.OrderItemId).First(&orderItem).Error; err != nil {
+			return nil, nil, models.Money{}, err
+		}
+		err = db.Where("order_id = ? AND status = ?", orderItem.OrderId, models.PaymentSucceeded).First(&payment).Error
+		if err == nil {
+			// Seule la part du ticket dans la commande est remboursée
+			return &payment, nil, orderItem.UnitPrice, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, models.Money{}, err
+		}
+	}
+
+	return nil, nil, models.Money{}, nil
+}
+
+// remainingAmount renvoie la part d'un paiement qui n'a pas encore été remboursée
+func remainingAmount(payment *models.Payment) models.Money {
+	return models.NewMoney(payment.Amount.Amount-payment.RefundedAmount, payment.Amount.Currency)
+}
+
+// createRefundRequest ouvre une demande de remboursement pour un ticket. Les annonces ouvertes
+// et les transferts en attente du ticket sont retirés pour qu'il ne puisse pas changer de
+// détenteur pendant l'examen de la demande.
+// bypassPolicy permet à un administrateur d'ignorer la propriété du ticket et la date limite.
+func createRefundRequest(db *gorm.DB, ticketID, requesterID uuid.UUID, reason string, bypassPolicy bool) (*models.RefundRequest, error) {
+	var request *models.RefundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ticketID).First(&ticket).Error; err != nil {
+			return err
+		}
+		if !bypassPolicy && ticket.UserId != requesterID {
+			return gorm.ErrRecordNotFound
+		}
+		if ticket.Status != models.TicketValid {
+			return errTicketNotValid
+		}
+
+		if !bypassPolicy {
+			var concertCategory models.ConcertCategory
+			if err := tx.Preload("Concert").Where("id = ?", ticket.ConcertCategoryId).First(&concertCategory).Error; err != nil {
+				return err
+			}
+			if err := checkRefundPolicy(&concertCategory.Concert, time.Now()); err != nil {
+				return err
+			}
+
+			// Un ticket déjà utilisé à l'entrée n'est pas remboursable
+			var checkIns int64
+			if err := tx.Model(&models.CheckIn{}).Where("ticket_id = ?", ticket.ID).Count(&checkIns).Error; err != nil {
+				return err
+			}
+			if checkIns > 0 {
+				return errTicketNotValid
+			}
+
+			// Le remboursement irait au dernier acheteur du ticket, pas à celui qui l'a reçu
+			acquisition, err := paidAcquisition(tx, &ticket)
+			if err != nil {
+				return err
+			}
+			if acquisition != nil && acquisition.UserId != ticket.UserId {
+				return errRefundTransferred
+			}
+		}
+
+		var inProgress int64
+		if err := tx.Model(&models.RefundRequest{}).
+			Where("ticket_id = ? AND status IN ?", ticket.ID, []string{models.RefundRequestPending, models.RefundRequestApproved, models.RefundRequestFailed}).
+			Count(&inProgress).Error; err != nil {
+			return err
+		}
+		if inProgress > 0 {
+			return errRefundAlreadyRequested
+		}
+
+		payment, sale, amount, err := findTicketPayment(tx, &ticket)
+		if err != nil {
+			return err
+		}
+		if amount.Currency == "" {
+			amount.Currency = models.DefaultCurrency
+		}
+
+		request = &models.RefundRequest{
+			ID:          uuid.New(),
+			TicketId:    ticket.ID,
+			RequesterId: requesterID,
+			Reason:      reason,
+			Status:      models.RefundRequestPending,
+			Amount:      amount,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		if payment != nil {
+			request.PaymentId = &payment.ID
+		}
+		if sale != nil {
+			request.SaleId = &sale.ID
+		}
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+
+		if err := cancelTicketTransfers(tx.Where("ticket_id = ?", ticket.ID)); err != nil {
+			return err
+		}
+		return withdrawTicketListings(tx, ticket.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// processRefundRequest approuve une demande de remboursement, rembourse le paiement auprès du
+// prestataire puis invalide le ticket et remet la place en vente. Le remboursement d'une revente
+// rend le ticket au vendeur. Une demande en échec peut être relancée : l'identifiant du
+// remboursement est conservé pour ne jamais rembourser deux fois.
+func processRefundRequest(ctx context.Context, db *gorm.DB, requestID, reviewerID uuid.UUID) (*models.RefundRequest, error) {
+	var request models.RefundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", requestID).First(&request).Error; err != nil {
+			return err
+		}
+		if request.Status != models.RefundRequestPending && request.Status != models.RefundRequestFailed {
+			return errRefundNotPending
+		}
+
+		now := time.Now()
+		request.Status = models.RefundRequestApproved
+		request.ReviewerId = &reviewerID
+		request.ReviewedAt = &now
+		request.UpdatedAt = now
+		return tx.Save(&request).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := executeRefund(ctx, db, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// executeRefund rembourse une demande approuvée auprès du prestataire puis l'applique. La clé
+// d'idempotence dérivée de la demande permet de la relancer après une interruption.
+func executeRefund(ctx context.Context, db *gorm.DB, request *models.RefundRequest) error {
+	if request.PaymentId != nil && request.ProviderRefundId == "" && request.Amount.IsPositive() {
+		var payment models.Payment
+		if err := db.Where("id = ?", request.PaymentId).First(&payment).Error; err != nil {
+			return markRefundFailed(db, request, err)
+		}
+		refund, err := payments.GetProvider().Refund(ctx, payment.ProviderPaymentId, request.Amount.Amount, "refund_"+request.ID.String())
+		if err != nil {
+			return markRefundFailed(db, request, errors.Join(errRefundProvider, err))
+		}
+		request.ProviderRefundId = refund.ID
+		if err := db.Model(request).Updates(map[string]interface{}{"provider_refund_id": refund.ID, "updated_at": time.Now()}).Error; err != nil {
+			return markRefundFailed(db, request, err)
+		}
+	}
+
+	if err := completeRefund(db, request); err != nil {
+		return markRefundFailed(db, request, err)
+	}
+	return nil
+}
+
+// markRefundFailed passe une demande en échec et renvoie l'erreur d'origine
+func markRefundFailed(db *gorm.DB, request *models.RefundRequest, cause error) error {
+	request.Status = models.RefundRequestFailed
+	db.Model(request).Updates(map[string]interface{}{"status": models.RefundRequestFailed, "updated_at": time.Now()})
+	return cause
+}
+
+// completeRefund applique un remboursement effectué : le ticket est invalidé et sa place remise
+// en vente, ou rendu au vendeur s'il provenait d'une revente, et le paiement est mis à jour
+func completeRefund(db *gorm.DB, request *models.RefundRequest) error {
+	var freedCategoryID *uuid.UUID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Le verrou sur la demande empêche d'appliquer deux fois le même remboursement
+		var locked models.RefundRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", request.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		if locked.Status == models.RefundRequestCompleted {
+			*request = locked
+			return nil
+		}
+
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", request.TicketId).First(&ticket).Error; err != nil {
+			return err
+		}
+
+		if request.SaleId != nil {
+			var sale models.Sale
+			if err := tx.Where("id = ?", request.SaleId).First(&sale).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.TicketListing{}).Where("id = ?", sale.TicketListingId).
+				Updates(map[string]interface{}{"status": models.ListingRefunded, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+			if err := reverseSaleEscrow(tx, sale.ID, models.EscrowReverseRefund); err != nil {
+				return err
+			}
+			if err := recordOwnership(tx, models.TicketOwnership{
+				TicketId:       ticket.ID,
+				UserId:         sale.SellerId,
+				PreviousUserId: &ticket.UserId,
+				Source:         models.OwnershipRefund,
+				Price:          sale.FinalPrice,
+				SaleId:         &sale.ID,
+			}); err != nil {
+				return err
+			}
+			ticket.UserId = sale.SellerId
+		} else {
+			concertCategory, err := lockConcertCategory(tx, ticket.ConcertCategoryId)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ConcertCategory{}).Where("id = ?", ticket.ConcertCategoryId).
+				Updates(map[string]interface{}{
+					"sold_tickets": gorm.Expr("GREATEST(COALESCE(sold_tickets, 0) - 1, 0)"),
+					"updated_at":   time.Now(),
+				}).Error; err != nil {
+				return err
+			}
+			if err := syncSoldOut(tx, &concertCategory.ConcertId); err != nil {
+				return err
+			}
+			if err := reverseTicketEscrows(tx, ticket.ID, models.EscrowReverseTicketInvalid); err != nil {
+				return err
+			}
+			if err := freeSeat(tx, ticket.ID); err != nil {
+				return err
+			}
+			ticket.Status = models.TicketRefunded
+			freedCategoryID = &ticket.ConcertCategoryId
+		}
+
+		ticket.UpdatedAt = time.Now()
+		if err := tx.Save(&ticket).Error; err != nil {
+			return err
+		}
+
+		if request.PaymentId != nil {
+			var payment models.Payment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", request.PaymentId).First(&payment).Error; err != nil {
+				return err
+			}
+			payment.RefundedAmount += request.Amount.Amount
+			if payment.RefundedAmount >= payment.Amount.Amount {
+				payment.Status = models.PaymentRefunded
+			}
+			payment.UpdatedAt = time.Now()
+			if err := tx.Save(&payment).Error; err != nil {
+				return err
+			}
+			if err := recordRefundInLedger(tx, request, &ticket); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		request.Status = models.RefundRequestCompleted
+		request.CompletedAt = &now
+		request.UpdatedAt = now
+		return tx.Save(request).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// La place remise en vente est d'abord proposée à la liste d'attente
+	if freedCategoryID != nil {
+		go alertWaitlistForInventory(*freedCategoryID)
+	}
+	return nil
+}
+
+// paymentTicketIDs renvoie les tickets obtenus par un paiement honoré : le ticket émis, ceux de la
+// commande ou le ticket revendu
+func paymentTicketIDs(db *gorm.DB, payment *models.Payment) ([]uuid.UUID, error) {
+	var ticketIDs []uuid.UUID
+	switch {
+	case payment.TicketId != nil:
+		ticketIDs = append(ticketIDs, *payment.TicketId)
+	case payment.OrderId != nil:
+		err := db.Model(&models.Ticket{}).
+			Where("order_item_id IN (?)", db.Model(&models.OrderItem{}).Select("id").Where("order_id = ?", payment.OrderId)).
+			Pluck("id", &ticketIDs).Error
+		if err != nil {
+			return nil, err
+		}
+	case payment.SaleId != nil:
+		err := db.Model(&models.TicketListing{}).
+			Where("id = (?)", db.Model(&models.Sale{}).Select("ticket_listing_id").Where("id = ?", payment.SaleId)).
+			Pluck("ticket_id", &ticketIDs).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ticketIDs, nil
+}
+
+// providerRefundRequest prépare le remboursement d'un ticket déjà remboursé chez le prestataire.
+// Une demande en cours pour le ticket est reprise, sinon une demande approuvée est créée. Aucune
+// demande n'est renvoyée si le ticket n'est plus valide ou si son détenteur ne l'a pas obtenu par
+// ce paiement.
+func providerRefundRequest(db *gorm.DB, ticketID uuid.UUID, payment *models.Payment, providerRefundID string) (*models.RefundRequest, error) {
+	var request *models.RefundRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ticketID).First(&ticket).Error; err != nil {
+			return err
+		}
+		if ticket.Status != models.TicketValid {
+			return nil
+		}
+
+		now := time.Now()
+		var existing models.RefundRequest
+		err := tx.Where("ticket_id = ? AND status IN ?", ticket.ID,
+			[]string{models.RefundRequestPending, models.RefundRequestApproved, models.RefundRequestFailed}).
+			First(&existing).Error
+		if err == nil {
+			if existing.PaymentId == nil || *existing.PaymentId != payment.ID {
+				return nil
+			}
+			existing.Status = models.RefundRequestApproved
+			if existing.ProviderRefundId == "" {
+				existing.ProviderRefundId = providerRefundID
+			}
+			existing.UpdatedAt = now
+			request = &existing
+			return tx.Save(request).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		ticketPayment, sale, amount, err := findTicketPayment(tx, &ticket)
+		if err != nil {
+			return err
+		}
+		if ticketPayment == nil || ticketPayment.ID != payment.ID {
+			return nil
+		}
+
+		request = &models.RefundRequest{
+			ID:               uuid.New(),
+			TicketId:         ticket.ID,
+			RequesterId:      payment.UserId,
+			Reason:           "Refunded from the payment provider",
+			Status:           models.RefundRequestApproved,
+			Amount:           amount,
+			PaymentId:        &payment.ID,
+			ReviewedAt:       &now,
+			ProviderRefundId: providerRefundID,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		if sale != nil {
+			request.SaleId = &sale.ID
+		}
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		if err := cancelTicketTransfers(tx.Where("ticket_id = ?", ticket.ID)); err != nil {
+			return err
+		}
+		return withdrawTicketListings(tx, ticket.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// applyProviderRefund applique un remboursement intégral fait directement chez le prestataire,
+// depuis le tableau de bord Stripe par exemple. Chaque ticket encore détenu grâce au paiement
+// passe par completeRefund comme une demande approuvée : le ticket est invalidé ou rendu au
+// vendeur, la place est libérée et le grand livre contrepassé. Renvoie le nombre de tickets
+// remboursés.
+func applyProviderRefund(db *gorm.DB, providerPaymentID, providerRefundID string) (int, error) {
+	var payment models.Payment
+	if err := db.Where("provider_payment_id = ?", providerPaymentID).First(&payment).Error; err != nil {
+		return 0, err
+	}
+
+	refunded := 0
+	if payment.Status == models.PaymentSucceeded {
+		ticketIDs, err := paymentTicketIDs(db, &payment)
+		if err != nil {
+			return 0, err
+		}
+		for _, ticketID := range ticketIDs {
+			request, err := providerRefundRequest(db, ticketID, &payment, providerRefundID)
+			if err != nil {
+				return refunded, err
+			}
+			if request == nil {
+				continue
+			}
+			if err := completeRefund(db, request); err != nil {
+				return refunded, markRefundFailed(db, request, err)
+			}
+			refunded++
+		}
+	}
+
+	// Le paiement est remboursé en entier, y compris la part qui ne correspond plus à un ticket
+	return refunded, db.Model(&models.Payment{}).Where("id = ? AND status <> ?", payment.ID, models.PaymentRefunded).
+		Updates(map[string]interface{}{
+			"status":          models.PaymentRefunded,
+			"refunded_amount": payment.Amount.Amount,
+			"updated_at":      time.Now(),
+		}).Error
+}
+
+// @Summary		Demande un remboursement
+// @Description	Ouvre une demande de remboursement pour un ticket. Une demande ouverte par un administrateur est approuvée immédiatement.
+// @ID				create-refund-request
+// @Tags			Refunds
+// @Accept			json
+// @Produce		json
+// @Param			body	body		object	true	"ticketId et reason"
+// @Success		201		{object}	models.RefundRequest
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Failure		502		{object}	map[string]string
+// @Router			/refunds [post]
+// @Security		Bearer
+func CreateRefundRequest(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userIdFromToken, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	userID, err := uuid.Parse(userIdFromToken)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid user ID format"})
+	}
+	isAdmin := claims["role"] == "admin"
+
+	var reqBody struct {
+		TicketId uuid.UUID `json:"ticketId"`
+		Reason   string    `json:"reason"`
+	}
+	if err := c.Bind(&reqBody); err != nil || reqBody.TicketId == uuid.Nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	request, err := createRefundRequest(db, reqBody.TicketId, userID, reqBody.Reason, isAdmin)
+	if err != nil {
+		return c.JSON(refundErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	c.Logger().Infof("event=RefundRequested refund_id=%s ticket_id=%s user_id=%s timestamp=%s", request.ID, request.TicketId, userID, time.Now().Format(time.RFC3339))
+
+	if isAdmin {
+		request, err = processRefundRequest(c.Request().Context(), db, request.ID, userID)
+		if err != nil {
+			c.Logger().Errorf("event=RefundFailed ticket_id=%s error=%s timestamp=%s", reqBody.TicketId, err, time.Now().Format(time.RFC3339))
+			return c.JSON(refundErrorStatus(err), map[string]string{"error": err.Error()})
+		}
+		c.Logger().Infof("event=RefundCompleted refund_id=%s timestamp=%s", request.ID, time.Now().Format(time.RFC3339))
+	}
+
+	return c.JSON(http.StatusCreated, request)
+}
+
+// @Summary		Liste les demandes de remboursement
+// @Description	Renvoie les demandes de l'utilisateur, ou toutes les demandes pour un administrateur
+// @ID				get-refund-requests
+// @Tags			Refunds
+// @Produce		json
+// @Param			status	query		string	false	"Filtre sur le statut"
+// @Success		200		{array}		models.RefundRequest
+// @Failure		401		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/refunds [get]
+// @Security		Bearer
+func GetRefundRequests(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	query := db.Preload("Ticket").Preload("Ticket.ConcertCategory").Preload("Ticket.ConcertCategory.Concert")
+	if claims["role"] != "admin" {
+		query = query.Where("requester_id = ?", userID)
+	}
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.RefundRequest
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve refund requests"})
+	}
+
+	return c.JSON(http.StatusOK, requests)
+}
+
+// @Summary		Approuve une demande de remboursement
+// @Description	Rembourse le paiement, invalide le ticket et remet la place en vente. Relance aussi une demande en échec.
+// @ID				approve-refund-request
+// @Tags			Refunds
+// @Produce		json
+// @Param			id	path		string	true	"ID de la demande"	format(uuid)
+// @Success		200	{object}	models.RefundRequest
+// @Failure		400	{object}	map[string]string
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Failure		502	{object}	map[string]string
+// @Router			/refunds/{id}/approve [post]
+// @Security		Bearer
+func ApproveRefundRequest(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	adminIdFromToken, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	adminID, err := uuid.Parse(adminIdFromToken)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid user ID format"})
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	request, err := processRefundRequest(c.Request().Context(), db, requestID, adminID)
+	if err != nil {
+		c.Logger().Errorf("event=RefundFailed refund_id=%s error=%s timestamp=%s", requestID, err, time.Now().Format(time.RFC3339))
+		return c.JSON(refundErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	c.Logger().Infof("event=RefundCompleted refund_id=%s admin_id=%s timestamp=%s", request.ID, adminID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, request)
+}
+
+// @Summary		Refuse une demande de remboursement
+// @Description	Refuse une demande de remboursement en attente
+// @ID				reject-refund-request
+// @Tags			Refunds
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID de la demande"	format(uuid)
+// @Param			body	body		object	false	"note"
+// @Success		200		{object}	models.RefundRequest
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/refunds/{id}/reject [post]
+// @Security		Bearer
+func RejectRefundRequest(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	adminIdFromToken, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	adminID, err := uuid.Parse(adminIdFromToken)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid user ID format"})
+	}
+
+	var reqBody struct {
+		Note string `json:"note"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	var request models.RefundRequest
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", c.Param("id")).First(&request).Error; err != nil {
+			return err
+		}
+		if request.Status != models.RefundRequestPending {
+			return errRefundNotPending
+		}
+
+		now := time.Now()
+		request.Status = models.RefundRequestRejected
+		request.ReviewerId = &adminID
+		request.ReviewNote = reqBody.Note
+		request.ReviewedAt = &now
+		request.UpdatedAt = now
+		return tx.Save(&request).Error
+	})
+	if err != nil {
+		return c.JSON(refundErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	c.Logger().Infof("event=RefundRejected refund_id=%s admin_id=%s timestamp=%s", request.ID, adminID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, request)
+}
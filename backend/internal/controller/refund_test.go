@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"testing"
+	"time"
+	"weezemaster/internal/models"
+	"weezemaster/internal/testdb"
+
+	"github.com/google/uuid"
+)
+
+func TestPaidAcquisition(t *testing.T) {
+	db := testdb.Open(t, &models.TicketOwnership{})
+
+	seller, buyer, friend := uuid.New(), uuid.New(), uuid.New()
+	sale := uuid.New()
+	primary := models.TicketOwnership{UserId: seller, Source: models.OwnershipPrimary}
+	resale := models.TicketOwnership{UserId: buyer, PreviousUserId: &seller, Source: models.OwnershipResale, SaleId: &sale}
+	gift := models.TicketOwnership{UserId: friend, PreviousUserId: &buyer, Source: models.OwnershipGift}
+	returned := models.TicketOwnership{UserId: seller, PreviousUserId: &friend, Source: models.OwnershipRefund, SaleId: &sale}
+	created := models.TicketOwnership{UserId: friend, Source: models.OwnershipAdmin}
+
+	tests := []struct {
+		name    string
+		history []models.TicketOwnership
+		holder  uuid.UUID
+		want    *models.TicketOwnership
+	}{
+		{"primary buyer", []models.TicketOwnership{primary}, seller, &primary},
+		{"resale buyer", []models.TicketOwnership{primary, resale}, buyer, &resale},
+		{"gift after a resale goes back to the resale", []models.TicketOwnership{primary, resale, gift}, friend, &resale},
+		{"refunded resale goes back to the seller's purchase", []models.TicketOwnership{primary, resale, gift, returned}, seller, &primary},
+		{"ticket created by an administrator", []models.TicketOwnership{created}, friend, nil},
+		{"no history", nil, seller, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := models.Ticket{ID: uuid.New(), UserId: tt.holder}
+			start := time.Now().Add(-time.Hour)
+			for i, entry := range tt.history {
+				entry.TicketId = ticket.ID
+				entry.AcquiredAt = start.Add(time.Duration(i) * time.Minute)
+				if err := recordOwnership(db, entry); err != nil {
+					t.Fatalf("record ownership: %v", err)
+				}
+			}
+
+			got, err := paidAcquisition(db, &ticket)
+			if err != nil {
+				t.Fatalf("paidAcquisition: %v", err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("paidAcquisition() = %s by %s, want none", got.Source, got.UserId)
+				}
+				return
+			}
+			if got == nil || got.Source != tt.want.Source || got.UserId != tt.want.UserId {
+				t.Errorf("paidAcquisition() = %+v, want %s by %s", got, tt.want.Source, tt.want.UserId)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"time"
 	"weezemaster/internal/database"
@@ -8,18 +9,187 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errListingNotAvailable = errors.New("ticket listing is not available")
+var errBuyerIsOwner = errors.New("buyer already owns the ticket")
+
+// issueTicket décompte une place de la catégorie et émet le ticket correspondant pour l'utilisateur
+func issueTicket(tx *gorm.DB, userID, concertCategoryID uuid.UUID) (*models.Ticket, error) {
+	// Verrouille la catégorie et décompte la place dans la même transaction que la création du ticket
+	concertCategory, err := reserveInventory(tx, concertCategoryID, userID, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err := takeHeldSeats(tx, userID, concertCategoryID, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := models.Ticket{
+		ID:                uuid.New(),
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		UserId:            userID,
+		ConcertCategoryId: concertCategoryID,
+		MaxPrice:          concertCategory.Price,
+		Status:            models.TicketValid,
+	}
+	if len(seats) > 0 {
+		ticket.ConcertSeatId = &seats[0].ID
+	}
+
+	if err := tx.Create(&ticket).Error; err != nil {
+		return nil, err
+	}
+
+	if ticket.ConcertSeatId != nil {
+		if err := sellSeat(tx, *ticket.ConcertSeatId, ticket.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordOwnership(tx, models.TicketOwnership{
+		TicketId: ticket.ID,
+		UserId:   userID,
+		Source:   models.OwnershipPrimary,
+		Price:    concertCategory.Price,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := consumeTicketHold(tx, userID, concertCategoryID, 1); err != nil {
+		return nil, err
+	}
+
+	if err := convertWaitlistAlerts(tx, userID, tx.Where("concert_category_id = ?", concertCategoryID)); err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+// completeResale transfère le ticket d'une annonce à l'acheteur au prix donné, enregistre la vente
+// et place le prix sous séquestre jusqu'au concert
+func completeResale(tx *gorm.DB, ticketListingID, buyerID uuid.UUID, price models.Money) (*models.Sale, error) {
+	var ticketListing models.TicketListing
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ticketListingID).First(&ticketListing).Error; err != nil {
+		return nil, err
+	}
+	if !isListingPurchasable(&ticketListing, buyerID) {
+		return nil, errListingNotAvailable
+	}
+
+	var ticket models.Ticket
+	if err := tx.Where("id = ?", ticketListing.TicketId).First(&ticket).Error; err != nil {
+		return nil, err
+	}
+	if ticket.UserId == buyerID {
+		return nil, errBuyerIsOwner
+	}
+	if ticket.Status != models.TicketValid {
+		return nil, errListingNotAvailable
+	}
+
+	sale := models.Sale{
+		ID:              uuid.New(),
+		FinalPrice:      price,
+		TicketListingId: ticketListing.ID,
+		BuyerId:         buyerID,
+		SellerId:        ticket.UserId,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+
+	if err := tx.Create(&sale).Error; err != nil {
+		return nil, err
+	}
+
+	if err := holdResaleFunds(tx, &sale, &ticket); err != nil {
+		return nil, err
+	}
+
+	if err := transitionListing(tx, &ticketListing, models.ListingSold); err != nil {
+		return nil, err
+	}
+
+	if err := convertWaitlistAlerts(tx, buyerID, tx.Where("ticket_listing_id = ?", ticketListing.ID)); err != nil {
+		return nil, err
+	}
+
+	if err := recordOwnership(tx, models.TicketOwnership{
+		TicketId:       ticket.ID,
+		UserId:         buyerID,
+		PreviousUserId: &ticket.UserId,
+		Source:         models.OwnershipResale,
+		Price:          price,
+		SaleId:         &sale.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	ticket.UserId = buyerID
+	ticket.MaxPrice = price
+	ticket.UpdatedAt = time.Now()
+
+	if err := tx.Save(&ticket).Error; err != nil {
+		return nil, err
+	}
+
+	return &sale, nil
+}
+
+// completeConversationResale conclut au prix de l'offre acceptée la revente négociée dans une
+// conversation puis supprime la conversation
+func completeConversationResale(tx *gorm.DB, conversationID, buyerID uuid.UUID) (*models.Sale, error) {
+	var conversation models.Conversation
+	if err := tx.Where("id = ?", conversationID).First(&conversation).Error; err != nil {
+		return nil, err
+	}
+	if conversation.BuyerId != buyerID {
+		return nil, errNotConversationParty
+	}
+
+	offer, err := acceptedOffer(tx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sale, err := completeResale(tx, conversation.TicketListingId, buyerID, offer.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.Message{}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.Offer{}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Delete(&conversation).Error; err != nil {
+		return nil, err
+	}
+
+	return sale, nil
+}
+
 // @Summary		Create a reservation
-// @Description	Create a reservation for a concert category
+// @Description	Return the ticket issued for a confirmed payment on a concert category
 // @ID				create-reservation
 // @Tags			Reservation
 // @Accept			json
 // @Produce		json
-// @Param			body	body string true "Id de la catégorie de concert" format(uuid)
-// @Success		201		{object}	models.Ticket
+// @Param			body	body string true "Id de la catégorie de concert et du payment intent" format(uuid)
+// @Success		200		{object}	models.Ticket
 // @Failure		400		{object}	map[string]string
 // @Failure		401		{object}	map[string]string
+// @Failure		402		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/reservation [post]
 // @Security		Bearer
@@ -48,6 +218,7 @@ func CreateReservation(c echo.Context) error {
 
 	var reqBody struct {
 		ConcertCategoryId uuid.UUID `json:"concertCategoryId"`
+		PaymentIntentId   string    `json:"paymentIntentId"`
 	}
 	if err := c.Bind(&reqBody); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
@@ -58,44 +229,14 @@ func CreateReservation(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
 	}
 
-	var concertCategory models.ConcertCategory
-	if err := db.Where("id = ?", reqBody.ConcertCategoryId).First(&concertCategory).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Concert category not found"})
-	}
-
-	if concertCategory.SoldTickets >= concertCategory.AvailableTickets {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No tickets available for this category"})
-	}
-
-	tx := db.Begin()
-	if tx.Error != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
-	}
-
-	ticket := models.Ticket{
-		ID:                uuid.New(),
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
-		UserId:            user.ID,
-		ConcertCategoryId: reqBody.ConcertCategoryId,
-		MaxPrice:          concertCategory.Price,
-	}
-
-	if err := tx.Create(&ticket).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create ticket"})
-	}
-
-	concertCategory.SoldTickets += 1
-	concertCategory.UpdatedAt = time.Now()
-
-	if err := tx.Save(&concertCategory).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update sold tickets"})
+	payment, status, err := confirmPayment(c.Request().Context(), db, user.ID, models.PaymentItemConcertCategory, reqBody.ConcertCategoryId, reqBody.PaymentIntentId)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	var ticket models.Ticket
+	if err := db.Where("id = ?", payment.TicketId).First(&ticket).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Ticket not found"})
 	}
 
 	c.Logger().Infof("event=TicketPurchased ticket_id=%s user_id=%s timestamp=%s", ticket.ID, user.ID, time.Now().Format(time.RFC3339))
@@ -103,16 +244,18 @@ func CreateReservation(c echo.Context) error {
 }
 
 // @Summary		Create a ticket listing reservation
-// @Description	Create a reservation for a ticket listing
+// @Description	Return the sale completed for a confirmed payment on a ticket listing
 // @ID				create-ticket-listing-reservation
 // @Tags			Reservation
 // @Accept			json
 // @Produce		json
 // @Param			id		path		string									true	"Ticket listing ID"	format(uuid)
-// @Param			body	body		string	true	"Id du ticket listing" format(uuid)
-// @Success		201		{object}	models.Sale
+// @Param			body	body		string	true	"Id du ticket listing et du payment intent" format(uuid)
+// @Success		200		{object}	models.Sale
 // @Failure		400		{object}	map[string]string
 // @Failure		401		{object}	map[string]string
+// @Failure		402		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/ticket_listing_reservation/{id} [post]
 // @Security		Bearer
@@ -141,6 +284,7 @@ func CreateTicketListingReservation(c echo.Context) error {
 
 	var reqBody struct {
 		TicketListingId uuid.UUID `json:"ticketListingId"`
+		PaymentIntentId string    `json:"paymentIntentId"`
 	}
 	if err := c.Bind(&reqBody); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
@@ -151,71 +295,32 @@ func CreateTicketListingReservation(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
 	}
 
-	var ticketListing models.TicketListing
-	if err := db.Where("id = ?", reqBody.TicketListingId).First(&ticketListing).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Ticket listing not found"})
-	}
-
-	var ticket models.Ticket
-	if err := db.Where("id = ?", ticketListing.TicketId).First(&ticket).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Ticket not found"})
-	}
-
-	tx := db.Begin()
-	if tx.Error != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
-	}
-
-	sale := models.Sale{
-		ID:              uuid.New(),
-		FinalPrice:      ticketListing.Price,
-		TicketListingId: ticketListing.ID,
-		BuyerId:         user.ID,
-		SellerId:        ticket.UserId,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
-	}
-
-	if err := tx.Create(&sale).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create sale"})
-	}
-
-	ticketListing.Status = "sold"
-	ticketListing.UpdatedAt = time.Now()
-
-	if err := tx.Save(&ticketListing).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update ticket listing"})
-	}
-
-	ticket.UserId = user.ID
-	ticket.MaxPrice = ticketListing.Price
-	ticket.UpdatedAt = time.Now()
-
-	if err := tx.Save(&ticket).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update ticket"})
+	payment, status, err := confirmPayment(c.Request().Context(), db, user.ID, models.PaymentItemTicketListing, reqBody.TicketListingId, reqBody.PaymentIntentId)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	var sale models.Sale
+	if err := db.Where("id = ?", payment.SaleId).First(&sale).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Sale not found"})
 	}
 
 	return c.JSON(http.StatusOK, sale)
 }
 
 // @Summary		Create a ticket listing reservation from conversation
-// @Description	Create a reservation for a ticket listing from a conversation
+// @Description	Return the sale completed for a confirmed payment on a conversation price
 // @ID				create-ticket-listing-reservation-from-conversation
 // @Tags			Reservation
 // @Accept			json
 // @Produce		json
 // @Param			id		path		string													true	"Conversation ID"	format(uuid)
-// @Param			body	body	string	true	"id de la conversation" format(uuid)
-// @Success		201		{object}	models.Sale
+// @Param			body	body	string	true	"id de la conversation et du payment intent" format(uuid)
+// @Success		200		{object}	models.Sale
 // @Failure		400		{object}	map[string]string
 // @Failure		401		{object}	map[string]string
+// @Failure		402		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/ticket_listing_reservation_conversation/{id} [post]
 // @Security		Bearer
@@ -243,7 +348,8 @@ func CreateTicketListingReservationFromConversation(c echo.Context) error {
 	}
 
 	var reqBody struct {
-		ConversationId uuid.UUID `json:"conversationId"`
+		ConversationId  uuid.UUID `json:"conversationId"`
+		PaymentIntentId string    `json:"paymentIntentId"`
 	}
 	if err := c.Bind(&reqBody); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
@@ -254,70 +360,14 @@ func CreateTicketListingReservationFromConversation(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
 	}
 
-	var conversation models.Conversation
-	if err := db.Where("id = ?", reqBody.ConversationId).First(&conversation).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Conversation not found"})
-	}
-
-	var ticketListing models.TicketListing
-	if err := db.Where("id = ?", conversation.TicketListingId).First(&ticketListing).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Ticket listing not found"})
-	}
-
-	var ticket models.Ticket
-	if err := db.Where("id = ?", ticketListing.TicketId).First(&ticket).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Ticket not found"})
-	}
-
-	tx := db.Begin()
-	if tx.Error != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
-	}
-
-	sale := models.Sale{
-		ID:              uuid.New(),
-		FinalPrice:      conversation.Price,
-		TicketListingId: ticketListing.ID,
-		BuyerId:         user.ID,
-		SellerId:        ticket.UserId,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
-	}
-
-	if err := tx.Create(&sale).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create sale"})
-	}
-
-	ticketListing.Status = "sold"
-	ticketListing.UpdatedAt = time.Now()
-
-	if err := tx.Save(&ticketListing).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update ticket listing"})
-	}
-
-	ticket.UserId = user.ID
-	ticket.MaxPrice = conversation.Price
-	ticket.UpdatedAt = time.Now()
-
-	if err := tx.Save(&ticket).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update ticket"})
-	}
-
-	if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.Message{}).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete messages"})
-	}
-
-	if err := tx.Delete(&conversation).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete conversation"})
+	payment, status, err := confirmPayment(c.Request().Context(), db, user.ID, models.PaymentItemConversation, reqBody.ConversationId, reqBody.PaymentIntentId)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	var sale models.Sale
+	if err := db.Where("id = ?", payment.SaleId).First(&sale).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Sale not found"})
 	}
 
 	return c.JSON(http.StatusOK, sale)
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	searchDefaultLimit       = 20
+	searchMaxLimit           = 50
+	autocompleteDefaultLimit = 8
+	searchMaxQueryLength     = 200
+)
+
+// SearchResult est un résultat de recherche, quel que soit son type. Detail est le lieu d'un
+// concert ; Date et Image ne sont renseignés que quand le type en a.
+type SearchResult struct {
+	Type   string     `json:"type"`
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Detail string     `json:"detail,omitempty"`
+	Date   *time.Time `json:"date,omitempty"`
+	Image  string     `json:"image,omitempty"`
+	Rank   float64    `json:"rank"`
+}
+
+// searchSources décrit, pour chaque type recherché, la table et les colonnes renvoyées.
+// Les brouillons et les concerts annulés ou passés ne sont pas proposés.
+var searchSources = []struct {
+	kind    string
+	table   string
+	columns string
+	filter  string
+}{
+	{"concert", "concerts", "concerts.location AS detail, concerts.date AS date, COALESCE(concerts.image, '') AS image",
+		"concerts.status NOT IN ('" + models.ConcertDraft + "', '" + models.ConcertCancelled + "') AND concerts.date >= now()"},
+	{"artist", "artists", "'' AS detail, NULL::timestamptz AS date, '' AS image", ""},
+	{"organization", "organizations", "'' AS detail, NULL::timestamptz AS date, COALESCE(organizations.image, '') AS image", ""},
+	{"interest", "interests", "'' AS detail, NULL::timestamptz AS date, '' AS image", ""},
+}
+
+// autocompleteQuery transforme la saisie en requête de préfixes (« conc metal » devient
+// « conc:* & metal:* ») en ne gardant que lettres et chiffres
+func autocompleteQuery(q string) string {
+	terms := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// @Summary		Recherche
+// @Description	Recherche plein texte dans les concerts (nom, description, lieu), les artistes, les organisations et les centres d'intérêt. La recherche gère le français et l'anglais, ignore les accents, tolère les fautes de frappe et renvoie les résultats par pertinence. Le mode autocomplete cherche les mots commençant par la saisie.
+// @ID				search
+// @Tags			Search
+// @Produce		json
+// @Param			q		query		string	true	"Texte recherché"
+// @Param			mode	query		string	false	"full (par défaut) ou autocomplete"
+// @Param			types	query		string	false	"Types recherchés, séparés par des virgules : concert, artist, organization, interest (tous par défaut)"
+// @Param			limit	query		int		false	"Nombre de résultats (20 par défaut, 8 en autocomplétion, 50 au maximum)"
+// @Success		200		{array}		SearchResult
+// @Failure		400		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/search [get]
+func Search(c echo.Context) error {
+	db := database.GetDB()
+
+	q := strings.TrimSpace(c.QueryParam("q"))
+	if q == "" || len(q) > searchMaxQueryLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "q must be between 1 and 200 characters"})
+	}
+
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = "full"
+	}
+	if mode != "full" && mode != "autocomplete" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid mode value"})
+	}
+
+	defaultLimit := searchDefaultLimit
+	if mode == "autocomplete" {
+		defaultLimit = autocompleteDefaultLimit
+	}
+	limit, err := parsePositiveQueryInt(c, "limit", defaultLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+
+	types := make(map[string]bool)
+	if value := c.QueryParam("types"); value != "" {
+		for _, kind := range strings.Split(value, ",") {
+			types[strings.TrimSpace(kind)] = true
+		}
+	}
+
+	// Plein texte : la saisie est analysée dans les trois configurations du tsvector.
+	// Autocomplétion : chaque mot est un préfixe, cherché sans racinisation.
+	tsquery := "websearch_to_tsquery('french', immutable_unaccent(@q)) || websearch_to_tsquery('english', immutable_unaccent(@q)) || websearch_to_tsquery('simple', immutable_unaccent(@q))"
+	args := map[string]interface{}{"q": q, "limit": limit}
+	if mode == "autocomplete" {
+		prefixes := autocompleteQuery(q)
+		if prefixes == "" {
+			return c.JSON(http.StatusOK, []SearchResult{})
+		}
+		tsquery = "to_tsquery('simple', immutable_unaccent(@prefixes))"
+		args["prefixes"] = prefixes
+	}
+
+	// Un nom proche de la saisie (similarité trigramme) compense une faute de frappe
+	var branches []string
+	for _, source := range searchSources {
+		if len(types) > 0 && !types[source.kind] {
+			continue
+		}
+		name := source.table + ".name"
+		branch := "SELECT '" + source.kind + "' AS type, " + source.table + ".id::text AS id, " + name + " AS name, " + source.columns + ", " +
+			"ts_rank_cd(" + source.table + ".search_vector, search_query.tsq) + word_similarity(search_query.term, immutable_unaccent(lower(" + name + "))) AS rank " +
+			"FROM " + source.table + ", search_query " +
+			"WHERE " + source.table + ".deleted_at IS NULL AND (" + source.table + ".search_vector @@ search_query.tsq OR search_query.term <% immutable_unaccent(lower(" + name + ")))"
+		if source.filter != "" {
+			branch += " AND " + source.filter
+		}
+		branches = append(branches, branch)
+	}
+	if len(branches) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid types value"})
+	}
+
+	sql := "WITH search_query AS (SELECT " + tsquery + " AS tsq, immutable_unaccent(lower(@q)) AS term) " +
+		"SELECT * FROM (" + strings.Join(branches, " UNION ALL ") + ") AS results ORDER BY rank DESC, name ASC LIMIT @limit"
+
+	results := []SearchResult{}
+	if err := db.Raw(sql, args).Scan(&results).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search"})
+	}
+
+	c.Logger().Infof("event=Search mode=%s results=%d timestamp=%s", mode, len(results), time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,593 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSeatUnavailable = errors.New("one or more seats are no longer available")
+var errSeatSelectionRequired = errors.New("this category has reserved seating: select your seats first")
+var errInvalidSeats = errors.New("seats must be on sale for this concert and belong to a single category")
+var errSeatingAssigned = errors.New("seating is already set up for this concert")
+var errCategoryOnSale = errors.New("a category that already has sales cannot switch to reserved seating")
+
+// Nombre de places insérées par requête lors de la création d'un plan ou d'une mise en vente
+const seatBatchSize = 500
+
+// isSeatedCategory indique si une catégorie est vendue en placement numéroté
+func isSeatedCategory(tx *gorm.DB, concertCategoryID uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&models.ConcertSeat{}).Where("concert_category_id = ?", concertCategoryID).Count(&count).Error
+	return count > 0, err
+}
+
+// holdSeats rattache au hold les places choisies par l'utilisateur, verrouillées le temps de la
+// transaction. Une place est libre si elle n'est pas vendue et que son hold éventuel n'est plus
+// actif. Sans sélection, le hold conserve les places déjà choisies dans sa catégorie, qui doivent
+// correspondre à la quantité demandée.
+func holdSeats(tx *gorm.DB, hold *models.TicketHold, seatIDs []uuid.UUID) error {
+	concertCategoryID := *hold.ConcertCategoryId
+	seated, err := isSeatedCategory(tx, concertCategoryID)
+	if err != nil {
+		return err
+	}
+	if !seated && len(seatIDs) > 0 {
+		return errInvalidSeats
+	}
+
+	// Les places d'une autre catégorie ne font plus partie du hold
+	release := tx.Model(&models.ConcertSeat{}).Where("hold_id = ?", hold.ID)
+	if len(seatIDs) == 0 {
+		release = release.Where("concert_category_id <> ?", concertCategoryID)
+	}
+	if err := release.Updates(map[string]interface{}{"hold_id": nil, "updated_at": time.Now()}).Error; err != nil {
+		return err
+	}
+	if !seated {
+		return nil
+	}
+
+	if len(seatIDs) == 0 {
+		var count int64
+		if err := tx.Model(&models.ConcertSeat{}).
+			Where("hold_id = ? AND concert_category_id = ? AND ticket_id IS NULL", hold.ID, concertCategoryID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != hold.Quantity {
+			return errSeatSelectionRequired
+		}
+		return nil
+	}
+
+	// Les places sont verrouillées dans un ordre stable pour éviter les interblocages
+	var seats []models.ConcertSeat
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", seatIDs).Order("id").Find(&seats).Error; err != nil {
+		return err
+	}
+	if len(seats) != len(seatIDs) {
+		return errInvalidSeats
+	}
+
+	var otherHolds []uuid.UUID
+	for _, seat := range seats {
+		if seat.ConcertId != hold.ConcertId || seat.ConcertCategoryId != concertCategoryID {
+			return errInvalidSeats
+		}
+		if seat.TicketId != nil {
+			return errSeatUnavailable
+		}
+		if seat.HoldId != nil {
+			otherHolds = append(otherHolds, *seat.HoldId)
+		}
+	}
+	if len(otherHolds) > 0 {
+		var active int64
+		if err := tx.Model(&models.TicketHold{}).
+			Where("id IN ? AND status = ? AND expires_at > ?", otherHolds, models.TicketHoldActive, time.Now()).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return errSeatUnavailable
+		}
+	}
+
+	return tx.Model(&models.ConcertSeat{}).Where("id IN ?", seatIDs).
+		Updates(map[string]interface{}{"hold_id": hold.ID, "updated_at": time.Now()}).Error
+}
+
+// takeHeldSeats renvoie, verrouillées, les places que l'utilisateur a bloquées dans une catégorie
+// en placement numéroté, ou nil pour une catégorie en placement libre. Une place reste attribuable
+// après l'expiration du hold tant qu'aucun autre utilisateur ne l'a bloquée.
+func takeHeldSeats(tx *gorm.DB, userID, concertCategoryID uuid.UUID, quantity int) ([]models.ConcertSeat, error) {
+	seated, err := isSeatedCategory(tx, concertCategoryID)
+	if err != nil || !seated {
+		return nil, err
+	}
+
+	var hold models.TicketHold
+	if err := tx.Where("user_id = ? AND concert_category_id = ? AND status IN ?",
+		userID, concertCategoryID, []string{models.TicketHoldActive, models.TicketHoldExpired}).
+		Order("created_at DESC").First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSeatSelectionRequired
+		}
+		return nil, err
+	}
+
+	var seats []models.ConcertSeat
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("hold_id = ? AND concert_category_id = ? AND ticket_id IS NULL", hold.ID, concertCategoryID).
+		Order("id").Limit(quantity).Find(&seats).Error; err != nil {
+		return nil, err
+	}
+	if len(seats) < quantity {
+		return nil, errSeatSelectionRequired
+	}
+	return seats, nil
+}
+
+// releaseHeldSeats remet en vente les places non vendues rattachées aux holds donnés
+func releaseHeldSeats(tx *gorm.DB, holdIDs []uuid.UUID) error {
+	return tx.Model(&models.ConcertSeat{}).Where("hold_id IN ? AND ticket_id IS NULL", holdIDs).
+		Updates(map[string]interface{}{"hold_id": nil, "updated_at": time.Now()}).Error
+}
+
+// sellSeat attribue une place au ticket émis
+func sellSeat(tx *gorm.DB, concertSeatID, ticketID uuid.UUID) error {
+	res := tx.Model(&models.ConcertSeat{}).Where("id = ? AND ticket_id IS NULL", concertSeatID).
+		Updates(map[string]interface{}{"ticket_id": ticketID, "hold_id": nil, "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errSeatUnavailable
+	}
+	return nil
+}
+
+// freeSeat remet en vente la place d'un ticket remboursé
+func freeSeat(tx *gorm.DB, ticketID uuid.UUID) error {
+	return tx.Model(&models.ConcertSeat{}).Where("ticket_id = ?", ticketID).
+		Updates(map[string]interface{}{"ticket_id": nil, "updated_at": time.Now()}).Error
+}
+
+type SeatMapRequest struct {
+	Name     string  `json:"name"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Sections []struct {
+		Name string `json:"name"`
+		Rows []struct {
+			Label string `json:"label"`
+			Seats []struct {
+				Label string  `json:"label"`
+				X     float64 `json:"x"`
+				Y     float64 `json:"y"`
+			} `json:"seats"`
+		} `json:"rows"`
+	} `json:"sections"`
+}
+
+// ConcertSeatingRequest met en vente les places d'un plan : chaque section, puis chaque place
+// précisée individuellement, est associée à une catégorie du concert. Les places sans catégorie
+// ne sont pas vendues.
+type ConcertSeatingRequest struct {
+	SeatMapId uuid.UUID               `json:"seatMapId"`
+	Sections  map[uuid.UUID]uuid.UUID `json:"sections"`
+	Seats     map[uuid.UUID]uuid.UUID `json:"seats"`
+}
+
+type ConcertSeatView struct {
+	ID                uuid.UUID `json:"id"`
+	SeatId            uuid.UUID `json:"seatId"`
+	Label             string    `json:"label"`
+	X                 float64   `json:"x"`
+	Y                 float64   `json:"y"`
+	ConcertCategoryId uuid.UUID `json:"concertCategoryId"`
+	Status            string    `json:"status"`
+}
+
+type ConcertSeatRowView struct {
+	ID    uuid.UUID         `json:"id"`
+	Label string            `json:"label"`
+	Seats []ConcertSeatView `json:"seats"`
+}
+
+type ConcertSeatSectionView struct {
+	ID   uuid.UUID            `json:"id"`
+	Name string               `json:"name"`
+	Rows []ConcertSeatRowView `json:"rows"`
+}
+
+// ConcertSeatMap est le plan d'un concert avec l'état de chaque place mise en vente
+type ConcertSeatMap struct {
+	SeatMapId  uuid.UUID                `json:"seatMapId"`
+	Name       string                   `json:"name"`
+	Width      float64                  `json:"width"`
+	Height     float64                  `json:"height"`
+	Categories []models.ConcertCategory `json:"categories"`
+	Sections   []ConcertSeatSectionView `json:"sections"`
+}
+
+// loadSeatMap récupère un plan avec ses sections, rangs et places dans l'ordre d'affichage
+func loadSeatMap(db *gorm.DB, seatMapID interface{}) (*models.SeatMap, error) {
+	var seatMap models.SeatMap
+	err := db.
+		Preload("Sections", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Sections.Rows", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Sections.Rows.Seats", func(db *gorm.DB) *gorm.DB { return db.Order("x ASC, y ASC") }).
+		Where("id = ?", seatMapID).First(&seatMap).Error
+	if err != nil {
+		return nil, err
+	}
+	return &seatMap, nil
+}
+
+// venueForOrganizer récupère une salle que l'utilisateur peut gérer
+func venueForOrganizer(c echo.Context, db *gorm.DB, venueID string) (*models.Venue, error) {
+	user, role, err := venueUser(c, db)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+	}
+	var venue models.Venue
+	if err := db.Where("id = ?", venueID).First(&venue).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Venue not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if role != "admin" && venue.OrganizationId != user.OrganizationId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Venue not found")
+	}
+	return &venue, nil
+}
+
+// @Summary		Plans d'une salle
+// @Description	Récupère les plans de salle en placement numéroté d'une salle, sans leurs places
+// @ID				get-venue-seat-maps
+// @Tags			Seating
+// @Produce		json
+// @Param			id	path		string	true	"ID de la salle"	format(uuid)
+// @Success		200	{array}		models.SeatMap
+// @Failure		500	{object}	map[string]string
+// @Router			/venues/{id}/seatmaps [get]
+func GetVenueSeatMaps(c echo.Context) error {
+	db := database.GetDB()
+
+	var seatMaps []models.SeatMap
+	if err := db.Where("venue_id = ?", c.Param("id")).Order("name ASC").Find(&seatMaps).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve seat maps"})
+	}
+	return c.JSON(http.StatusOK, seatMaps)
+}
+
+// @Summary		Récupère un plan de salle
+// @Description	Récupère un plan de salle avec ses sections, rangs et places
+// @ID				get-seat-map
+// @Tags			Seating
+// @Produce		json
+// @Param			id	path		string	true	"ID du plan"	format(uuid)
+// @Success		200	{object}	models.SeatMap
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/seatmaps/{id} [get]
+func GetSeatMap(c echo.Context) error {
+	db := database.GetDB()
+
+	seatMap, err := loadSeatMap(db, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Seat map not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, seatMap)
+}
+
+// @Summary		Créé un plan de salle
+// @Description	Créé le plan d'une salle de l'organisation : des sections composées de rangs de places, chaque place ayant des coordonnées dans le plan
+// @ID				create-seat-map
+// @Tags			Seating
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string			true	"ID de la salle"	format(uuid)
+// @Param			body	body		SeatMapRequest	true	"Plan de salle"
+// @Success		201		{object}	models.SeatMap
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/venues/{id}/seatmaps [post]
+// @Security		Bearer
+func CreateSeatMap(c echo.Context) error {
+	db := database.GetDB()
+
+	venue, err := venueForOrganizer(c, db, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	var reqBody SeatMapRequest
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if reqBody.Name == "" || len(reqBody.Sections) == 0 || reqBody.Width < 0 || reqBody.Height < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A seat map needs a name and at least one section"})
+	}
+
+	now := time.Now()
+	seatMap := models.SeatMap{
+		ID:        uuid.New(),
+		VenueId:   venue.ID,
+		Name:      reqBody.Name,
+		Width:     reqBody.Width,
+		Height:    reqBody.Height,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	seatCount := 0
+	for i, section := range reqBody.Sections {
+		if section.Name == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Every section needs a name"})
+		}
+		seatSection := models.SeatSection{ID: uuid.New(), SeatMapId: seatMap.ID, Name: section.Name, Position: i, CreatedAt: now, UpdatedAt: now}
+		for j, row := range section.Rows {
+			if row.Label == "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Every row needs a label"})
+			}
+			seatRow := models.SeatRow{ID: uuid.New(), SectionId: seatSection.ID, Label: row.Label, Position: j, CreatedAt: now, UpdatedAt: now}
+			for _, seat := range row.Seats {
+				if seat.Label == "" {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": "Every seat needs a label"})
+				}
+				seatRow.Seats = append(seatRow.Seats, models.Seat{ID: uuid.New(), RowId: seatRow.ID, Label: seat.Label, X: seat.X, Y: seat.Y, CreatedAt: now, UpdatedAt: now})
+			}
+			seatCount += len(seatRow.Seats)
+			seatSection.Rows = append(seatSection.Rows, seatRow)
+		}
+		seatMap.Sections = append(seatMap.Sections, seatSection)
+	}
+	if venue.Capacity > 0 && seatCount > venue.Capacity {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "The seat map has more seats than the venue capacity"})
+	}
+
+	if err := db.Session(&gorm.Session{CreateBatchSize: seatBatchSize}).Create(&seatMap).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create seat map"})
+	}
+
+	c.Logger().Infof("event=SeatMapCreated seat_map_id=%s venue_id=%s seats=%d timestamp=%s", seatMap.ID, venue.ID, seatCount, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, seatMap)
+}
+
+// @Summary		Met en vente les places d'un concert
+// @Description	Associe un plan de la salle du concert aux catégories du concert, par section ou place par place. Le nombre de places de chaque catégorie concernée devient le nombre de places qui lui sont associées. Possible une seule fois, avant toute vente dans ces catégories.
+// @ID				assign-concert-seating
+// @Tags			Seating
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string					true	"ID du concert"	format(uuid)
+// @Param			body	body		ConcertSeatingRequest	true	"Plan et catégories"
+// @Success		200		{object}	ConcertSeatMap
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/concerts/{id}/seating [post]
+// @Security		Bearer
+func AssignConcertSeating(c echo.Context) error {
+	db := database.GetDB()
+
+	user, role, err := venueUser(c, db)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	var reqBody ConcertSeatingRequest
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	var concert models.Concert
+	if err := db.Preload("ConcertCategories").Where("id = ?", c.Param("id")).First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if role != "admin" && concert.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Concert does not belong to your organization"})
+	}
+
+	seatMap, err := loadSeatMap(db, reqBody.SeatMapId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Seat map not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if concert.VenueId == nil || *concert.VenueId != seatMap.VenueId {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "The seat map does not belong to the concert's venue"})
+	}
+
+	categories := make(map[uuid.UUID]bool)
+	for _, concertCategory := range concert.ConcertCategories {
+		categories[concertCategory.ID] = true
+	}
+	for _, assignments := range []map[uuid.UUID]uuid.UUID{reqBody.Sections, reqBody.Seats} {
+		for _, concertCategoryID := range assignments {
+			if !categories[concertCategoryID] {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Every category must belong to the concert"})
+			}
+		}
+	}
+
+	// Une place précisée individuellement l'emporte sur sa section
+	var concertSeats []models.ConcertSeat
+	counts := make(map[uuid.UUID]int)
+	for _, section := range seatMap.Sections {
+		for _, row := range section.Rows {
+			for _, seat := range row.Seats {
+				concertCategoryID, ok := reqBody.Seats[seat.ID]
+				if !ok {
+					concertCategoryID, ok = reqBody.Sections[section.ID]
+				}
+				if !ok {
+					continue
+				}
+				concertSeats = append(concertSeats, models.ConcertSeat{
+					ID:                uuid.New(),
+					ConcertId:         concert.ID,
+					SeatId:            seat.ID,
+					ConcertCategoryId: concertCategoryID,
+					CreatedAt:         time.Now(),
+					UpdatedAt:         time.Now(),
+				})
+				counts[concertCategoryID]++
+			}
+		}
+	}
+	if len(concertSeats) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No seat is assigned to a category"})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", concert.ID).First(&concert).Error; err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&models.ConcertSeat{}).Where("concert_id = ?", concert.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errSeatingAssigned
+		}
+
+		for concertCategoryID, count := range counts {
+			concertCategory, err := lockConcertCategory(tx, concertCategoryID)
+			if err != nil {
+				return err
+			}
+			held, err := heldTickets(tx, concertCategoryID, uuid.Nil)
+			if err != nil {
+				return err
+			}
+			if concertCategory.SoldTickets > 0 || held > 0 {
+				return errCategoryOnSale
+			}
+			if err := tx.Model(&models.ConcertCategory{}).Where("id = ?", concertCategoryID).
+				Updates(map[string]interface{}{"available_tickets": count, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.CreateInBatches(&concertSeats, seatBatchSize).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Concert{}).Where("id = ?", concert.ID).Update("seat_map_id", seatMap.ID).Error
+	})
+	if err != nil {
+		if errors.Is(err, errSeatingAssigned) || errors.Is(err, errCategoryOnSale) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to set up seating"})
+	}
+
+	c.Logger().Infof("event=ConcertSeatingAssigned concert_id=%s seat_map_id=%s seats=%d timestamp=%s", concert.ID, seatMap.ID, len(concertSeats), time.Now().Format(time.RFC3339))
+	return GetConcertSeats(c)
+}
+
+// @Summary		Plan d'un concert
+// @Description	Récupère le plan de salle d'un concert en placement numéroté, avec l'état de chaque place mise en vente : available, held ou sold. Les places libres se bloquent avec POST /holds et seatIds.
+// @ID				get-concert-seats
+// @Tags			Seating
+// @Produce		json
+// @Param			id	path		string	true	"ID du concert"	format(uuid)
+// @Success		200	{object}	ConcertSeatMap
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/concerts/{id}/seats [get]
+func GetConcertSeats(c echo.Context) error {
+	db := database.GetDB()
+
+	var concert models.Concert
+	if err := db.Preload("ConcertCategories.Category").Where("id = ?", c.Param("id")).First(&concert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if concert.SeatMapId == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "This concert has no reserved seating"})
+	}
+
+	seatMap, err := loadSeatMap(db, *concert.SeatMapId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve seat map"})
+	}
+
+	var concertSeats []struct {
+		models.ConcertSeat
+		HoldActive bool
+	}
+	if err := db.Model(&models.ConcertSeat{}).
+		Select("concert_seats.*, (ticket_holds.id IS NOT NULL) AS hold_active").
+		Joins("LEFT JOIN ticket_holds ON ticket_holds.id = concert_seats.hold_id AND ticket_holds.status = ? AND ticket_holds.expires_at > ?", models.TicketHoldActive, time.Now()).
+		Where("concert_seats.concert_id = ?", concert.ID).
+		Scan(&concertSeats).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve seats"})
+	}
+	bySeat := make(map[uuid.UUID]ConcertSeatView, len(concertSeats))
+	for _, concertSeat := range concertSeats {
+		status := models.SeatAvailable
+		switch {
+		case concertSeat.TicketId != nil:
+			status = models.SeatSold
+		case concertSeat.HoldActive:
+			status = models.SeatHeld
+		}
+		bySeat[concertSeat.SeatId] = ConcertSeatView{ID: concertSeat.ID, ConcertCategoryId: concertSeat.ConcertCategoryId, Status: status}
+	}
+
+	result := ConcertSeatMap{
+		SeatMapId:  seatMap.ID,
+		Name:       seatMap.Name,
+		Width:      seatMap.Width,
+		Height:     seatMap.Height,
+		Categories: concert.ConcertCategories,
+		Sections:   []ConcertSeatSectionView{},
+	}
+	for _, section := range seatMap.Sections {
+		sectionView := ConcertSeatSectionView{ID: section.ID, Name: section.Name, Rows: []ConcertSeatRowView{}}
+		for _, row := range section.Rows {
+			rowView := ConcertSeatRowView{ID: row.ID, Label: row.Label, Seats: []ConcertSeatView{}}
+			for _, seat := range row.Seats {
+				view, onSale := bySeat[seat.ID]
+				if !onSale {
+					continue
+				}
+				view.SeatId = seat.ID
+				view.Label = seat.Label
+				view.X = seat.X
+				view.Y = seat.Y
+				rowView.Seats = append(rowView.Seats, view)
+			}
+			sectionView.Rows = append(sectionView.Rows, rowView)
+		}
+		result.Sections = append(result.Sections, sectionView)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"testing"
+	"time"
+	"weezemaster/internal/models"
+	"weezemaster/internal/testdb"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// createSeatedConcert crée un concert en vente dont chaque catégorie est vendue en placement
+// numéroté, avec autant de places que sa jauge
+func createSeatedConcert(t *testing.T, db *gorm.DB, categories, seatsPerCategory int, price models.Money) ([]models.ConcertCategory, map[uuid.UUID][]uuid.UUID) {
+	t.Helper()
+	concert := models.Concert{
+		ID:             uuid.New(),
+		Name:           "Concert",
+		Location:       "Paris",
+		Date:           time.Now().AddDate(0, 1, 0),
+		OrganizationId: uuid.New(),
+		ArtistId:       uuid.New(),
+		Status:         models.ConcertOnSale,
+	}
+	if err := db.Create(&concert).Error; err != nil {
+		t.Fatalf("create concert: %v", err)
+	}
+
+	var concertCategories []models.ConcertCategory
+	seats := make(map[uuid.UUID][]uuid.UUID)
+	for i := 1; i <= categories; i++ {
+		concertCategory := models.ConcertCategory{
+			ID:               uuid.New(),
+			ConcertId:        concert.ID,
+			CategoryId:       i,
+			AvailableTickets: seatsPerCategory,
+			Price:            price,
+		}
+		if err := db.Omit("Concert", "Category").Create(&concertCategory).Error; err != nil {
+			t.Fatalf("create concert category: %v", err)
+		}
+		concertCategories = append(concertCategories, concertCategory)
+
+		for n := 0; n < seatsPerCategory; n++ {
+			seat := models.ConcertSeat{ID: uuid.New(), ConcertId: concert.ID, SeatId: uuid.New(), ConcertCategoryId: concertCategory.ID}
+			if err := db.Create(&seat).Error; err != nil {
+				t.Fatalf("create seat: %v", err)
+			}
+			seats[concertCategory.ID] = append(seats[concertCategory.ID], seat.ID)
+		}
+	}
+	return concertCategories, seats
+}
+
+// L'achat d'une place sur un hold de trois laisse les deux autres bloquées, jusqu'à leur libération
+func TestPartialPurchaseKeepsRemainingSeatsHeld(t *testing.T) {
+	db := testdb.Open(t, &models.Concert{}, &models.ConcertCategory{}, &models.TicketHold{}, &models.ConcertSeat{},
+		&models.Ticket{}, &models.TicketOwnership{}, &models.WaitlistAlert{})
+
+	categories, seats := createSeatedConcert(t, db, 1, 4, models.NewMoney(4000, models.DefaultCurrency))
+	concertCategory := categories[0]
+	userID := uuid.New()
+
+	hold, err := holdTickets(db, userID, concertCategory.ID, 3, seats[concertCategory.ID][:3])
+	if err != nil {
+		t.Fatalf("holdTickets: %v", err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := issueTicket(tx, userID, concertCategory.ID)
+		return err
+	}); err != nil {
+		t.Fatalf("issueTicket: %v", err)
+	}
+
+	countHeld := func() int64 {
+		var held int64
+		if err := db.Model(&models.ConcertSeat{}).Where("hold_id = ?", hold.ID).Count(&held).Error; err != nil {
+			t.Fatalf("count held seats: %v", err)
+		}
+		return held
+	}
+
+	var stored models.TicketHold
+	if err := db.Where("id = ?", hold.ID).First(&stored).Error; err != nil {
+		t.Fatalf("load hold: %v", err)
+	}
+	if stored.Status != models.TicketHoldActive || stored.Quantity != 2 {
+		t.Fatalf("hold is %s with %d places, want active with 2", stored.Status, stored.Quantity)
+	}
+	if held := countHeld(); held != 2 {
+		t.Fatalf("%d seats held after the purchase, want 2", held)
+	}
+
+	if err := releaseTicketHold(db, userID, concertCategory.ID); err != nil {
+		t.Fatalf("releaseTicketHold: %v", err)
+	}
+	if held := countHeld(); held != 0 {
+		t.Errorf("%d seats still held after the release", held)
+	}
+}
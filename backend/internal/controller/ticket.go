@@ -68,7 +68,24 @@ func CreateTicket(c echo.Context) error {
 	if err := c.Bind(ticket); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	db.Create(&ticket)
+	if ticket.ID == uuid.Nil {
+		ticket.ID = uuid.New()
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ticket).Error; err != nil {
+			return err
+		}
+		return recordOwnership(tx, models.TicketOwnership{
+			TicketId:     ticket.ID,
+			UserId:       ticket.UserId,
+			Source:       models.OwnershipAdmin,
+			Price:        models.NewMoney(0, ticket.MaxPrice.Currency),
+			AssignedById: adminIDFromContext(c),
+		})
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
 	return c.JSON(http.StatusCreated, ticket)
 }
 
@@ -107,6 +124,7 @@ func UpdateTicket(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
 	}
 
+	previousUserID := ticket.UserId
 	if input.UserId != nil {
 		userID, err := uuid.Parse(*input.UserId)
 		if err != nil {
@@ -126,7 +144,26 @@ func UpdateTicket(c echo.Context) error {
 	}
 
 	ticket.UpdatedAt = time.Now()
-	db.Save(&ticket)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&ticket).Error; err != nil {
+			return err
+		}
+		if ticket.UserId == previousUserID {
+			return nil
+		}
+		// Une réattribution par un administrateur est tracée dans l'historique des détenteurs
+		return recordOwnership(tx, models.TicketOwnership{
+			TicketId:       ticket.ID,
+			UserId:         ticket.UserId,
+			PreviousUserId: &previousUserID,
+			Source:         models.OwnershipAdmin,
+			Price:          models.NewMoney(0, ticket.MaxPrice.Currency),
+			AssignedById:   adminIDFromContext(c),
+		})
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
 	return c.JSON(http.StatusOK, ticket)
 }
 
@@ -197,6 +234,8 @@ func GetUserTickets(c echo.Context) error {
 		Preload("ConcertCategory.Concert").
 		Preload("ConcertCategory.Category").
 		Preload("TicketListings").
+		Preload("CheckIn").
+		Preload("ConcertSeat.Seat.Row.Section").
 		Where("user_id = ?", user.ID).Find(&userTickets).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -0,0 +1,401 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNoTicketsAvailable = errors.New("no tickets available for this category")
+
+// Intervalle entre deux passages du sweeper des holds expirés
+const holdSweepInterval = 30 * time.Second
+
+func getHoldDuration() time.Duration {
+	return time.Duration(getConfigInt("TICKET_HOLD_DURATION_MINUTES", 10)) * time.Minute
+}
+
+// heldTickets renvoie le nombre de places bloquées par des holds actifs sur une catégorie,
+// en excluant ceux de l'utilisateur donné
+func heldTickets(db *gorm.DB, concertCategoryID, excludeUserID uuid.UUID) (int, error) {
+	var held int
+	err := db.Model(&models.TicketHold{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("concert_category_id = ? AND status = ? AND expires_at > ? AND user_id != ?",
+			concertCategoryID, models.TicketHoldActive, time.Now(), excludeUserID).
+		Scan(&held).Error
+	return held, err
+}
+
+// findActiveHold renvoie le hold actif d'un utilisateur pour un concert, s'il existe
+func findActiveHold(db *gorm.DB, userID, concertID uuid.UUID) (*models.TicketHold, error) {
+	var hold models.TicketHold
+	err := db.Where("user_id = ? AND concert_id = ? AND status = ? AND expires_at > ?",
+		userID, concertID, models.TicketHoldActive, time.Now()).
+		Order("created_at DESC").
+		First(&hold).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// findCategoryHold renvoie le hold actif d'un utilisateur sur une catégorie. À défaut, le hold
+// d'accès au concert, qui ne porte encore sur aucune catégorie, est renvoyé pour être réutilisé.
+func findCategoryHold(db *gorm.DB, userID, concertID, concertCategoryID uuid.UUID) (*models.TicketHold, error) {
+	var hold models.TicketHold
+	err := db.Where("user_id = ? AND concert_id = ? AND status = ? AND expires_at > ?",
+		userID, concertID, models.TicketHoldActive, time.Now()).
+		Where("concert_category_id = ? OR concert_category_id IS NULL", concertCategoryID).
+		Order("concert_category_id IS NULL, created_at DESC").
+		First(&hold).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// grantQueueHold crée le hold d'accès d'un utilisateur qui sort de la file d'attente
+func grantQueueHold(userID, concertID string) (*models.TicketHold, error) {
+	db := database.GetDB()
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	concertUUID, err := uuid.Parse(concertID)
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := findActiveHold(db, userUUID, concertUUID)
+	if err == nil {
+		return hold, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hold = &models.TicketHold{
+		ID:        uuid.New(),
+		UserId:    userUUID,
+		ConcertId: concertUUID,
+		Status:    models.TicketHoldActive,
+		ExpiresAt: time.Now().Add(getHoldDuration()),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.Create(hold).Error; err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// holdTickets bloque des places d'une catégorie pour un utilisateur qui démarre le paiement.
+// Le hold de l'utilisateur sur la catégorie est mis à jour, sinon son hold d'accès au concert
+// est réutilisé s'il existe. Ses holds sur les autres catégories sont conservés. La catégorie est
+// verrouillée pendant la vérification pour que deux holds concurrents ne dépassent pas le stock.
+// En placement numéroté, seatIDs désigne les places choisies (voir holdSeats).
+func holdTickets(db *gorm.DB, userID, concertCategoryID uuid.UUID, quantity int, seatIDs []uuid.UUID) (*models.TicketHold, error) {
+	var hold *models.TicketHold
+	err := db.Transaction(func(tx *gorm.DB) error {
+		concertCategory, err := lockConcertCategory(tx, concertCategoryID)
+		if err != nil {
+			return err
+		}
+		if err := checkConcertOpen(tx, concertCategory, uuid.Nil); err != nil {
+			return err
+		}
+
+		held, err := heldTickets(tx, concertCategory.ID, userID)
+		if err != nil {
+			return err
+		}
+		if concertCategory.SoldTickets+held+quantity > concertCategory.AvailableTickets {
+			return errNoTicketsAvailable
+		}
+
+		hold, err = findCategoryHold(tx, userID, concertCategory.ConcertId, concertCategory.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if hold == nil {
+			hold = &models.TicketHold{
+				ID:        uuid.New(),
+				UserId:    userID,
+				ConcertId: concertCategory.ConcertId,
+				Status:    models.TicketHoldActive,
+				CreatedAt: time.Now(),
+			}
+		}
+
+		hold.ConcertCategoryId = &concertCategory.ID
+		hold.Quantity = quantity
+		hold.ExpiresAt = time.Now().Add(getHoldDuration())
+		hold.UpdatedAt = time.Now()
+
+		if err := tx.Save(hold).Error; err != nil {
+			return err
+		}
+		return holdSeats(tx, hold, seatIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// consumeTicketHold décompte du hold actif d'un utilisateur sur une catégorie les places achetées.
+// Le hold est converti une fois toutes ses places achetées ; sinon il reste actif pour les places
+// restantes, dont les places numérotées qui n'ont pas été attribuées (sellSeat libère les autres).
+func consumeTicketHold(tx *gorm.DB, userID, concertCategoryID uuid.UUID, quantity int) error {
+	var hold models.TicketHold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND concert_category_id = ? AND status = ?", userID, concertCategoryID, models.TicketHoldActive).
+		Order("created_at DESC").First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if hold.Quantity > quantity {
+		return tx.Model(&hold).Updates(map[string]interface{}{"quantity": hold.Quantity - quantity, "updated_at": time.Now()}).Error
+	}
+	if err := releaseHeldSeats(tx, []uuid.UUID{hold.ID}); err != nil {
+		return err
+	}
+	return tx.Model(&hold).Updates(map[string]interface{}{"status": models.TicketHoldConverted, "updated_at": time.Now()}).Error
+}
+
+// releaseTicketHold libère le hold actif d'un utilisateur sur une catégorie et les places choisies
+func releaseTicketHold(db *gorm.DB, userID, concertCategoryID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var holdIDs []uuid.UUID
+		if err := tx.Model(&models.TicketHold{}).
+			Where("user_id = ? AND concert_category_id = ? AND status = ?", userID, concertCategoryID, models.TicketHoldActive).
+			Pluck("id", &holdIDs).Error; err != nil {
+			return err
+		}
+		if len(holdIDs) == 0 {
+			return nil
+		}
+		if err := releaseHeldSeats(tx, holdIDs); err != nil {
+			return err
+		}
+		return tx.Model(&models.TicketHold{}).Where("id IN ?", holdIDs).
+			Updates(map[string]interface{}{"status": models.TicketHoldReleased, "updated_at": time.Now()}).Error
+	})
+}
+
+// expireTicketHolds passe les holds arrivés à échéance en expirés et retire l'accès à la salle
+// des utilisateurs concernés qui n'ont plus aucun hold actif sur le concert
+func expireTicketHolds() {
+	db := database.GetDB()
+
+	var holds []models.TicketHold
+	if err := db.Where("status = ? AND expires_at <= ?", models.TicketHoldActive, time.Now()).Find(&holds).Error; err != nil {
+		fmt.Println("Erreur lors de la récupération des holds expirés :", err)
+		return
+	}
+	if len(holds) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(holds))
+	for _, hold := range holds {
+		ids = append(ids, hold.ID)
+	}
+
+	if err := db.Model(&models.TicketHold{}).
+		Where("id IN ? AND status = ?", ids, models.TicketHoldActive).
+		Updates(map[string]interface{}{"status": models.TicketHoldExpired, "updated_at": time.Now()}).Error; err != nil {
+		fmt.Println("Erreur lors de l'expiration des holds :", err)
+		return
+	}
+
+	for _, hold := range holds {
+		if _, err := findActiveHold(db, hold.UserId, hold.ConcertId); !errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		revokeQueueAccess(hold.ConcertId.String(), hold.UserId.String())
+	}
+	fmt.Printf("%d hold(s) expiré(s)\n", len(holds))
+}
+
+// StartTicketHoldSweeper lance en arrière-plan la libération périodique des holds expirés
+func StartTicketHoldSweeper() {
+	ticker := time.NewTicker(holdSweepInterval)
+	go func() {
+		for range ticker.C {
+			expireTicketHolds()
+		}
+	}()
+}
+
+// @Summary		Bloque des places pendant le paiement
+// @Description	Bloque des places d'une catégorie de concert pour l'utilisateur pendant une durée limitée. En placement numéroté, seatIds désigne les places choisies (ID renvoyés par GET /concerts/{id}/seats) et remplace quantity.
+// @ID				create-ticket-hold
+// @Tags			Reservation
+// @Accept			json
+// @Produce		json
+// @Param			body	body		object	true	"concertCategoryId, quantity et seatIds"
+// @Success		201		{object}	models.TicketHold
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/holds [post]
+// @Security		Bearer
+func CreateTicketHold(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var reqBody struct {
+		ConcertCategoryId uuid.UUID   `json:"concertCategoryId"`
+		Quantity          int         `json:"quantity"`
+		SeatIds           []uuid.UUID `json:"seatIds"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if len(reqBody.SeatIds) > 0 {
+		reqBody.Quantity = len(reqBody.SeatIds)
+	}
+	if reqBody.Quantity == 0 {
+		reqBody.Quantity = 1
+	}
+	if reqBody.Quantity < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid quantity"})
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "User not found"})
+	}
+
+	attempt, err := reservationAttempt(db, user.ID, reqBody.ConcertCategoryId, reqBody.Quantity)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert category not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to hold tickets"})
+	}
+	if review, err := screenAttempt(db, attempt); err != nil {
+		return fraudResponse(c, review, err)
+	}
+
+	hold, err := holdTickets(db, user.ID, reqBody.ConcertCategoryId, reqBody.Quantity, reqBody.SeatIds)
+	if err != nil {
+		if errors.Is(err, errNoTicketsAvailable) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "No tickets available for this category"})
+		}
+		if errors.Is(err, errSeatUnavailable) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, errInvalidSeats) || errors.Is(err, errSeatSelectionRequired) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, errConcertCancelled) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Concert has been cancelled"})
+		}
+		if errors.Is(err, errSalesNotOpen) || errors.Is(err, errSalesClosed) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert category not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to hold tickets"})
+	}
+
+	c.Logger().Infof("event=TicketHoldCreated hold_id=%s user_id=%s timestamp=%s", hold.ID, user.ID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, hold)
+}
+
+// @Summary		Libère un hold
+// @Description	Libère les places bloquées par un hold de l'utilisateur
+// @ID				release-ticket-hold
+// @Tags			Reservation
+// @Produce		json
+// @Param			id	path	string	true	"ID du hold"	format(uuid)
+// @Success		204
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/holds/{id} [delete]
+// @Security		Bearer
+func ReleaseTicketHold(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var hold models.TicketHold
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&hold).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Hold not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if hold.Status == models.TicketHoldActive {
+		hold.Status = models.TicketHoldReleased
+		hold.UpdatedAt = time.Now()
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := releaseHeldSeats(tx, []uuid.UUID{hold.ID}); err != nil {
+				return err
+			}
+			return tx.Save(&hold).Error
+		}); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to release hold"})
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
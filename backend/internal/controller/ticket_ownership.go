@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func getResaleMinHoldDuration() time.Duration {
+	return time.Duration(getConfigInt("RESALE_MIN_HOLD_HOURS", 24)) * time.Hour
+}
+
+// recordOwnership ajoute une entrée à l'historique des détenteurs d'un ticket
+func recordOwnership(tx *gorm.DB, ownership models.TicketOwnership) error {
+	ownership.ID = uuid.New()
+	ownership.CreatedAt = time.Now()
+	if ownership.AcquiredAt.IsZero() {
+		ownership.AcquiredAt = ownership.CreatedAt
+	}
+	if ownership.Price.Currency == "" {
+		ownership.Price.Currency = models.DefaultCurrency
+	}
+	return tx.Create(&ownership).Error
+}
+
+// adminIDFromContext renvoie l'identifiant de l'administrateur authentifié, s'il est lisible
+func adminIDFromContext(c echo.Context) *uuid.UUID {
+	tokenString := c.Request().Header.Get("Authorization")
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	id, ok := claims["id"].(string)
+	if !ok {
+		return nil
+	}
+	adminID, err := uuid.Parse(id)
+	if err != nil {
+		return nil
+	}
+	return &adminID
+}
+
+// @Summary		Historique des détenteurs d'un ticket
+// @Description	Renvoie l'historique des détenteurs successifs d'un ticket, du plus ancien au plus récent. Réservé aux administrateurs et au détenteur actuel.
+// @ID				get-ticket-ownership
+// @Tags			Tickets
+// @Produce		json
+// @Param			id	path		string	true	"ID du ticket"	format(uuid)
+// @Success		200	{array}		models.TicketOwnership
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tickets/{id}/ownership [get]
+// @Security		Bearer
+func GetTicketOwnership(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	role, _ := claims["role"].(string)
+
+	var ticket models.Ticket
+	if err := db.Where("id = ?", c.Param("id")).First(&ticket).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Ticket not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// Un ancien détenteur n'a pas accès à l'historique : le ticket est présenté comme introuvable
+	if role != "admin" && ticket.UserId.String() != userID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Ticket not found"})
+	}
+
+	var history []models.TicketOwnership
+	if err := db.Preload("User").Where("ticket_id = ?", ticket.ID).Order("acquired_at ASC, created_at ASC").Find(&history).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve ownership history"})
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
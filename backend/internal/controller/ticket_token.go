@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Préfixe versionné des tokens de QR code. wm2 signe en Ed25519 pour que les appareils de scan
+// hors ligne puissent vérifier les tokens avec la clé publique de /checkin/bundle-key.
+const ticketTokenPrefix = "wm2"
+
+var errInvalidTicketToken = errors.New("invalid ticket token")
+var errExpiredTicketToken = errors.New("expired ticket token")
+
+// ticketTokenPayload est le contenu signé d'un QR code. Le détenteur fait partie de la
+// signature : un ticket revendu ou transféré invalide les QR codes de l'ancien détenteur.
+type ticketTokenPayload struct {
+	TicketId  uuid.UUID `json:"t"`
+	UserId    uuid.UUID `json:"u"`
+	ConcertId uuid.UUID `json:"c"`
+	Window    int64     `json:"w"`
+}
+
+func getTicketTokenRotation() time.Duration {
+	return time.Duration(getConfigInt("TICKET_QR_ROTATION_SECONDS", 30)) * time.Second
+}
+
+// ticketTokenWindow renvoie l'index de la fenêtre de rotation contenant l'instant donné
+func ticketTokenWindow(now time.Time, rotation time.Duration) int64 {
+	return now.Unix() / int64(rotation.Seconds())
+}
+
+// signTicketPayload signe "wm2.<payload>" avec la clé Ed25519 des scans
+func signTicketPayload(encoded string) string {
+	signature := ed25519.Sign(checkInSigningKey(), []byte(ticketTokenPrefix+"."+encoded))
+	return base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// generateTicketToken signe le token du ticket pour la fenêtre de rotation courante
+// et renvoie sa date d'expiration
+func generateTicketToken(ticket *models.Ticket, concertID uuid.UUID, now time.Time) (string, time.Time, error) {
+	rotation := getTicketTokenRotation()
+	window := ticketTokenWindow(now, rotation)
+
+	payload, err := json.Marshal(ticketTokenPayload{
+		TicketId:  ticket.ID,
+		UserId:    ticket.UserId,
+		ConcertId: concertID,
+		Window:    window,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := ticketTokenPrefix + "." + encoded + "." + signTicketPayload(encoded)
+	expiresAt := time.Unix((window+1)*int64(rotation.Seconds()), 0)
+	return token, expiresAt, nil
+}
+
+// verifyTicketToken vérifie la signature d'un token et qu'il appartient à la fenêtre de rotation
+// courante ou à la précédente, pour tolérer un QR code affiché juste avant la rotation
+func verifyTicketToken(token string, now time.Time) (*ticketTokenPayload, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != ticketTokenPrefix {
+		return nil, errInvalidTicketToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidTicketToken
+	}
+	publicKey := checkInSigningKey().Public().(ed25519.PublicKey)
+	if !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errInvalidTicketToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errInvalidTicketToken
+	}
+	var payload ticketTokenPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, errInvalidTicketToken
+	}
+
+	current := ticketTokenWindow(now, getTicketTokenRotation())
+	if payload.Window > current || payload.Window < current-1 {
+		return nil, errExpiredTicketToken
+	}
+	return &payload, nil
+}
+
+// @Summary		QR code d'un ticket
+// @Description	Renvoie le token signé à afficher en QR code. Le token change à chaque rotation et doit être rafraîchi avant expiresAt.
+// @ID				get-ticket-qr-code
+// @Tags			Tickets
+// @Produce		json
+// @Param			id	path		string	true	"ID du ticket"	format(uuid)
+// @Success		200	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tickets/{id}/qrcode [get]
+// @Security		Bearer
+func GetTicketQRCode(c echo.Context) error {
+	db := database.GetDB()
+
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+
+	var ticket models.Ticket
+	if err := db.Preload("ConcertCategory").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&ticket).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Ticket not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if ticket.Status != models.TicketValid {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Ticket is no longer valid"})
+	}
+
+	token, expiresAt, err := generateTicketToken(&ticket, ticket.ConcertCategory.ConcertId, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate ticket token"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
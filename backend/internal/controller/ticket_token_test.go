@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"weezemaster/internal/config"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyTicketToken(t *testing.T) {
+	ticket := &models.Ticket{ID: uuid.New(), UserId: uuid.New()}
+	concertID := uuid.New()
+	now := time.Unix(1700000000, 0)
+	rotation := getTicketTokenRotation()
+
+	token, expiresAt, err := generateTicketToken(ticket, concertID, now)
+	if err != nil {
+		t.Fatalf("generateTicketToken: %v", err)
+	}
+	if !expiresAt.After(now) {
+		t.Fatalf("expiresAt = %v, want after %v", expiresAt, now)
+	}
+	parts := strings.Split(token, ".")
+
+	// Token de l'ancien format, signé en HMAC avec la clé des tickets
+	mac := hmac.New(sha256.New, config.TicketSigningKey)
+	mac.Write([]byte("wm1." + parts[1]))
+	legacy := "wm1." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	tampered := models.Ticket{ID: uuid.New(), UserId: ticket.UserId}
+	other, _, err := generateTicketToken(&tampered, concertID, now)
+	if err != nil {
+		t.Fatalf("generateTicketToken: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{"current window", token, now, nil},
+		{"previous window", token, now.Add(rotation), nil},
+		{"expired window", token, now.Add(2 * rotation), errExpiredTicketToken},
+		{"future window", token, now.Add(-2 * rotation), errExpiredTicketToken},
+		{"payload from another token", parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2], now, errInvalidTicketToken},
+		{"truncated signature", token[:len(token)-4], now, errInvalidTicketToken},
+		{"legacy HMAC token", legacy, now, errInvalidTicketToken},
+		{"missing part", parts[0] + "." + parts[1], now, errInvalidTicketToken},
+		{"empty token", "", now, errInvalidTicketToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := verifyTicketToken(tt.token, tt.now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("verifyTicketToken() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyTicketToken() = %v, want nil", err)
+			}
+			if payload.TicketId != ticket.ID || payload.UserId != ticket.UserId || payload.ConcertId != concertID {
+				t.Errorf("payload = %+v, want ticket %s, user %s, concert %s", payload, ticket.ID, ticket.UserId, concertID)
+			}
+		})
+	}
+}
+
+// TestTicketTokenOfflineVerification vérifie un QR code comme un appareil hors ligne, avec la
+// seule clé publique exposée par /checkin/bundle-key
+func TestTicketTokenOfflineVerification(t *testing.T) {
+	token, _, err := generateTicketToken(&models.Ticket{ID: uuid.New(), UserId: uuid.New()}, uuid.New(), time.Now())
+	if err != nil {
+		t.Fatalf("generateTicketToken: %v", err)
+	}
+	publicKey := checkInSigningKey().Public().(ed25519.PublicKey)
+
+	parts := strings.Split(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	if !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		t.Fatal("token signature does not verify with the check-in public key")
+	}
+	if ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]+"x"), signature) {
+		t.Fatal("signature verifies a tampered payload")
+	}
+}
@@ -0,0 +1,501 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errTransferRecipientNotFound = errors.New("no user found with this email")
+var errTransferToSelf = errors.New("you cannot transfer a ticket to yourself")
+var errTransferAlreadyPending = errors.New("a transfer is already pending for this ticket")
+var errTransferNotPending = errors.New("transfer is no longer pending")
+var errTransferClosed = errors.New("this ticket can no longer be transferred")
+
+// Intervalle entre deux passages de l'expiration des transferts ignorés
+const transferExpiryInterval = 5 * time.Minute
+
+// Réponses possibles à un transfert
+const (
+	transferAccept  = "accept"
+	transferDecline = "decline"
+	transferCancel  = "cancel"
+)
+
+func getTransferExpiry() time.Duration {
+	return time.Duration(getConfigInt("TICKET_TRANSFER_EXPIRY_HOURS", 72)) * time.Hour
+}
+
+// transferErrorStatus traduit une erreur de transfert en code HTTP
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, errTransferRecipientNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errTransferToSelf):
+		return http.StatusBadRequest
+	case errors.Is(err, errTransferAlreadyPending), errors.Is(err, errTransferNotPending), errors.Is(err, errTransferClosed),
+		errors.Is(err, errTicketNotValid), errors.Is(err, errRefundAlreadyRequested), errors.Is(err, errConcertCancelled),
+		errors.Is(err, errTicketCapExceeded):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// cancelTicketTransfers annule les transferts encore en attente de la requête donnée
+func cancelTicketTransfers(query *gorm.DB) error {
+	return query.Model(&models.TicketTransfer{}).
+		Where("status = ?", models.TicketTransferPending).
+		Updates(map[string]interface{}{"status": models.TicketTransferCancelled, "updated_at": time.Now()}).Error
+}
+
+// checkTicketTransferable vérifie qu'un ticket peut encore changer de détenteur : valide, pas
+// encore scanné, sans remboursement en cours et pour un concert ni annulé ni passé
+func checkTicketTransferable(tx *gorm.DB, ticket *models.Ticket) (*models.ConcertCategory, error) {
+	if ticket.Status != models.TicketValid {
+		return nil, errTicketNotValid
+	}
+
+	var concertCategory models.ConcertCategory
+	if err := tx.Preload("Concert").Where("id = ?", ticket.ConcertCategoryId).First(&concertCategory).Error; err != nil {
+		return nil, err
+	}
+	if concertCategory.Concert.Status == models.ConcertCancelled {
+		return nil, errConcertCancelled
+	}
+	if !concertCategory.Concert.Date.After(time.Now()) {
+		return nil, errTransferClosed
+	}
+
+	var checkIns int64
+	if err := tx.Model(&models.CheckIn{}).Where("ticket_id = ?", ticket.ID).Count(&checkIns).Error; err != nil {
+		return nil, err
+	}
+	if checkIns > 0 {
+		return nil, errTicketNotValid
+	}
+
+	var inProgress int64
+	if err := tx.Model(&models.RefundRequest{}).
+		Where("ticket_id = ? AND status IN ?", ticket.ID, []string{models.RefundRequestPending, models.RefundRequestApproved, models.RefundRequestFailed}).
+		Count(&inProgress).Error; err != nil {
+		return nil, err
+	}
+	if inProgress > 0 {
+		return nil, errRefundAlreadyRequested
+	}
+	return &concertCategory, nil
+}
+
+// createTicketTransfer propose le ticket d'un utilisateur à un autre utilisateur désigné par son
+// email. Les annonces ouvertes du ticket sont retirées dans la même transaction pour qu'il ne
+// puisse pas être revendu pendant que le destinataire décide.
+func createTicketTransfer(db *gorm.DB, ticketID, senderID uuid.UUID, recipientEmail string) (*models.TicketTransfer, error) {
+	var transfer *models.TicketTransfer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", ticketID, senderID).First(&ticket).Error; err != nil {
+			return err
+		}
+		if _, err := checkTicketTransferable(tx, &ticket); err != nil {
+			return err
+		}
+
+		var recipient models.User
+		if err := tx.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(recipientEmail))).First(&recipient).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errTransferRecipientNotFound
+			}
+			return err
+		}
+		if recipient.ID == senderID {
+			return errTransferToSelf
+		}
+
+		var pending int64
+		if err := tx.Model(&models.TicketTransfer{}).
+			Where("ticket_id = ? AND status = ? AND expires_at > ?", ticket.ID, models.TicketTransferPending, time.Now()).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errTransferAlreadyPending
+		}
+		// Un transfert échu que le sweeper n'a pas encore traité ne bloque pas le nouveau
+		if err := tx.Model(&models.TicketTransfer{}).
+			Where("ticket_id = ? AND status = ?", ticket.ID, models.TicketTransferPending).
+			Updates(map[string]interface{}{"status": models.TicketTransferExpired, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		if err := withdrawTicketListings(tx, ticket.ID); err != nil {
+			return err
+		}
+
+		transfer = &models.TicketTransfer{
+			ID:          uuid.New(),
+			TicketId:    ticket.ID,
+			SenderId:    senderID,
+			RecipientId: recipient.ID,
+			Status:      models.TicketTransferPending,
+			ExpiresAt:   time.Now().Add(getTransferExpiry()),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		return tx.Create(transfer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// respondToTicketTransfer applique la réponse à un transfert en attente : le destinataire
+// l'accepte ou le refuse, l'expéditeur peut l'annuler. L'acceptation donne le ticket au
+// destinataire et retire les annonces qui auraient pu être créées entre-temps.
+func respondToTicketTransfer(db *gorm.DB, transferID, userID uuid.UUID, action string) (*models.TicketTransfer, error) {
+	var transfer models.TicketTransfer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", transferID).First(&transfer).Error; err != nil {
+			return err
+		}
+		if action == transferCancel && transfer.SenderId != userID {
+			return gorm.ErrRecordNotFound
+		}
+		if action != transferCancel && transfer.RecipientId != userID {
+			return gorm.ErrRecordNotFound
+		}
+		if transfer.Status != models.TicketTransferPending || !transfer.ExpiresAt.After(time.Now()) {
+			return errTransferNotPending
+		}
+
+		now := time.Now()
+		transfer.RespondedAt = &now
+		transfer.UpdatedAt = now
+
+		switch action {
+		case transferAccept:
+			var ticket models.Ticket
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", transfer.TicketId).First(&ticket).Error; err != nil {
+				return err
+			}
+			// Le ticket a pu être revendu ou remboursé depuis la proposition
+			if ticket.UserId != transfer.SenderId {
+				return errTicketNotValid
+			}
+			concertCategory, err := checkTicketTransferable(tx, &ticket)
+			if err != nil {
+				return err
+			}
+
+			var owned int64
+			if err := tx.Model(&models.Ticket{}).
+				Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+				Where("tickets.user_id = ? AND concert_categories.concert_id = ? AND tickets.status = ?", userID, concertCategory.ConcertId, models.TicketValid).
+				Count(&owned).Error; err != nil {
+				return err
+			}
+			if int(owned)+1 > getMaxTicketsPerConcert() {
+				return errTicketCapExceeded
+			}
+
+			if err := withdrawTicketListings(tx, ticket.ID); err != nil {
+				return err
+			}
+			if err := recordOwnership(tx, models.TicketOwnership{
+				TicketId:       ticket.ID,
+				UserId:         transfer.RecipientId,
+				PreviousUserId: &transfer.SenderId,
+				Source:         models.OwnershipGift,
+				Price:          models.NewMoney(0, ticket.MaxPrice.Currency),
+				TransferId:     &transfer.ID,
+			}); err != nil {
+				return err
+			}
+			ticket.UserId = transfer.RecipientId
+			ticket.UpdatedAt = now
+			if err := tx.Save(&ticket).Error; err != nil {
+				return err
+			}
+			transfer.Status = models.TicketTransferAccepted
+		case transferDecline:
+			transfer.Status = models.TicketTransferDeclined
+		case transferCancel:
+			transfer.Status = models.TicketTransferCancelled
+		}
+		return tx.Save(&transfer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// expireTicketTransfers passe en expirés les transferts restés sans réponse
+func expireTicketTransfers() {
+	db := database.GetDB()
+
+	result := db.Model(&models.TicketTransfer{}).
+		Where("status = ? AND expires_at <= ?", models.TicketTransferPending, time.Now()).
+		Updates(map[string]interface{}{"status": models.TicketTransferExpired, "updated_at": time.Now()})
+	if result.Error != nil {
+		fmt.Println("Erreur lors de l'expiration des transferts :", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		fmt.Printf("%d transfert(s) expiré(s)\n", result.RowsAffected)
+	}
+}
+
+// StartTicketTransferExpirer lance en arrière-plan l'expiration périodique des transferts ignorés
+func StartTicketTransferExpirer() {
+	ticker := time.NewTicker(transferExpiryInterval)
+	go func() {
+		for range ticker.C {
+			expireTicketTransfers()
+		}
+	}()
+}
+
+// notifyTransferReceived prévient le destinataire d'un transfert par notification push et par email
+func notifyTransferReceived(db *gorm.DB, transfer *models.TicketTransfer) {
+	var sender, recipient models.User
+	if err := db.Where("id = ?", transfer.SenderId).First(&sender).Error; err != nil {
+		fmt.Printf("Failed to find sender %s for transfer %s: %v\n", transfer.SenderId, transfer.ID, err)
+		return
+	}
+	if err := db.Where("id = ?", transfer.RecipientId).First(&recipient).Error; err != nil {
+		fmt.Printf("Failed to find recipient %s for transfer %s: %v\n", transfer.RecipientId, transfer.ID, err)
+		return
+	}
+	var ticket models.Ticket
+	if err := db.Preload("ConcertCategory.Concert").Where("id = ?", transfer.TicketId).First(&ticket).Error; err != nil {
+		fmt.Printf("Failed to find ticket %s for transfer %s: %v\n", transfer.TicketId, transfer.ID, err)
+		return
+	}
+	concert := ticket.ConcertCategory.Concert
+
+	data := map[string]string{
+		"transfer_id": transfer.ID.String(),
+		"ticket_id":   transfer.TicketId.String(),
+	}
+	notification := map[string]string{
+		"title": "Un ticket vous attend",
+		"body":  fmt.Sprintf("%s vous offre un ticket pour \"%s\".", sender.Firstname, concert.Name),
+	}
+	if err := SendFCMNotification(userTopic(recipient.ID), data, notification); err != nil {
+		fmt.Printf("Failed to send transfer notification to user %s: %v\n", recipient.ID, err)
+	}
+
+	html := `<!DOCTYPE html>
+<html lang="fr">
+  <body>
+    <h1>Weezemaster</h1>
+    <p>Bonjour ` + recipient.Firstname + `,</p>
+    <p>` + sender.Firstname + ` ` + sender.Lastname + ` vous offre un ticket pour le concert <strong>` + concert.Name + `</strong> prévu le ` + concert.Date.Format("02/01/2006 15:04") + `.</p>
+    <p>Ouvrez l'application pour l'accepter ou le refuser avant le ` + transfer.ExpiresAt.Format("02/01/2006 15:04") + `.</p>
+    <p>À bientôt sur <strong>Weezemaster</strong>.</p>
+  </body>
+</html>`
+	if err := sendEmail(recipient.Email, "Weezemaster - Un ticket vous attend", html); err != nil {
+		fmt.Printf("Failed to send transfer email to user %s: %v\n", recipient.ID, err)
+	}
+}
+
+// notifyTransferAnswered prévient l'expéditeur de la réponse du destinataire
+func notifyTransferAnswered(transfer *models.TicketTransfer) {
+	body := "Votre ticket a été accepté."
+	if transfer.Status == models.TicketTransferDeclined {
+		body = "Votre ticket a été refusé, il vous appartient toujours."
+	}
+	data := map[string]string{
+		"transfer_id": transfer.ID.String(),
+		"ticket_id":   transfer.TicketId.String(),
+		"status":      transfer.Status,
+	}
+	notification := map[string]string{
+		"title": "Transfert de ticket",
+		"body":  body,
+	}
+	if err := SendFCMNotification(userTopic(transfer.SenderId), data, notification); err != nil {
+		fmt.Printf("Failed to send transfer answer to user %s: %v\n", transfer.SenderId, err)
+	}
+}
+
+// @Summary		Offre un ticket
+// @Description	Propose gratuitement un ticket à un autre utilisateur désigné par son email. Les annonces ouvertes du ticket sont retirées. Le destinataire a TICKET_TRANSFER_EXPIRY_HOURS heures pour répondre.
+// @ID				create-ticket-transfer
+// @Tags			Tickets
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string	true	"ID du ticket"	format(uuid)
+// @Param			body	body		object	true	"email du destinataire"
+// @Success		201		{object}	models.TicketTransfer
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/tickets/{id}/transfer [post]
+// @Security		Bearer
+func CreateTicketTransfer(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ticket ID"})
+	}
+
+	var reqBody struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&reqBody); err != nil || strings.TrimSpace(reqBody.Email) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	transfer, err := createTicketTransfer(db, ticketID, userID, reqBody.Email)
+	if err != nil {
+		return c.JSON(transferErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	go notifyTransferReceived(db, transfer)
+
+	c.Logger().Infof("event=TicketTransferCreated transfer_id=%s ticket_id=%s sender_id=%s recipient_id=%s timestamp=%s", transfer.ID, ticketID, userID, transfer.RecipientId, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, transfer)
+}
+
+// @Summary		Transferts de l'utilisateur
+// @Description	Renvoie les transferts reçus (incoming) et envoyés (outgoing) par l'utilisateur, les plus récents en premier
+// @ID				get-ticket-transfers
+// @Tags			Tickets
+// @Produce		json
+// @Param			status	query		string	false	"Filtre sur le statut (pending, accepted, declined, cancelled, expired)"
+// @Success		200		{object}	map[string][]models.TicketTransfer
+// @Failure		401		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/user/transfers [get]
+// @Security		Bearer
+func GetTicketTransfers(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	find := func(column string) ([]models.TicketTransfer, error) {
+		query := db.Preload("Ticket.ConcertCategory.Concert").Preload("Sender").Preload("Recipient").
+			Where(column+" = ?", userID)
+		if status := c.QueryParam("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		var transfers []models.TicketTransfer
+		err := query.Order("created_at DESC").Find(&transfers).Error
+		return transfers, err
+	}
+
+	incoming, err := find("recipient_id")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve transfers"})
+	}
+	outgoing, err := find("sender_id")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve transfers"})
+	}
+
+	return c.JSON(http.StatusOK, map[string][]models.TicketTransfer{
+		"incoming": incoming,
+		"outgoing": outgoing,
+	})
+}
+
+// respondToTicketTransferHandler regroupe le traitement des réponses à un transfert
+func respondToTicketTransferHandler(c echo.Context, action string) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	transferID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid transfer ID"})
+	}
+
+	transfer, err := respondToTicketTransfer(db, transferID, userID, action)
+	if err != nil {
+		return c.JSON(transferErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	if action != transferCancel {
+		go notifyTransferAnswered(transfer)
+	}
+
+	c.Logger().Infof("event=TicketTransferAnswered transfer_id=%s action=%s user_id=%s timestamp=%s", transferID, action, userID, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusOK, transfer)
+}
+
+// @Summary		Accepte un transfert
+// @Description	Le destinataire accepte le ticket qui lui est offert et en devient le détenteur
+// @ID				accept-ticket-transfer
+// @Tags			Tickets
+// @Produce		json
+// @Param			id	path		string	true	"ID du transfert"	format(uuid)
+// @Success		200	{object}	models.TicketTransfer
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/transfers/{id}/accept [post]
+// @Security		Bearer
+func AcceptTicketTransfer(c echo.Context) error {
+	return respondToTicketTransferHandler(c, transferAccept)
+}
+
+// @Summary		Refuse un transfert
+// @Description	Le destinataire refuse le ticket qui lui est offert, qui reste à l'expéditeur
+// @ID				decline-ticket-transfer
+// @Tags			Tickets
+// @Produce		json
+// @Param			id	path		string	true	"ID du transfert"	format(uuid)
+// @Success		200	{object}	models.TicketTransfer
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/transfers/{id}/decline [post]
+// @Security		Bearer
+func DeclineTicketTransfer(c echo.Context) error {
+	return respondToTicketTransferHandler(c, transferDecline)
+}
+
+// @Summary		Annule un transfert
+// @Description	L'expéditeur annule un transfert auquel le destinataire n'a pas encore répondu
+// @ID				cancel-ticket-transfer
+// @Tags			Tickets
+// @Produce		json
+// @Param			id	path		string	true	"ID du transfert"	format(uuid)
+// @Success		200	{object}	models.TicketTransfer
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/transfers/{id}/cancel [post]
+// @Security		Bearer
+func CancelTicketTransfer(c echo.Context) error {
+	return respondToTicketTransferHandler(c, transferCancel)
+}
@@ -1,14 +1,17 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"time"
 	"weezemaster/internal/database"
+	"weezemaster/internal/fraud"
 	"weezemaster/internal/models"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // @Summary		Get all ticket listings
@@ -71,6 +74,14 @@ func GetTicketListingByConcertId(c echo.Context) error {
 		Find(&ticketListings).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 	}
+
+	listings := make([]*models.TicketListing, len(ticketListings))
+	for i := range ticketListings {
+		listings[i] = &ticketListings[i]
+	}
+	if err := attachSellerReputations(db, listings); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
 	return c.JSON(http.StatusOK, ticketListings)
 }
 
@@ -80,7 +91,7 @@ func GetTicketListingByConcertId(c echo.Context) error {
 // @Accept			json
 // @Produce		json
 // @Param			ticketId	body		string	true	"Ticket ID"
-// @Param			price		body		float64	true	"Price"
+// @Param			price		body		models.Money	true	"Price"
 // @Success		201			{object}	models.TicketListing
 // @Failure		400			{object}	map[string]string
 // @Failure		401			{object}	map[string]string
@@ -111,10 +122,10 @@ func CreateTicketListings(c echo.Context) error {
 	}
 
 	var reqBody struct {
-		TicketId uuid.UUID `json:"ticketId"`
-		Price    float64   `json:"price"`
+		TicketId uuid.UUID    `json:"ticketId"`
+		Price    models.Money `json:"price"`
 	}
-	if err := c.Bind(&reqBody); err != nil {
+	if err := c.Bind(&reqBody); err != nil || !reqBody.Price.IsPositive() {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
@@ -127,113 +138,249 @@ func CreateTicketListings(c echo.Context) error {
 	if err := db.Where("id = ? AND user_id = ?", reqBody.TicketId, user.ID).First(&ticket).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Ticket not found or does not belong to the user"})
 	}
+	if ticket.Status != models.TicketValid {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Ticket is no longer valid"})
+	}
+
+	var checkIns int64
+	if err := db.Model(&models.CheckIn{}).Where("ticket_id = ?", ticket.ID).Count(&checkIns).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check ticket entry"})
+	}
+	if checkIns > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Ticket has already been used"})
+	}
+
+	var refundsInProgress int64
+	if err := db.Model(&models.RefundRequest{}).
+		Where("ticket_id = ? AND status IN ?", ticket.ID, []string{models.RefundRequestPending, models.RefundRequestApproved, models.RefundRequestFailed}).
+		Count(&refundsInProgress).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check refund requests"})
+	}
+	if refundsInProgress > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A refund is in progress for this ticket"})
+	}
 
 	var concertCategory models.ConcertCategory
 	if err := db.Where("id = ?", ticket.ConcertCategoryId).First(&concertCategory).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Concert category not found"})
 	}
 
-	if reqBody.Price > concertCategory.Price {
+	cmp, err := reqBody.Price.Cmp(concertCategory.Price)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Price currency does not match the original ticket price"})
+	}
+	if cmp > 0 {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Price exceeds the original ticket price"})
 	}
 
-	var existingListing models.TicketListing
-	if err := db.Where("ticket_id = ? AND status = ?", reqBody.TicketId, "available").First(&existingListing).Error; err == nil {
-		return c.JSON(http.StatusConflict, map[string]string{"error": "Ticket listing already exists with status available"})
+	if review, err := screenAttempt(db, fraud.Attempt{
+		Checkpoint:        models.FraudCheckpointListing,
+		UserID:            user.ID,
+		ConcertID:         concertCategory.ConcertId,
+		ConcertCategoryID: concertCategory.ID,
+		TicketID:          ticket.ID,
+	}); err != nil {
+		return fraudResponse(c, review, err)
+	}
+
+	var pendingTransfers int64
+	if err := db.Model(&models.TicketTransfer{}).
+		Where("ticket_id = ? AND status = ? AND expires_at > ?", ticket.ID, models.TicketTransferPending, time.Now()).
+		Count(&pendingTransfers).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check ticket transfers"})
+	}
+	if pendingTransfers > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Ticket is being transferred"})
+	}
+
+	if err := checkListingWindow(db, ticket.ConcertCategoryId); err != nil {
+		if errors.Is(err, errListingTooLate) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Concert not found"})
 	}
 
 	newListing := models.TicketListing{
 		ID:        uuid.New(),
 		Price:     reqBody.Price,
-		Status:    "available",
+		Status:    models.ListingAvailable,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		TicketId:  reqBody.TicketId,
 	}
 
+	// L'index unique partiel sur les annonces actives rejette une deuxième annonce ouverte,
+	// y compris quand deux créations arrivent en même temps
 	if err := db.Create(&newListing).Error; err != nil {
+		if isListingActiveViolation(err) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": errListingAlreadyActive.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create ticket listing"})
 	}
 
+	go alertWaitlistForListing(newListing.ID)
+
 	return c.JSON(http.StatusOK, newListing)
 }
 
+// findSellerListing charge une annonce que l'utilisateur authentifié peut gérer : la sienne, ou
+// n'importe laquelle pour un administrateur
+func findSellerListing(c echo.Context, db *gorm.DB) (*models.TicketListing, error) {
+	authHeader := c.Request().Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authorization header is missing"})
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok {
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid token claims"})
+	}
+	role, _ := claims["role"].(string)
+
+	ticketListingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	var ticketListing models.TicketListing
+	if err := db.Preload("Ticket.ConcertCategory").Where("id = ?", ticketListingID).First(&ticketListing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "TicketListing not found"})
+		}
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	if role != "admin" && ticketListing.Ticket.UserId.String() != userID {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "TicketListing not found"})
+	}
+	return &ticketListing, nil
+}
+
 // @Summary		Update ticket listing
-// @Description	Update ticket listing
+// @Description	Update the price of an available listing, or withdraw it. Other statuses are only reached through checkout, sale or expiry.
 // @Tags			Ticket listing
 // @Accept			json
 // @Produce		json
 // @Param			id		path		string	true	"TicketListing ID"
-// @Param			price	body		float64	false	"Price"
-// @Param			status	body		string	false	"Status"
+// @Param			price	body		models.Money	false	"Price"
+// @Param			status	body		string	false	"Status (withdrawn)"
 // @Success		200		{object}	models.TicketListing
 // @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
 // @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
-// @Router			/ticketlistings/{id} [patch]
+// @Router			/ticketlisting/{id} [patch]
 // @Security		Bearer
 func UpdateTicketListing(c echo.Context) error {
 	db := database.GetDB()
-	id := c.Param("id")
-	var ticketListing models.TicketListing
 
-	if res := db.Where("id = ?", id).First(&ticketListing); res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "TicketListing not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	ticketListing, err := findSellerListing(c, db)
+	if ticketListing == nil {
+		return err
 	}
 
-	input := new(models.TicketListing)
-	if err := c.Bind(input); err != nil {
+	var input struct {
+		Price  *models.Money `json:"price"`
+		Status string        `json:"status"`
+	}
+	if err := c.Bind(&input); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid payload"})
 	}
 
-	if input.Price != 0 {
-		ticketListing.Price = input.Price
-	}
-	if input.Status != "" {
-		ticketListing.Status = input.Status
-	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Relit l'annonce sous verrou : elle a pu être réservée ou vendue depuis le chargement
+		var locked models.TicketListing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ticketListing.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		ticketListing.Status = locked.Status
+		ticketListing.Price = locked.Price
+		ticketListing.ReservedById = locked.ReservedById
+		ticketListing.ReservedUntil = locked.ReservedUntil
+
+		if input.Price != nil && !input.Price.IsZero() && *input.Price != ticketListing.Price {
+			if ticketListing.Status != models.ListingAvailable {
+				return errInvalidListingTransition
+			}
+			if !input.Price.IsPositive() {
+				return errInvalidOfferPrice
+			}
+			cmp, err := input.Price.Cmp(ticketListing.Ticket.ConcertCategory.Price)
+			if err != nil {
+				return err
+			}
+			if cmp > 0 {
+				return errOfferAboveCap
+			}
+			ticketListing.Price = *input.Price
+		}
 
-	ticketListing.UpdatedAt = time.Now()
+		if input.Status != "" && input.Status != ticketListing.Status {
+			if !sellerListingStatuses[input.Status] {
+				return errInvalidListingTransition
+			}
+			return transitionListing(tx.Omit("Ticket"), ticketListing, input.Status)
+		}
 
-	if res := db.Save(&ticketListing); res.Error != nil {
+		ticketListing.UpdatedAt = time.Now()
+		return tx.Omit("Ticket").Save(ticketListing).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidListingTransition):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, errInvalidOfferPrice), errors.Is(err, errOfferAboveCap), errors.Is(err, models.ErrCurrencyMismatch):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Price must be positive and not exceed the original ticket price"})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 	}
 
 	return c.JSON(http.StatusOK, ticketListing)
 }
 
-// @Summary		Delete ticket listing
-// @Description	Delete ticket listing
+// @Summary		Withdraw ticket listing
+// @Description	Withdraw an open ticket listing. The listing is kept with the withdrawn status.
 // @Tags			Ticket listing
 // @Accept			json
 // @Produce		json
 // @Param			id	path	string	true	"TicketListing ID"
 // @Success		204
+// @Failure		401	{object}	map[string]string
 // @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
 // @Failure		500	{object}	map[string]string
-// @Router			/ticketlistings/{id} [delete]
+// @Router			/ticketlisting/{id} [delete]
 // @Security		Bearer
 func DeleteTicketListing(c echo.Context) error {
 	db := database.GetDB()
-	id := c.Param("id")
 
-	ticketListingID, err := uuid.Parse(id)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	ticketListing, err := findSellerListing(c, db)
+	if ticketListing == nil {
+		return err
 	}
 
-	var ticketListing models.TicketListing
-	if res := db.Where("id = ?", ticketListingID).First(&ticketListing); res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "TicketListing not found"})
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var locked models.TicketListing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ticketListing.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		*ticketListing = locked
+		return transitionListing(tx, ticketListing, models.ListingWithdrawn)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidListingTransition) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
-	}
-
-	if res := db.Delete(&ticketListing); res.Error != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 	}
 
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidListingTransition = errors.New("this status change is not allowed for the ticket listing")
+var errListingAlreadyActive = errors.New("ticket already has an active listing")
+var errListingReserved = errors.New("ticket listing is reserved by another buyer")
+var errListingTooLate = errors.New("listings close before the concert")
+
+// Intervalle entre deux passages du sweeper des annonces
+const listingSweepInterval = time.Minute
+
+// listingTransitions liste les changements de statut autorisés pour une annonce
+var listingTransitions = map[string][]string{
+	models.ListingAvailable: {models.ListingReserved, models.ListingSold, models.ListingWithdrawn, models.ListingExpired},
+	models.ListingReserved:  {models.ListingAvailable, models.ListingSold, models.ListingWithdrawn, models.ListingExpired},
+	models.ListingSold:      {models.ListingRefunded},
+}
+
+// sellerListingStatuses sont les statuts qu'un vendeur ou un administrateur peut demander
+// directement ; les autres ne sont atteints que par la réservation, la vente ou l'expiration
+var sellerListingStatuses = map[string]bool{
+	models.ListingWithdrawn: true,
+}
+
+func getListingExpiryOffset() time.Duration {
+	return time.Duration(getConfigInt("LISTING_EXPIRY_OFFSET_HOURS", 2)) * time.Hour
+}
+
+func canTransitionListing(from, to string) bool {
+	for _, allowed := range listingTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionListing fait passer une annonce dans un nouveau statut si la transition est autorisée.
+// La réservation est effacée dès que l'annonce quitte le statut reserved.
+func transitionListing(tx *gorm.DB, listing *models.TicketListing, to string) error {
+	if !canTransitionListing(listing.Status, to) {
+		return errInvalidListingTransition
+	}
+	listing.Status = to
+	if to != models.ListingReserved {
+		listing.ReservedById = nil
+		listing.ReservedUntil = nil
+	}
+	listing.UpdatedAt = time.Now()
+	return tx.Save(listing).Error
+}
+
+// isListingActiveViolation indique si une insertion a violé l'index d'une annonce active par ticket
+func isListingActiveViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_ticket_listing_active"
+}
+
+// checkListingWindow vérifie que le concert du ticket est assez loin pour ouvrir une annonce
+func checkListingWindow(db *gorm.DB, concertCategoryID uuid.UUID) error {
+	var concertCategory models.ConcertCategory
+	if err := db.Preload("Concert").Where("id = ?", concertCategoryID).First(&concertCategory).Error; err != nil {
+		return err
+	}
+	if !concertCategory.Concert.Date.After(time.Now().Add(getListingExpiryOffset())) {
+		return errListingTooLate
+	}
+	return nil
+}
+
+// isListingPurchasable indique si un acheteur peut conclure la vente d'une annonce : elle est
+// disponible, ou réservée par lui, ou sa réservation par un autre acheteur a expiré
+func isListingPurchasable(listing *models.TicketListing, buyerID uuid.UUID) bool {
+	switch listing.Status {
+	case models.ListingAvailable:
+		return true
+	case models.ListingReserved:
+		if listing.ReservedById != nil && *listing.ReservedById == buyerID {
+			return true
+		}
+		return listing.ReservedUntil == nil || !listing.ReservedUntil.After(time.Now())
+	}
+	return false
+}
+
+// reserveListing réserve une annonce à un acheteur pendant son paiement, pour la durée d'un hold.
+// L'acheteur qui détient déjà la réservation la prolonge.
+func reserveListing(db *gorm.DB, listingID, buyerID uuid.UUID) (*models.TicketListing, error) {
+	var listing models.TicketListing
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Ticket").Where("id = ?", listingID).First(&listing).Error; err != nil {
+			return err
+		}
+		if listing.Ticket.UserId == buyerID {
+			return errBuyerIsOwner
+		}
+		if !isListingPurchasable(&listing, buyerID) {
+			if listing.Status == models.ListingReserved {
+				return errListingReserved
+			}
+			return errListingNotAvailable
+		}
+
+		until := time.Now().Add(getHoldDuration())
+		// Une fenêtre exclusive plus longue (liste d'attente) n'est pas raccourcie
+		if listing.Status == models.ListingReserved && listing.ReservedById != nil && *listing.ReservedById == buyerID &&
+			listing.ReservedUntil != nil && listing.ReservedUntil.After(until) {
+			until = *listing.ReservedUntil
+		}
+		listing.Status = models.ListingReserved
+		listing.ReservedById = &buyerID
+		listing.ReservedUntil = &until
+		listing.UpdatedAt = time.Now()
+		return tx.Omit("Ticket").Save(&listing).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// withdrawTicketListings retire les annonces encore ouvertes d'un ticket
+func withdrawTicketListings(tx *gorm.DB, ticketID uuid.UUID) error {
+	return withdrawListings(tx.Where("ticket_id = ?", ticketID))
+}
+
+// withdrawListings retire les annonces encore ouvertes de la requête donnée. Un paiement en cours
+// sur une annonce réservée ne pourra plus être honoré et sera remboursé.
+func withdrawListings(query *gorm.DB) error {
+	return query.Model(&models.TicketListing{}).
+		Where("status IN ?", models.ActiveListingStatuses).
+		Updates(map[string]interface{}{
+			"status":         models.ListingWithdrawn,
+			"reserved_by_id": nil,
+			"reserved_until": nil,
+			"updated_at":     time.Now(),
+		}).Error
+}
+
+// sweepTicketListings remet en vente les annonces dont la réservation a expiré et fait expirer
+// les annonces dont le concert commence dans moins de LISTING_EXPIRY_OFFSET_HOURS heures
+func sweepTicketListings() {
+	db := database.GetDB()
+	now := time.Now()
+
+	released := db.Model(&models.TicketListing{}).
+		Where("status = ? AND reserved_until <= ?", models.ListingReserved, now).
+		Updates(map[string]interface{}{
+			"status":         models.ListingAvailable,
+			"reserved_by_id": nil,
+			"reserved_until": nil,
+			"updated_at":     now,
+		})
+	if released.Error != nil {
+		fmt.Println("Erreur lors de la libération des annonces réservées :", released.Error)
+	}
+
+	// Une annonce réservée dont le paiement est en cours expire seulement une fois la réservation échue
+	closing := db.Model(&models.Ticket{}).Select("tickets.id").
+		Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+		Joins("JOIN concerts ON concerts.id = concert_categories.concert_id").
+		Where("concerts.date <= ?", now.Add(getListingExpiryOffset()))
+	expired := db.Model(&models.TicketListing{}).
+		Where("status = ? AND ticket_id IN (?)", models.ListingAvailable, closing).
+		Updates(map[string]interface{}{"status": models.ListingExpired, "updated_at": now})
+	if expired.Error != nil {
+		fmt.Println("Erreur lors de l'expiration des annonces :", expired.Error)
+		return
+	}
+
+	if released.RowsAffected > 0 || expired.RowsAffected > 0 {
+		fmt.Printf("%d annonce(s) remise(s) en vente, %d annonce(s) expirée(s)\n", released.RowsAffected, expired.RowsAffected)
+	}
+}
+
+// StartTicketListingSweeper lance en arrière-plan la libération des réservations échues et
+// l'expiration des annonces avant les concerts
+func StartTicketListingSweeper() {
+	ticker := time.NewTicker(listingSweepInterval)
+	go func() {
+		for range ticker.C {
+			sweepTicketListings()
+		}
+	}()
+}
@@ -92,6 +92,12 @@ func GetUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	reputation, err := reputationOf(db, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	user.Reputation = reputation
+
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -0,0 +1,441 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var errInvalidVenue = errors.New("venue name is required, coordinates must be valid and the time zone must be an IANA name")
+var errVenueNotOwned = errors.New("venue does not belong to the concert's organization")
+
+const (
+	// Rayon de la Terre utilisé pour les distances, en kilomètres
+	earthRadiusKm         = 6371.0
+	nearbyDefaultRadiusKm = 25.0
+	nearbyMaxRadiusKm     = 500.0
+)
+
+// Format des dates de concert saisies, à l'heure locale de la salle
+const concertDateLayout = "2006-01-02 15:04"
+
+// venueLocation renvoie le fuseau horaire d'une salle, ou celui par défaut
+func venueLocation(venue *models.Venue) *time.Location {
+	if venue != nil && venue.TimeZone != "" {
+		if loc, err := time.LoadLocation(venue.TimeZone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(models.DefaultVenueTimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseConcertDate interprète une date de concert à l'heure locale de sa salle
+func parseConcertDate(value string, venue *models.Venue) (time.Time, error) {
+	return time.ParseInLocation(concertDateLayout, value, venueLocation(venue))
+}
+
+// venueLabel est le lieu affiché d'un concert : le nom de la salle, suivi de sa ville si elle
+// n'y figure pas déjà
+func venueLabel(venue *models.Venue) string {
+	if venue.City == "" || strings.Contains(strings.ToLower(venue.Name), strings.ToLower(venue.City)) {
+		return venue.Name
+	}
+	return venue.Name + ", " + venue.City
+}
+
+func validateVenue(venue *models.Venue) error {
+	venue.Name = strings.TrimSpace(venue.Name)
+	if venue.Name == "" || venue.Capacity < 0 {
+		return errInvalidVenue
+	}
+	if (venue.Latitude == nil) != (venue.Longitude == nil) {
+		return errInvalidVenue
+	}
+	if venue.Latitude != nil && (*venue.Latitude < -90 || *venue.Latitude > 90 || *venue.Longitude < -180 || *venue.Longitude > 180) {
+		return errInvalidVenue
+	}
+	if venue.TimeZone == "" {
+		venue.TimeZone = models.DefaultVenueTimeZone
+	}
+	if _, err := time.LoadLocation(venue.TimeZone); err != nil {
+		return errInvalidVenue
+	}
+	return nil
+}
+
+// venueForLocation renvoie la salle d'une organisation portant ce nom, en la créant au besoin.
+// Sert aux concerts créés avec un lieu en texte libre plutôt qu'avec une salle.
+func venueForLocation(db *gorm.DB, organizationID uuid.UUID, location string) (*models.Venue, error) {
+	location = strings.TrimSpace(location)
+	var venue models.Venue
+	err := db.Where("organization_id = ? AND name = ?", organizationID, location).First(&venue).Error
+	if err == nil {
+		return &venue, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	venue = models.Venue{
+		ID:             uuid.New(),
+		OrganizationId: organizationID,
+		Name:           location,
+		Address:        location,
+		TimeZone:       models.DefaultVenueTimeZone,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if err := db.Create(&venue).Error; err != nil {
+		return nil, err
+	}
+	return &venue, nil
+}
+
+// resolveConcertVenue détermine la salle d'un concert à partir du form-data : la salle venueId,
+// qui doit appartenir à l'organisation du concert, sinon une salle déduite du lieu en texte
+// libre, sinon la salle actuelle
+func resolveConcertVenue(c echo.Context, db *gorm.DB, organizationID uuid.UUID, current *uuid.UUID) (*models.Venue, error) {
+	if venueID := c.FormValue("venueId"); venueID != "" {
+		var venue models.Venue
+		if err := db.Where("id = ?", venueID).First(&venue).Error; err != nil {
+			return nil, err
+		}
+		if venue.OrganizationId != organizationID {
+			return nil, errVenueNotOwned
+		}
+		return &venue, nil
+	}
+	if location := c.FormValue("location"); strings.TrimSpace(location) != "" {
+		return venueForLocation(db, organizationID, location)
+	}
+	if current != nil {
+		var venue models.Venue
+		if err := db.Where("id = ?", *current).First(&venue).Error; err != nil {
+			return nil, err
+		}
+		return &venue, nil
+	}
+	return nil, nil
+}
+
+// concertVenueError traduit une erreur de resolveConcertVenue
+func concertVenueError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Venue not found")
+	case errors.Is(err, errVenueNotOwned):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resolve venue: "+err.Error())
+}
+
+// venueUser renvoie l'utilisateur authentifié et son rôle
+func venueUser(c echo.Context, db *gorm.DB) (*models.User, string, error) {
+	tokenString := c.Request().Header.Get("Authorization")
+	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+		tokenString = tokenString[7:]
+	}
+	claims, err := verifyToken(tokenString)
+	if err != nil {
+		return nil, "", err
+	}
+	userID, _ := claims["id"].(string)
+	role, _ := claims["role"].(string)
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, "", err
+	}
+	return &user, role, nil
+}
+
+type VenueRequest struct {
+	OrganizationId *uuid.UUID `json:"organizationId"`
+	Name           *string    `json:"name"`
+	Address        *string    `json:"address"`
+	City           *string    `json:"city"`
+	PostalCode     *string    `json:"postalCode"`
+	Country        *string    `json:"country"`
+	Latitude       *float64   `json:"latitude"`
+	Longitude      *float64   `json:"longitude"`
+	TimeZone       *string    `json:"timeZone"`
+	Capacity       *int       `json:"capacity"`
+}
+
+// apply reporte sur la salle les champs présents dans la requête
+func (r VenueRequest) apply(venue *models.Venue) {
+	for field, value := range map[*string]*string{
+		&venue.Name: r.Name, &venue.Address: r.Address, &venue.City: r.City,
+		&venue.PostalCode: r.PostalCode, &venue.Country: r.Country, &venue.TimeZone: r.TimeZone,
+	} {
+		if value != nil {
+			*field = strings.TrimSpace(*value)
+		}
+	}
+	if r.Latitude != nil || r.Longitude != nil {
+		venue.Latitude = r.Latitude
+		venue.Longitude = r.Longitude
+	}
+	if r.Capacity != nil {
+		venue.Capacity = *r.Capacity
+	}
+}
+
+// @Summary		Récupère les salles
+// @Description	Récupère les salles, éventuellement celles d'une organisation
+// @ID				get-venues
+// @Tags			Venues
+// @Produce		json
+// @Param			organizationId	query		string	false	"ID de l'organisation"	format(uuid)
+// @Success		200				{array}		models.Venue
+// @Failure		500				{object}	map[string]string
+// @Router			/venues [get]
+func GetVenues(c echo.Context) error {
+	db := database.GetDB()
+
+	query := db.Order("name ASC")
+	if organizationID := c.QueryParam("organizationId"); organizationID != "" {
+		query = query.Where("organization_id = ?", organizationID)
+	}
+
+	var venues []models.Venue
+	if err := query.Find(&venues).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve venues"})
+	}
+	return c.JSON(http.StatusOK, venues)
+}
+
+// @Summary		Récupère une salle
+// @Description	Récupère une salle par ID
+// @ID				get-venue
+// @Tags			Venues
+// @Produce		json
+// @Param			id	path		string	true	"ID de la salle"	format(uuid)
+// @Success		200	{object}	models.Venue
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/venues/{id} [get]
+func GetVenue(c echo.Context) error {
+	db := database.GetDB()
+
+	var venue models.Venue
+	if err := db.Where("id = ?", c.Param("id")).First(&venue).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Venue not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, venue)
+}
+
+// @Summary		Créé une salle
+// @Description	Créé une salle pour l'organisation de l'organisateur. Un administrateur précise l'organisation.
+// @ID				create-venue
+// @Tags			Venues
+// @Accept			json
+// @Produce		json
+// @Param			venue	body		VenueRequest	true	"Salle à créer"
+// @Success		201		{object}	models.Venue
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/venues [post]
+// @Security		Bearer
+func CreateVenue(c echo.Context) error {
+	db := database.GetDB()
+
+	user, role, err := venueUser(c, db)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	var reqBody VenueRequest
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	venue := models.Venue{
+		ID:             uuid.New(),
+		OrganizationId: user.OrganizationId,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if role == "admin" && reqBody.OrganizationId != nil {
+		venue.OrganizationId = *reqBody.OrganizationId
+	}
+	if venue.OrganizationId == uuid.Nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "organizationId is required"})
+	}
+	reqBody.apply(&venue)
+	if err := validateVenue(&venue); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := db.Create(&venue).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create venue"})
+	}
+
+	c.Logger().Infof("event=VenueCreated venue_id=%s organization_id=%s timestamp=%s", venue.ID, venue.OrganizationId, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, venue)
+}
+
+// @Summary		Modifie une salle
+// @Description	Modifie une salle de l'organisation. Changer le fuseau horaire ne déplace pas les concerts déjà programmés.
+// @ID				update-venue
+// @Tags			Venues
+// @Accept			json
+// @Produce		json
+// @Param			id		path		string			true	"ID de la salle"	format(uuid)
+// @Param			venue	body		VenueRequest	true	"Champs à modifier"
+// @Success		200		{object}	models.Venue
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/venues/{id} [patch]
+// @Security		Bearer
+func UpdateVenue(c echo.Context) error {
+	db := database.GetDB()
+
+	user, role, err := venueUser(c, db)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired token"})
+	}
+
+	var venue models.Venue
+	if err := db.Where("id = ?", c.Param("id")).First(&venue).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Venue not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// La salle d'une autre organisation est présentée comme introuvable
+	if role != "admin" && venue.OrganizationId != user.OrganizationId {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Venue not found"})
+	}
+
+	var reqBody VenueRequest
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	reqBody.apply(&venue)
+	if err := validateVenue(&venue); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	venue.UpdatedAt = time.Now()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&venue).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Concert{}).Where("venue_id = ?", venue.ID).Update("location", venueLabel(&venue)).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update venue"})
+	}
+
+	return c.JSON(http.StatusOK, venue)
+}
+
+// NearbyConcert est un concert à venir et la distance de sa salle au point recherché
+type NearbyConcert struct {
+	models.Concert
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// @Summary		Concerts à proximité
+// @Description	Récupère les concerts à venir et non annulés dont la salle est à moins de radius kilomètres du point donné, du plus proche au plus lointain
+// @ID				get-nearby-concerts
+// @Tags			Concerts
+// @Produce		json
+// @Param			lat		query		number	true	"Latitude"
+// @Param			lon		query		number	true	"Longitude"
+// @Param			radius	query		number	false	"Rayon en kilomètres (25 par défaut, 500 au maximum)"
+// @Param			limit	query		int		false	"Nombre de concerts (20 par défaut, 100 au maximum)"
+// @Success		200		{array}		NearbyConcert
+// @Failure		400		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/concerts/nearby [get]
+func GetNearbyConcerts(c echo.Context) error {
+	db := database.GetDB()
+
+	lat, errLat := strconv.ParseFloat(c.QueryParam("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.QueryParam("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid lat or lon value"})
+	}
+	radius := nearbyDefaultRadiusKm
+	if value := c.QueryParam("radius"); value != "" {
+		r, err := strconv.ParseFloat(value, 64)
+		if err != nil || r <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid radius value"})
+		}
+		radius = min(r, nearbyMaxRadiusKm)
+	}
+	limit, err := parsePositiveQueryInt(c, "limit", catalogDefaultLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	limit = min(limit, catalogMaxLimit)
+
+	// Formule de haversine ; le filtre sur la latitude écarte d'abord les salles trop éloignées
+	distance := "2 * @earth * asin(sqrt(power(sin(radians(venues.latitude - @lat) / 2), 2) + " +
+		"cos(radians(@lat)) * cos(radians(venues.latitude)) * power(sin(radians(venues.longitude - @lon) / 2), 2)))"
+	args := map[string]interface{}{
+		"earth":  earthRadiusKm,
+		"lat":    lat,
+		"lon":    lon,
+		"radius": radius,
+		"delta":  radius / 111.0,
+		"now":    time.Now(),
+		"hidden": []string{models.ConcertDraft, models.ConcertCancelled},
+		"limit":  limit,
+	}
+
+	var rows []struct {
+		ID         uuid.UUID
+		DistanceKm float64
+	}
+	if err := db.Raw("SELECT * FROM (SELECT concerts.id AS id, "+distance+" AS distance_km "+
+		"FROM concerts JOIN venues ON venues.id = concerts.venue_id "+
+		"WHERE concerts.deleted_at IS NULL AND venues.deleted_at IS NULL "+
+		"AND venues.latitude BETWEEN @lat - @delta AND @lat + @delta "+
+		"AND concerts.date >= @now AND concerts.status NOT IN @hidden) AS nearby "+
+		"WHERE distance_km <= @radius ORDER BY distance_km ASC LIMIT @limit", args).Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve nearby concerts"})
+	}
+
+	results := []NearbyConcert{}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusOK, results)
+	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var concerts []models.Concert
+	if err := db.Preload("Venue").Preload("Artist").Preload("Interests").Where("id IN ?", ids).Find(&concerts).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve nearby concerts"})
+	}
+	byID := make(map[uuid.UUID]models.Concert, len(concerts))
+	for _, concert := range concerts {
+		byID[concert.ID] = concert
+	}
+	for _, row := range rows {
+		if concert, ok := byID[row.ID]; ok {
+			results = append(results, NearbyConcert{Concert: concert, DistanceKm: row.DistanceKm})
+		}
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,492 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errWaitlistDuplicate = errors.New("you are already on the waitlist for this concert and category")
+var errWaitlistClosed = errors.New("this concert no longer accepts waitlist subscriptions")
+
+// Intervalle entre deux passages du sweeper des alertes de liste d'attente
+const waitlistSweepInterval = 30 * time.Second
+
+func getWaitlistWindow() time.Duration {
+	return time.Duration(getConfigInt("WAITLIST_WINDOW_MINUTES", 15)) * time.Minute
+}
+
+// nextWaitlistEntry renvoie le premier inscrit encore en attente qui accepte ce prix pour cette
+// catégorie. alerted exclut les inscrits déjà prévenus pour la même annonce ou la même catégorie.
+func nextWaitlistEntry(tx *gorm.DB, concertID, concertCategoryID uuid.UUID, price models.Money, excludeUserID uuid.UUID, alerted *gorm.DB) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	err := tx.Where("status = ? AND concert_id = ? AND user_id <> ?", models.WaitlistActive, concertID, excludeUserID).
+		Where("concert_category_id IS NULL OR concert_category_id = ?", concertCategoryID).
+		Where("max_price_amount = 0 OR (max_price_currency = ? AND max_price_amount >= ?)", price.Currency, price.Amount).
+		Where("id NOT IN (?)", alerted).
+		Order("created_at ASC").
+		First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// dispatchListingAlert réserve une annonce disponible au premier inscrit intéressé et lui ouvre
+// une fenêtre d'achat exclusive. Renvoie nil si personne n'attend cette annonce.
+func dispatchListingAlert(db *gorm.DB, listingID uuid.UUID) (*models.WaitlistAlert, error) {
+	var alert *models.WaitlistAlert
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var listing models.TicketListing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", listingID).First(&listing).Error; err != nil {
+			return err
+		}
+		if listing.Status != models.ListingAvailable {
+			return nil
+		}
+		var ticket models.Ticket
+		if err := tx.Preload("ConcertCategory").Where("id = ?", listing.TicketId).First(&ticket).Error; err != nil {
+			return err
+		}
+
+		alerted := tx.Model(&models.WaitlistAlert{}).Select("entry_id").Where("ticket_listing_id = ?", listing.ID)
+		entry, err := nextWaitlistEntry(tx, ticket.ConcertCategory.ConcertId, ticket.ConcertCategoryId, listing.Price, ticket.UserId, alerted)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(getWaitlistWindow())
+		listing.ReservedById = &entry.UserId
+		listing.ReservedUntil = &expiresAt
+		if err := transitionListing(tx, &listing, models.ListingReserved); err != nil {
+			return err
+		}
+
+		alert = &models.WaitlistAlert{
+			ID:              uuid.New(),
+			EntryId:         entry.ID,
+			UserId:          entry.UserId,
+			TicketListingId: &listing.ID,
+			Price:           listing.Price,
+			Status:          models.WaitlistAlertOpen,
+			ExpiresAt:       expiresAt,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		return tx.Create(alert).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// dispatchInventoryAlert bloque une place libérée d'une catégorie pour le premier inscrit intéressé
+// et lui ouvre une fenêtre d'achat exclusive. Renvoie nil s'il n'y a ni place ni inscrit.
+func dispatchInventoryAlert(db *gorm.DB, concertCategoryID uuid.UUID) (*models.WaitlistAlert, error) {
+	var alert *models.WaitlistAlert
+	err := db.Transaction(func(tx *gorm.DB) error {
+		concertCategory, err := lockConcertCategory(tx, concertCategoryID)
+		if err != nil {
+			return err
+		}
+		if err := checkConcertOpen(tx, concertCategory, uuid.Nil); err != nil {
+			if errors.Is(err, errConcertCancelled) || errors.Is(err, errSalesNotOpen) || errors.Is(err, errSalesClosed) {
+				return nil
+			}
+			return err
+		}
+		held, err := heldTickets(tx, concertCategory.ID, uuid.Nil)
+		if err != nil {
+			return err
+		}
+		if concertCategory.SoldTickets+held >= concertCategory.AvailableTickets {
+			return nil
+		}
+
+		alerted := tx.Model(&models.WaitlistAlert{}).Select("entry_id").Where("concert_category_id = ?", concertCategory.ID)
+		entry, err := nextWaitlistEntry(tx, concertCategory.ConcertId, concertCategory.ID, concertCategory.Price, uuid.Nil, alerted)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// La place est bloquée par un hold, comme au démarrage d'un paiement
+		expiresAt := time.Now().Add(getWaitlistWindow())
+		hold, err := findCategoryHold(tx, entry.UserId, concertCategory.ConcertId, concertCategory.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if hold == nil {
+			hold = &models.TicketHold{
+				ID:        uuid.New(),
+				UserId:    entry.UserId,
+				ConcertId: concertCategory.ConcertId,
+				Status:    models.TicketHoldActive,
+				CreatedAt: time.Now(),
+			}
+		}
+		// Un hold déjà posé sur la catégorie couvre la place proposée
+		if hold.ConcertCategoryId == nil {
+			hold.Quantity = 1
+		}
+		hold.ConcertCategoryId = &concertCategory.ID
+		hold.ExpiresAt = expiresAt
+		hold.UpdatedAt = time.Now()
+		if err := tx.Save(hold).Error; err != nil {
+			return err
+		}
+
+		alert = &models.WaitlistAlert{
+			ID:                uuid.New(),
+			EntryId:           entry.ID,
+			UserId:            entry.UserId,
+			ConcertCategoryId: &concertCategory.ID,
+			Price:             concertCategory.Price,
+			Status:            models.WaitlistAlertOpen,
+			ExpiresAt:         expiresAt,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
+		return tx.Create(alert).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// alertWaitlistForListing prévient la liste d'attente d'une nouvelle annonce, en arrière-plan
+func alertWaitlistForListing(listingID uuid.UUID) {
+	db := database.GetDB()
+	alert, err := dispatchListingAlert(db, listingID)
+	if err != nil {
+		fmt.Printf("Failed to alert waitlist for listing %s: %v\n", listingID, err)
+		return
+	}
+	if alert != nil {
+		notifyWaitlistAlert(db, alert)
+	}
+}
+
+// alertWaitlistForInventory prévient la liste d'attente d'une place libérée, en arrière-plan
+func alertWaitlistForInventory(concertCategoryID uuid.UUID) {
+	db := database.GetDB()
+	alert, err := dispatchInventoryAlert(db, concertCategoryID)
+	if err != nil {
+		fmt.Printf("Failed to alert waitlist for category %s: %v\n", concertCategoryID, err)
+		return
+	}
+	if alert != nil {
+		notifyWaitlistAlert(db, alert)
+	}
+}
+
+// convertWaitlistAlerts clôt les alertes ouvertes d'un utilisateur qui a acheté pendant sa
+// fenêtre exclusive, ainsi que les inscriptions correspondantes
+func convertWaitlistAlerts(tx *gorm.DB, userID uuid.UUID, query *gorm.DB) error {
+	var alerts []models.WaitlistAlert
+	if err := query.Where("user_id = ? AND status = ?", userID, models.WaitlistAlertOpen).Find(&alerts).Error; err != nil {
+		return err
+	}
+	for _, alert := range alerts {
+		if err := tx.Model(&models.WaitlistAlert{}).Where("id = ?", alert.ID).
+			Updates(map[string]interface{}{"status": models.WaitlistAlertConverted, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.WaitlistEntry{}).Where("id = ? AND status = ?", alert.EntryId, models.WaitlistActive).
+			Updates(map[string]interface{}{"status": models.WaitlistFulfilled, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireWaitlistAlerts clôt les fenêtres exclusives échues, rend l'annonce ou la place à la vente
+// et prévient l'inscrit suivant
+func expireWaitlistAlerts() {
+	db := database.GetDB()
+
+	var alerts []models.WaitlistAlert
+	if err := db.Where("status = ? AND expires_at <= ?", models.WaitlistAlertOpen, time.Now()).Find(&alerts).Error; err != nil {
+		fmt.Println("Erreur lors de la récupération des alertes échues :", err)
+		return
+	}
+
+	for _, alert := range alerts {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.WaitlistAlert{}).Where("id = ? AND status = ?", alert.ID, models.WaitlistAlertOpen).
+				Updates(map[string]interface{}{"status": models.WaitlistAlertExpired, "updated_at": time.Now()})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			if alert.TicketListingId != nil {
+				return tx.Model(&models.TicketListing{}).
+					Where("id = ? AND status = ? AND reserved_by_id = ?", alert.TicketListingId, models.ListingReserved, alert.UserId).
+					Updates(map[string]interface{}{
+						"status":         models.ListingAvailable,
+						"reserved_by_id": nil,
+						"reserved_until": nil,
+						"updated_at":     time.Now(),
+					}).Error
+			}
+			return releaseTicketHold(tx, alert.UserId, *alert.ConcertCategoryId)
+		})
+		if err != nil {
+			fmt.Printf("Erreur lors de l'expiration de l'alerte %s : %v\n", alert.ID, err)
+			continue
+		}
+
+		if alert.TicketListingId != nil {
+			alertWaitlistForListing(*alert.TicketListingId)
+		} else {
+			alertWaitlistForInventory(*alert.ConcertCategoryId)
+		}
+	}
+}
+
+// StartWaitlistSweeper lance en arrière-plan le passage des fenêtres exclusives à l'inscrit suivant
+func StartWaitlistSweeper() {
+	ticker := time.NewTicker(waitlistSweepInterval)
+	go func() {
+		for range ticker.C {
+			expireWaitlistAlerts()
+		}
+	}()
+}
+
+// notifyWaitlistAlert prévient un inscrit de sa fenêtre d'achat par notification push et par email
+func notifyWaitlistAlert(db *gorm.DB, alert *models.WaitlistAlert) {
+	var entry models.WaitlistEntry
+	if err := db.Preload("User").Preload("Concert").Where("id = ?", alert.EntryId).First(&entry).Error; err != nil {
+		fmt.Printf("Failed to find waitlist entry %s: %v\n", alert.EntryId, err)
+		return
+	}
+
+	deadline := alert.ExpiresAt.Format("15:04")
+	kind := "Une place"
+	data := map[string]string{
+		"alert_id":   alert.ID.String(),
+		"concert_id": entry.ConcertId.String(),
+	}
+	if alert.TicketListingId != nil {
+		kind = "Un ticket en revente"
+		data["ticket_listing_id"] = alert.TicketListingId.String()
+	} else {
+		data["concert_category_id"] = alert.ConcertCategoryId.String()
+	}
+
+	notification := map[string]string{
+		"title": "Une place vous attend",
+		"body":  fmt.Sprintf("%s pour \"%s\" vous est réservé(e) à %s jusqu'à %s.", kind, entry.Concert.Name, alert.Price, deadline),
+	}
+	if err := SendFCMNotification(userTopic(entry.UserId), data, notification); err != nil {
+		fmt.Printf("Failed to send waitlist notification to user %s: %v\n", entry.UserId, err)
+	}
+
+	html := `<!DOCTYPE html>
+<html lang="fr">
+  <body>
+    <h1>Weezemaster</h1>
+    <p>Bonjour ` + entry.User.Firstname + `,</p>
+    <p>` + kind + ` pour le concert <strong>` + entry.Concert.Name + `</strong> est disponible à <strong>` + alert.Price.String() + `</strong>.</p>
+    <p>Il vous est réservé jusqu'à ` + deadline + `. Passé ce délai, il sera proposé à la personne suivante sur la liste d'attente.</p>
+    <p>À bientôt sur <strong>Weezemaster</strong>.</p>
+  </body>
+</html>`
+	if err := sendEmail(entry.User.Email, "Weezemaster - Une place vous attend", html); err != nil {
+		fmt.Printf("Failed to send waitlist email to user %s: %v\n", entry.UserId, err)
+	}
+}
+
+// @Summary		S'inscrit sur la liste d'attente
+// @Description	Inscrit l'utilisateur aux alertes d'un concert, éventuellement limitées à une catégorie et à un prix maximum. Les inscrits sont prévenus dans l'ordre quand une annonce correspondante est publiée ou qu'une place se libère, et disposent d'une fenêtre d'achat exclusive.
+// @ID				create-waitlist-entry
+// @Tags			Waitlist
+// @Accept			json
+// @Produce		json
+// @Param			body	body		object	true	"concertId, concertCategoryId (optionnel) et maxPrice (optionnel)"
+// @Success		201		{object}	models.WaitlistEntry
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/waitlist [post]
+// @Security		Bearer
+func CreateWaitlistEntry(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	var reqBody struct {
+		ConcertId         uuid.UUID    `json:"concertId"`
+		ConcertCategoryId *uuid.UUID   `json:"concertCategoryId"`
+		MaxPrice          models.Money `json:"maxPrice"`
+	}
+	if err := c.Bind(&reqBody); err != nil || reqBody.ConcertId == uuid.Nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if !reqBody.MaxPrice.IsZero() {
+		if err := reqBody.MaxPrice.Validate(); err != nil || !reqBody.MaxPrice.IsPositive() {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid maximum price"})
+		}
+	}
+
+	var entry models.WaitlistEntry
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var concert models.Concert
+		if err := tx.Where("id = ?", reqBody.ConcertId).First(&concert).Error; err != nil {
+			return err
+		}
+		switch concert.Status {
+		case models.ConcertDraft, models.ConcertCancelled, models.ConcertCompleted:
+			return errWaitlistClosed
+		}
+		if !concert.Date.After(time.Now()) {
+			return errWaitlistClosed
+		}
+		if reqBody.ConcertCategoryId != nil {
+			var concertCategory models.ConcertCategory
+			if err := tx.Where("id = ? AND concert_id = ?", reqBody.ConcertCategoryId, concert.ID).First(&concertCategory).Error; err != nil {
+				return err
+			}
+		}
+
+		existing := tx.Model(&models.WaitlistEntry{}).Where("user_id = ? AND concert_id = ? AND status = ?", userID, concert.ID, models.WaitlistActive)
+		if reqBody.ConcertCategoryId != nil {
+			existing = existing.Where("concert_category_id = ?", reqBody.ConcertCategoryId)
+		} else {
+			existing = existing.Where("concert_category_id IS NULL")
+		}
+		var count int64
+		if err := existing.Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errWaitlistDuplicate
+		}
+
+		entry = models.WaitlistEntry{
+			ID:                uuid.New(),
+			UserId:            userID,
+			ConcertId:         concert.ID,
+			ConcertCategoryId: reqBody.ConcertCategoryId,
+			MaxPrice:          reqBody.MaxPrice,
+			Status:            models.WaitlistActive,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Concert or category not found"})
+		case errors.Is(err, errWaitlistDuplicate), errors.Is(err, errWaitlistClosed):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to join the waitlist"})
+	}
+
+	c.Logger().Infof("event=WaitlistJoined entry_id=%s user_id=%s concert_id=%s timestamp=%s", entry.ID, userID, entry.ConcertId, time.Now().Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, entry)
+}
+
+// @Summary		Inscriptions de l'utilisateur
+// @Description	Renvoie les inscriptions de l'utilisateur sur les listes d'attente et ses fenêtres d'achat encore ouvertes
+// @ID				get-user-waitlist
+// @Tags			Waitlist
+// @Produce		json
+// @Success		200	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/user/waitlist [get]
+// @Security		Bearer
+func GetUserWaitlist(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	var entries []models.WaitlistEntry
+	if err := db.Preload("Concert").Preload("ConcertCategory.Category").
+		Where("user_id = ? AND status = ?", userID, models.WaitlistActive).
+		Order("created_at ASC").Find(&entries).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve waitlist"})
+	}
+
+	var alerts []models.WaitlistAlert
+	if err := db.Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.WaitlistAlertOpen, time.Now()).
+		Order("expires_at ASC").Find(&alerts).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve waitlist alerts"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"alerts":  alerts,
+	})
+}
+
+// @Summary		Quitte une liste d'attente
+// @Description	Annule une inscription. Une fenêtre d'achat en cours est rendue à l'inscrit suivant.
+// @ID				delete-waitlist-entry
+// @Tags			Waitlist
+// @Produce		json
+// @Param			id	path	string	true	"ID de l'inscription"	format(uuid)
+// @Success		204
+// @Failure		401	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/waitlist/{id} [delete]
+// @Security		Bearer
+func DeleteWaitlistEntry(c echo.Context) error {
+	db := database.GetDB()
+
+	userID, err := authenticatedUserID(c)
+	if userID == uuid.Nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.WaitlistEntry{}).
+			Where("id = ? AND user_id = ? AND status = ?", c.Param("id"), userID, models.WaitlistActive).
+			Updates(map[string]interface{}{"status": models.WaitlistCancelled, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// Le sweeper rendra la fenêtre ouverte à l'inscrit suivant à son prochain passage
+		return tx.Model(&models.WaitlistAlert{}).
+			Where("entry_id = ? AND status = ?", c.Param("id"), models.WaitlistAlertOpen).
+			Update("expires_at", time.Now()).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Waitlist entry not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to leave the waitlist"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
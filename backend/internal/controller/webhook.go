@@ -0,0 +1,303 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"weezemaster/internal/config"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+	"weezemaster/internal/payments"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errPaymentClosed = errors.New("payment can no longer be fulfilled")
+var errPaymentAmountMismatch = errors.New("the price changed after the payment was created")
+
+// Intervalle entre deux relances des remboursements de paiements non honorés
+const unfulfilledRefundInterval = 5 * time.Minute
+
+type StripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// fulfillmentErrorStatus traduit une erreur d'émission en code HTTP
+func fulfillmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errNoTicketsAvailable), errors.Is(err, errListingNotAvailable),
+		errors.Is(err, errBuyerIsOwner), errors.Is(err, errTicketCapExceeded),
+		errors.Is(err, errOrderNotPending), errors.Is(err, errPaymentClosed), errors.Is(err, errConcertCancelled),
+		errors.Is(err, errNoAcceptedOffer), errors.Is(err, errNotConversationParty),
+		errors.Is(err, errSeatUnavailable), errors.Is(err, errSeatSelectionRequired),
+		errors.Is(err, errSalesNotOpen), errors.Is(err, errSalesClosed), errors.Is(err, errPaymentAmountMismatch):
+		return http.StatusConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// isFulfillmentRejection indique si l'émission a échoué pour une raison métier : le paiement
+// ne pourra jamais être honoré et doit être remboursé plutôt que retenté
+func isFulfillmentRejection(err error) bool {
+	status := fulfillmentErrorStatus(err)
+	return status == http.StatusConflict || status == http.StatusNotFound
+}
+
+// fulfillPayment honore un paiement confirmé en émettant le ticket, la revente ou la commande
+// correspondant. L'opération est idempotente : un paiement déjà honoré est renvoyé tel quel.
+func fulfillPayment(db *gorm.DB, providerPaymentID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Verrouille le paiement pour que le webhook et le client ne l'honorent pas deux fois
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider_payment_id = ?", providerPaymentID).First(&payment).Error; err != nil {
+			return err
+		}
+
+		switch payment.Status {
+		case models.PaymentSucceeded:
+			return nil
+		case models.PaymentRefunded, models.PaymentFulfillmentFailed:
+			return errPaymentClosed
+		}
+
+		switch payment.ItemType {
+		case models.PaymentItemConcertCategory:
+			ticket, err := issueTicket(tx, payment.UserId, payment.ItemId)
+			if err != nil {
+				return err
+			}
+			payment.TicketId = &ticket.ID
+		case models.PaymentItemOrder:
+			order, err := fulfillOrder(tx, payment.ItemId)
+			if err != nil {
+				return err
+			}
+			payment.OrderId = &order.ID
+		case models.PaymentItemTicketListing:
+			var ticketListing models.TicketListing
+			if err := tx.Where("id = ?", payment.ItemId).First(&ticketListing).Error; err != nil {
+				return err
+			}
+			// Le vendeur a pu changer son prix depuis la création du paiement : la revente n'est
+			// conclue qu'au montant réellement payé, sinon le paiement est remboursé
+			if ticketListing.Price != payment.Amount {
+				return errPaymentAmountMismatch
+			}
+			sale, err := completeResale(tx, ticketListing.ID, payment.UserId, payment.Amount)
+			if err != nil {
+				return err
+			}
+			payment.SaleId = &sale.ID
+		case models.PaymentItemConversation:
+			sale, err := completeConversationResale(tx, payment.ItemId, payment.UserId)
+			if err != nil {
+				return err
+			}
+			// Même contrôle pour une offre acceptée remplacée par une autre depuis le paiement
+			if sale.FinalPrice != payment.Amount {
+				return errPaymentAmountMismatch
+			}
+			payment.SaleId = &sale.ID
+		default:
+			return fmt.Errorf("unknown payment item type %q", payment.ItemType)
+		}
+
+		if err := recordPaymentInLedger(tx, &payment); err != nil {
+			return err
+		}
+
+		payment.Status = models.PaymentSucceeded
+		payment.UpdatedAt = time.Now()
+		return tx.Save(&payment).Error
+	})
+
+	if err != nil && isFulfillmentRejection(err) && !errors.Is(err, errPaymentClosed) && payment.ID != uuid.Nil {
+		// Le paiement a abouti mais ne peut pas être honoré (plus de places, annonce déjà vendue...) :
+		// il est remboursé, ou le sera par StartUnfulfilledPaymentRefunder si le prestataire échoue
+		res := db.Model(&models.Payment{}).Where("id = ? AND status = ?", payment.ID, models.PaymentPending).
+			Updates(map[string]interface{}{"status": models.PaymentFulfillmentFailed, "updated_at": time.Now()})
+		if res.Error == nil && res.RowsAffected > 0 {
+			if refundErr := refundUnfulfilledPayment(context.Background(), db, payment.ID); refundErr != nil {
+				fmt.Printf("Erreur lors du remboursement du paiement non honoré %s : %v\n", payment.ID, refundErr)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// refundUnfulfilledPayment rembourse intégralement un paiement abouti qui n'a pas pu être honoré.
+// La clé d'idempotence dérivée du paiement garantit un seul remboursement malgré les relances.
+func refundUnfulfilledPayment(ctx context.Context, db *gorm.DB, paymentID uuid.UUID) error {
+	var payment models.Payment
+	if err := db.Where("id = ? AND status = ?", paymentID, models.PaymentFulfillmentFailed).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Déjà remboursé, par exemple par le webhook charge.refunded
+			return nil
+		}
+		return err
+	}
+
+	if _, err := payments.GetProvider().Refund(ctx, payment.ProviderPaymentId, 0, "fulfillment_refund_"+payment.ID.String()); err != nil {
+		return err
+	}
+	return db.Model(&models.Payment{}).Where("id = ? AND status = ?", payment.ID, models.PaymentFulfillmentFailed).
+		Updates(map[string]interface{}{
+			"status":          models.PaymentRefunded,
+			"refunded_amount": payment.Amount.Amount,
+			"updated_at":      time.Now(),
+		}).Error
+}
+
+// refundUnfulfilledPayments relance le remboursement des paiements restés non honorés
+func refundUnfulfilledPayments() {
+	db := database.GetDB()
+
+	var ids []uuid.UUID
+	if err := db.Model(&models.Payment{}).Where("status = ?", models.PaymentFulfillmentFailed).Pluck("id", &ids).Error; err != nil {
+		fmt.Println("Erreur lors de la récupération des paiements non honorés :", err)
+		return
+	}
+	for _, id := range ids {
+		if err := refundUnfulfilledPayment(context.Background(), db, id); err != nil {
+			fmt.Printf("Erreur lors du remboursement du paiement non honoré %s : %v\n", id, err)
+			continue
+		}
+		fmt.Printf("Paiement non honoré %s remboursé\n", id)
+	}
+}
+
+// StartUnfulfilledPaymentRefunder lance en arrière-plan la relance périodique des remboursements
+// de paiements non honorés
+func StartUnfulfilledPaymentRefunder() {
+	ticker := time.NewTicker(unfulfilledRefundInterval)
+	go func() {
+		for range ticker.C {
+			refundUnfulfilledPayments()
+		}
+	}()
+}
+
+// updatePaymentStatus met à jour le statut d'un paiement sans revenir sur un paiement déjà honoré
+func updatePaymentStatus(db *gorm.DB, providerPaymentID, status string, fromStatuses ...string) error {
+	return db.Model(&models.Payment{}).
+		Where("provider_payment_id = ? AND status IN ?", providerPaymentID, fromStatuses).
+		Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}).Error
+}
+
+// @Summary		Webhook Stripe
+// @Description	Reçoit les événements Stripe signés et honore les paiements confirmés
+// @ID				stripe-webhook
+// @Tags			Payment
+// @Accept			json
+// @Produce		json
+// @Param			Stripe-Signature	header	string	true	"Signature Stripe"
+// @Success		200	{object}	map[string]string
+// @Failure		400	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/webhooks/stripe [post]
+func HandleStripeWebhook(c echo.Context) error {
+	db := database.GetDB()
+
+	payload, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+	}
+
+	if err := payments.VerifyStripeSignature(payload, c.Request().Header.Get("Stripe-Signature"), config.StripeWebhookSecret, time.Now()); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid signature"})
+	}
+
+	var event StripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event payload"})
+	}
+
+	var object struct {
+		ID            string `json:"id"`
+		PaymentIntent string `json:"payment_intent"`
+		Refunded      bool   `json:"refunded"`
+		Refunds       struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		} `json:"refunds"`
+	}
+	if err := json.Unmarshal(event.Data.Object, &object); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event object"})
+	}
+
+	switch event.Type {
+	case "payment_intent.succeeded":
+		payment, err := fulfillPayment(db, object.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Payment intent créé en dehors de l'API : rien à honorer
+				return c.JSON(http.StatusOK, map[string]string{"status": "ignored"})
+			}
+			if isFulfillmentRejection(err) {
+				c.Logger().Errorf("event=PaymentFulfillmentFailed payment_intent=%s error=%s timestamp=%s", object.ID, err, time.Now().Format(time.RFC3339))
+				return c.JSON(http.StatusOK, map[string]string{"status": "fulfillment_failed"})
+			}
+			// Stripe renverra l'événement plus tard
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fulfill payment"})
+		}
+		c.Logger().Infof("event=PaymentSucceeded payment_id=%s user_id=%s timestamp=%s", payment.ID, payment.UserId, time.Now().Format(time.RFC3339))
+	case "payment_intent.payment_failed":
+		if err := updatePaymentStatus(db, object.ID, models.PaymentFailed, models.PaymentPending); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update payment"})
+		}
+		// Libère immédiatement les places bloquées plutôt que d'attendre l'expiration des holds
+		var payment models.Payment
+		if err := db.Where("provider_payment_id = ?", object.ID).First(&payment).Error; err == nil {
+			var err error
+			switch payment.ItemType {
+			case models.PaymentItemConcertCategory:
+				err = releaseTicketHold(db, payment.UserId, payment.ItemId)
+			case models.PaymentItemOrder:
+				err = releaseOrderHolds(db, payment.UserId, payment.ItemId)
+			}
+			if err != nil {
+				c.Logger().Errorf("event=TicketHoldReleaseFailed payment_id=%s error=%s", payment.ID, err)
+			}
+		}
+		c.Logger().Infof("event=PaymentFailed payment_intent=%s timestamp=%s", object.ID, time.Now().Format(time.RFC3339))
+	case "charge.refunded":
+		// Un remboursement partiel (un ticket d'une commande) laisse le paiement honoré
+		if !object.Refunded {
+			break
+		}
+		// Le remboursement le plus récent est en tête de liste
+		refundID := object.ID
+		if len(object.Refunds.Data) > 0 {
+			refundID = object.Refunds.Data[0].ID
+		}
+		tickets, err := applyProviderRefund(db, object.PaymentIntent, refundID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.JSON(http.StatusOK, map[string]string{"status": "ignored"})
+			}
+			c.Logger().Errorf("event=PaymentRefundFailed payment_intent=%s error=%s timestamp=%s", object.PaymentIntent, err, time.Now().Format(time.RFC3339))
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to apply refund"})
+		}
+		c.Logger().Infof("event=PaymentRefunded payment_intent=%s tickets=%d timestamp=%s", object.PaymentIntent, tickets, time.Now().Format(time.RFC3339))
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
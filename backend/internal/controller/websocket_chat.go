@@ -33,8 +33,11 @@ type MessagePayload struct {
 }
 
 type PriceUpdatePayload struct {
-	ConversationID string  `json:"conversation_id,omitempty"`
-	NewPrice       float64 `json:"new_price,omitempty"`
+	ConversationID string       `json:"conversation_id,omitempty"`
+	NewPrice       models.Money `json:"new_price,omitempty"`
+	OfferID        string       `json:"offer_id,omitempty"`
+	ProposerID     string       `json:"proposer_id,omitempty"`
+	Status         string       `json:"status,omitempty"`
 }
 
 // HandleWebSocket gère les connexions WebSocket pour toutes les conversations
@@ -130,14 +133,9 @@ func HandleWebSocketChat(c echo.Context) error {
 
 		// Vérifier si le message est une mise à jour de prix
 		if msgPayload.Content == "" {
-			var priceUpdatePayload PriceUpdatePayload
-			if err := json.Unmarshal(message, &priceUpdatePayload); err != nil {
-				fmt.Println("Erreur de parsing du message de mise à jour de prix: ", err)
-				continue
-			}
-			fmt.Println("Mise à jour de prix reçue: ", priceUpdatePayload)
-			// Diffuser la mise à jour du prix aux autres connexions dans la même room
-			broadcastMessage(conversationID, message)
+			// Les prix ne se négocient que par les offres (/conversations/:id/offers), diffusées
+			// par le serveur : une mise à jour envoyée par un client n'est pas relayée
+			fmt.Println("Mise à jour de prix ignorée, utiliser les offres: ", string(message))
 			continue
 		}
 
@@ -2,15 +2,16 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"sync"
 	"time"
-	"weezemaster/internal/config"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 var upgraderQueue = websocket.Upgrader{
@@ -24,8 +25,18 @@ var authorized = make(map[string][]*UserConnection)
 var queueMutex = sync.Mutex{}
 
 type UserConnection struct {
-	UserID string
-	Conn   *websocket.Conn
+	UserID     string
+	Conn       *websocket.Conn
+	writeMutex sync.Mutex
+}
+
+// send écrit un message JSON sur la connexion. Une connexion WebSocket n'accepte qu'une écriture
+// à la fois : tous les messages de la file passent par send, avec ou sans queueMutex.
+func (uc *UserConnection) send(message Message) error {
+	messageBytes, _ := json.Marshal(message)
+	uc.writeMutex.Lock()
+	defer uc.writeMutex.Unlock()
+	return uc.Conn.WriteMessage(websocket.TextMessage, messageBytes)
 }
 
 // Message struct pour formater les messages WebSocket en JSON
@@ -34,40 +45,72 @@ type Message struct {
 	Position       int    `json:"position,omitempty"`
 	ConcertID      string `json:"concertId,omitempty"`
 	IsFirstMessage bool   `json:"isFirstMessage"`
+	HoldExpiresAt  string `json:"holdExpiresAt,omitempty"`
+	SalesOpenAt    string `json:"salesOpenAt,omitempty"`
 }
 
 // Intervalle pour les pings en secondes
 const pingInterval = 30 * time.Second
 
 func getMaxUsers() int {
-	err := config.LoadConfig("../../cmd/weezemaster/config/weezemaster.config")
-	if err != nil {
-		fmt.Println("Erreur lors du chargement de la configuration :", err)
-		return 100
+	return getConfigInt("CONCERTS_MAX_USERS_BEFORE_QUEUE", 100)
+}
+
+// queueUserID authentifie la connexion à la file d'attente. Un client WebSocket ne pouvant pas
+// toujours envoyer d'en-tête Authorization, l'access token est aussi accepté dans le paramètre token.
+func queueUserID(c echo.Context) uuid.UUID {
+	if userID := optionalUserID(c); userID != uuid.Nil {
+		return userID
 	}
-	maxUsers, err := strconv.Atoi(config.Config["CONCERTS_MAX_USERS_BEFORE_QUEUE"])
+	token := c.QueryParam("token")
+	if token == "" {
+		return uuid.Nil
+	}
+	claims, err := verifyToken(token)
 	if err != nil {
-		fmt.Println("Erreur lors de la conversion de CONCERTS_MAX_USERS_BEFORE_QUEUE :", err)
-		return 100
+		return uuid.Nil
+	}
+	id, ok := claims["id"].(string)
+	if !ok {
+		return uuid.Nil
 	}
-	return maxUsers
+	userID, _ := uuid.Parse(id)
+	return userID
 }
 
 // HandleWebSocketQueue gère les connexions WebSocket pour la file d'attente des concerts
 // @Summary Gère les connexions WebSocket pour la file d'attente des concerts
-// @Description Gère les connexions WebSocket pour la file d'attente des concerts
+// @Description Gère les connexions WebSocket pour la file d'attente des concerts. L'utilisateur est celui de l'access token, passé dans l'en-tête Authorization ou le paramètre token. Avant l'ouverture programmée de la vente, les utilisateurs patientent en salle d'attente (statut "waiting_for_sales") et entrent dans l'ordre d'arrivée à l'ouverture. La connexion est refusée si la vente n'est pas programmée ou est fermée.
 // @ID handle-websocket-queue
 // @Tags WebSockets
 // @Param concertId query string true "ID du concert" format(uuid)
-// @Param userId query string true "ID de l'utilisateur" format(uuid)
+// @Param token query string false "Access token, si l'en-tête Authorization n'est pas envoyé"
 // @Success 101 {object} Message
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /ws-queue [get]
 func HandleWebSocketQueue(c echo.Context) error {
 	concertID := c.QueryParam("concertId")
-	userID := c.QueryParam("userId")
+	if concertID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "ConcertID requis")
+	}
+	userUUID := queueUserID(c)
+	if userUUID == uuid.Nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+	userID := userUUID.String()
 
-	if concertID == "" || userID == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "ConcertID et UserID requis")
+	opensAt, err := queueOpening(concertID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Concert not found")
+		}
+		if errors.Is(err, errSalesNotOpen) || errors.Is(err, errSalesClosed) || errors.Is(err, errConcertCancelled) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	conn, err := upgraderQueue.Upgrade(c.Response(), c.Request(), nil)
@@ -76,12 +119,13 @@ func HandleWebSocketQueue(c echo.Context) error {
 	}
 	defer conn.Close()
 
-	// Routine pour envoyer des pings périodiquement pour garder la connexion active
+	// Routine pour envoyer des pings périodiquement pour garder la connexion active.
+	// WriteControl peut être appelé en parallèle des messages envoyés par send.
 	ticker := time.NewTicker(pingInterval)
 	defer ticker.Stop()
 	go func() {
 		for range ticker.C {
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingInterval)); err != nil {
 				fmt.Println("Erreur lors de l'envoi du ping :", err)
 				break
 			}
@@ -89,7 +133,7 @@ func HandleWebSocketQueue(c echo.Context) error {
 	}()
 
 	// Gérer l’entrée de l’utilisateur dans la file d’attente
-	if err := handleQueue(userID, concertID, conn); err != nil {
+	if err := handleQueue(&UserConnection{UserID: userID, Conn: conn}, concertID, opensAt); err != nil {
 		return err
 	}
 
@@ -105,59 +149,101 @@ func HandleWebSocketQueue(c echo.Context) error {
 	return nil
 }
 
-// handleQueue ajoute un utilisateur à la file d'attente ou l'accepte dans la salle si possible
-func handleQueue(userID, concertID string, conn *websocket.Conn) error {
+// handleQueue ajoute un utilisateur à la file d'attente ou l'accepte dans la salle si possible.
+// opensAt est la date d'ouverture de la vente tant qu'elle n'a pas commencé : l'utilisateur
+// patiente alors dans la file jusqu'à admitFromQueue. Le hold d'accès est créé après avoir
+// relâché queueMutex.
+func handleQueue(user *UserConnection, concertID string, opensAt *time.Time) error {
 	queueMutex.Lock()
-	defer queueMutex.Unlock()
-
-	maxUsers := getMaxUsers()
 
 	// Vérifie si l'utilisateur est déjà dans la liste des utilisateurs autorisés
+	granted := false
 	for _, uc := range authorized[concertID] {
-		if uc.UserID == userID {
-			message := Message{Status: "access_granted", ConcertID: concertID, IsFirstMessage: true}
-			messageBytes, _ := json.Marshal(message)
-			return conn.WriteMessage(websocket.TextMessage, messageBytes)
+		if uc.UserID == user.UserID {
+			granted = true
+			break
 		}
 	}
 
-	// Calcul du nombre d'utilisateurs déjà autorisés
-	currentInConcert := len(authorized[concertID])
-
-	// Si la limite est atteinte, ajoute l'utilisateur en file d'attente
-	if currentInConcert >= maxUsers {
-		queue[concertID] = append(queue[concertID], &UserConnection{UserID: userID, Conn: conn})
-		position := len(queue[concertID])
+	if !granted {
+		// La vente n'est pas encore ouverte : l'utilisateur patiente en salle d'attente
+		if opensAt != nil {
+			queue[concertID] = append(queue[concertID], user)
+			message := waitingMessage(concertID, len(queue[concertID]), opensAt, true)
+			fmt.Printf("User %s en salle d'attente pour le concert %s à la position %d\n", user.UserID, concertID, message.Position)
+			err := user.send(message)
+			queueMutex.Unlock()
+			return err
+		}
 
-		message := Message{
-			Status:         "in_queue",
-			Position:       position,
-			ConcertID:      concertID,
-			IsFirstMessage: true,
+		// Si la limite est atteinte, ajoute l'utilisateur en file d'attente
+		if len(authorized[concertID]) >= getMaxUsers() {
+			queue[concertID] = append(queue[concertID], user)
+			position := len(queue[concertID])
+			fmt.Printf("User %s ajouté à la file d'attente pour le concert %s à la position %d\n", user.UserID, concertID, position)
+			err := user.send(Message{Status: "in_queue", Position: position, ConcertID: concertID, IsFirstMessage: true})
+			queueMutex.Unlock()
+			return err
 		}
-		messageBytes, _ := json.Marshal(message)
-		fmt.Printf("User %s ajouté à la file d'attente pour le concert %s à la position %d\n", userID, concertID, position)
 
-		return conn.WriteMessage(websocket.TextMessage, messageBytes)
+		// Ajouter l'utilisateur aux utilisateurs autorisés
+		authorized[concertID] = append(authorized[concertID], user)
+		fmt.Printf("User %s accepté dans la salle pour le concert %s\n", user.UserID, concertID)
 	}
+	queueMutex.Unlock()
 
-	// Ajouter l'utilisateur aux utilisateurs autorisés
-	authorized[concertID] = append(authorized[concertID], &UserConnection{UserID: userID, Conn: conn})
-	message := Message{Status: "access_granted", ConcertID: concertID, IsFirstMessage: true}
-	messageBytes, _ := json.Marshal(message)
-	fmt.Printf("User %s accepté dans la salle pour le concert %s\n", userID, concertID)
+	return user.send(accessGrantedMessage(user.UserID, concertID, true))
+}
 
-	return conn.WriteMessage(websocket.TextMessage, messageBytes)
+// accessGrantedMessage crée le hold d'accès de l'utilisateur et construit le message "access_granted".
+// Il accède à la base : queueMutex ne doit pas être verrouillé par l'appelant.
+func accessGrantedMessage(userID, concertID string, isFirstMessage bool) Message {
+	message := Message{Status: "access_granted", ConcertID: concertID, IsFirstMessage: isFirstMessage}
+	hold, err := grantQueueHold(userID, concertID)
+	if err != nil {
+		fmt.Printf("Erreur lors de la création du hold pour l'utilisateur %s : %v\n", userID, err)
+		return message
+	}
+	message.HoldExpiresAt = hold.ExpiresAt.Format(time.RFC3339)
+	return message
 }
 
-// removeUserFromQueue retire un utilisateur spécifique de la file d'attente lorsque sa connexion est fermée
-// removeUserFromQueue retire un utilisateur spécifique de la file d'attente
-func removeUserFromQueue(concertID, userID string) {
+// grantAccess envoie "access_granted" aux utilisateurs sortis de la file d'attente.
+// queueMutex ne doit pas être verrouillé par l'appelant.
+func grantAccess(concertID string, users []*UserConnection) {
+	for _, user := range users {
+		if err := user.send(accessGrantedMessage(user.UserID, concertID, false)); err != nil {
+			fmt.Printf("Erreur d'écriture WebSocket : %v\n", err)
+		}
+		fmt.Printf("User %s promu pour entrer dans le concert %s\n", user.UserID, concertID)
+	}
+}
+
+// revokeQueueAccess retire l'accès à la salle d'un utilisateur dont le hold a expiré
+// et laisse entrer le suivant dans la file d'attente
+func revokeQueueAccess(concertID, userID string) {
 	queueMutex.Lock()
-	defer queueMutex.Unlock()
+	found := false
+	for _, uc := range authorized[concertID] {
+		if uc.UserID == userID {
+			found = true
+			if err := uc.send(Message{Status: "access_expired", ConcertID: concertID, IsFirstMessage: false}); err != nil {
+				fmt.Printf("Erreur d'écriture WebSocket : %v\n", err)
+			}
+			break
+		}
+	}
+	queueMutex.Unlock()
 
-	maxUsers := getMaxUsers()
+	if found {
+		removeUserFromQueue(concertID, userID)
+	}
+}
 
+// removeUserFromQueue retire un utilisateur spécifique de la file d'attente ou de la salle et
+// laisse entrer les suivants si la vente est ouverte
+func removeUserFromQueue(concertID, userID string) {
+	queueMutex.Lock()
 	// Vérifie d'abord si l'utilisateur est dans la liste des utilisateurs autorisés
 	if authorizedUsers, ok := authorized[concertID]; ok {
 		for i, uc := range authorizedUsers {
@@ -170,26 +256,6 @@ func removeUserFromQueue(concertID, userID string) {
 		}
 	}
 
-	// Si la liste des utilisateurs autorisés est en dessous de la limite, on promeut le prochain en file d'attente
-	if len(authorized[concertID]) < maxUsers {
-		if queueUsers, ok := queue[concertID]; ok && len(queueUsers) > 0 {
-			// Sélectionne le premier utilisateur en attente dans la file (index 0)
-			nextUser := queueUsers[0]
-			queue[concertID] = queueUsers[1:] // Retire l'utilisateur promu de la file
-
-			// Ajoute l'utilisateur promu aux utilisateurs autorisés
-			authorized[concertID] = append(authorized[concertID], nextUser)
-
-			// Envoie une notification de type "access_granted" au nouvel utilisateur autorisé
-			message := Message{Status: "access_granted", ConcertID: concertID, IsFirstMessage: false}
-			messageBytes, _ := json.Marshal(message)
-			if err := nextUser.Conn.WriteMessage(websocket.TextMessage, messageBytes); err != nil {
-				fmt.Printf("Erreur d'écriture WebSocket : %v\n", err)
-			}
-			fmt.Printf("User %s promu pour entrer dans le concert %s\n", nextUser.UserID, concertID)
-		}
-	}
-
 	// Gestion du décalage dans la file d'attente si un utilisateur en file d'attente quitte
 	if queueUsers, ok := queue[concertID]; ok {
 		for i, user := range queueUsers {
@@ -200,14 +266,119 @@ func removeUserFromQueue(concertID, userID string) {
 				break
 			}
 		}
+	}
+	queueMutex.Unlock()
+
+	// Personne n'entre tant que la vente n'est pas ouverte
+	opensAt, err := queueOpening(concertID)
+	if err != nil {
+		return
+	}
+
+	queueMutex.Lock()
+	var promoted []*UserConnection
+	if opensAt == nil {
+		promoted = promoteFromQueue(concertID)
+	}
+	notifyQueuePositions(concertID, opensAt)
+	queueMutex.Unlock()
+
+	grantAccess(concertID, promoted)
+}
+
+// promoteFromQueue fait passer les premiers utilisateurs de la file d'attente dans la salle, dans
+// la limite de la salle, et les renvoie pour que l'appelant leur accorde l'accès avec grantAccess.
+// queueMutex doit être verrouillé par l'appelant.
+func promoteFromQueue(concertID string) []*UserConnection {
+	free := getMaxUsers() - len(authorized[concertID])
+	if free <= 0 || len(queue[concertID]) == 0 {
+		return nil
+	}
+	if free > len(queue[concertID]) {
+		free = len(queue[concertID])
+	}
+	promoted := append([]*UserConnection(nil), queue[concertID][:free]...)
+	queue[concertID] = queue[concertID][free:]
+	authorized[concertID] = append(authorized[concertID], promoted...)
+	return promoted
+}
+
+// notifyQueuePositions envoie sa position à chaque utilisateur restant dans la file d'attente.
+// queueMutex doit être verrouillé par l'appelant.
+func notifyQueuePositions(concertID string, opensAt *time.Time) {
+	for index, user := range queue[concertID] {
+		updatedMessage := Message{Status: "in_queue", Position: index + 1, ConcertID: concertID, IsFirstMessage: false}
+		if opensAt != nil {
+			updatedMessage = waitingMessage(concertID, index+1, opensAt, false)
+		}
+		if err := user.send(updatedMessage); err != nil {
+			fmt.Printf("Erreur lors de la mise à jour de la position pour l'utilisateur %s : %v\n", user.UserID, err)
+		}
+	}
+}
+
+// waitingMessage construit le message "waiting_for_sales" d'un utilisateur en salle d'attente
+func waitingMessage(concertID string, position int, opensAt *time.Time, isFirstMessage bool) Message {
+	return Message{
+		Status:         "waiting_for_sales",
+		Position:       position,
+		ConcertID:      concertID,
+		IsFirstMessage: isFirstMessage,
+		SalesOpenAt:    opensAt.Format(time.RFC3339),
+	}
+}
 
-		// Mise à jour de la position de chaque utilisateur restant dans la file d'attente
-		for index, user := range queue[concertID] {
-			updatedMessage := Message{Status: "in_queue", Position: index + 1, ConcertID: concertID, IsFirstMessage: false}
-			updatedMessageBytes, _ := json.Marshal(updatedMessage)
-			if err := user.Conn.WriteMessage(websocket.TextMessage, updatedMessageBytes); err != nil {
-				fmt.Printf("Erreur lors de la mise à jour de la position pour l'utilisateur %s : %v\n", user.UserID, err)
+// admitFromQueue fait entrer les utilisateurs en attente d'un concert dont la vente est ouverte,
+// dans la limite de la salle. Si la vente est fermée ou le concert annulé, les utilisateurs en
+// attente reçoivent "sales_closed" et la file est vidée. L'état de la vente est lu sans
+// verrouiller queueMutex.
+func admitFromQueue(concertID string) {
+	queueMutex.Lock()
+	waiting := len(queue[concertID])
+	queueMutex.Unlock()
+	if waiting == 0 {
+		return
+	}
+
+	opensAt, err := queueOpening(concertID)
+	if err != nil {
+		if errors.Is(err, errSalesNotOpen) || errors.Is(err, errSalesClosed) || errors.Is(err, errConcertCancelled) {
+			queueMutex.Lock()
+			for _, user := range queue[concertID] {
+				if err := user.send(Message{Status: "sales_closed", ConcertID: concertID, IsFirstMessage: false}); err != nil {
+					fmt.Printf("Erreur d'écriture WebSocket : %v\n", err)
+				}
 			}
+			delete(queue, concertID)
+			queueMutex.Unlock()
+			fmt.Printf("Vente fermée, file d'attente vidée pour le concert %s\n", concertID)
 		}
+		return
+	}
+	if opensAt != nil {
+		return
+	}
+
+	queueMutex.Lock()
+	promoted := promoteFromQueue(concertID)
+	if len(promoted) > 0 {
+		notifyQueuePositions(concertID, nil)
+	}
+	queueMutex.Unlock()
+
+	grantAccess(concertID, promoted)
+}
+
+// admitOpenQueues applique admitFromQueue à toutes les files d'attente en cours
+func admitOpenQueues() {
+	queueMutex.Lock()
+	concertIDs := make([]string, 0, len(queue))
+	for concertID := range queue {
+		concertIDs = append(concertIDs, concertID)
+	}
+	queueMutex.Unlock()
+
+	for _, concertID := range concertIDs {
+		admitFromQueue(concertID)
 	}
 }
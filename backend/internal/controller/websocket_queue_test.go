@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPromoteFromQueue(t *testing.T) {
+	maxUsers := getMaxUsers()
+	tests := []struct {
+		name         string
+		inRoom       int
+		waiting      int
+		wantPromoted int
+	}{
+		{"room full", maxUsers, 3, 0},
+		{"more waiting than free places", maxUsers - 2, 5, 2},
+		{"fewer waiting than free places", maxUsers - 10, 3, 3},
+		{"empty queue", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			concertID := fmt.Sprintf("concert-%s", tt.name)
+			t.Cleanup(func() {
+				delete(queue, concertID)
+				delete(authorized, concertID)
+			})
+			for i := 0; i < tt.inRoom; i++ {
+				authorized[concertID] = append(authorized[concertID], &UserConnection{UserID: fmt.Sprintf("room-%d", i)})
+			}
+			for i := 0; i < tt.waiting; i++ {
+				queue[concertID] = append(queue[concertID], &UserConnection{UserID: fmt.Sprintf("queue-%d", i)})
+			}
+
+			promoted := promoteFromQueue(concertID)
+			if len(promoted) != tt.wantPromoted {
+				t.Fatalf("promoted %d users, want %d", len(promoted), tt.wantPromoted)
+			}
+			// Les utilisateurs entrent dans l'ordre d'arrivée
+			for i, user := range promoted {
+				if want := fmt.Sprintf("queue-%d", i); user.UserID != want {
+					t.Errorf("promoted[%d] = %s, want %s", i, user.UserID, want)
+				}
+			}
+			if got, want := len(authorized[concertID]), tt.inRoom+tt.wantPromoted; got != want {
+				t.Errorf("%d users in the room, want %d", got, want)
+			}
+			if got, want := len(queue[concertID]), tt.waiting-tt.wantPromoted; got != want {
+				t.Errorf("%d users still waiting, want %d", got, want)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"time"
 	_ "weezemaster/internal/config"
 	"weezemaster/internal/models"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -27,7 +32,8 @@ func InitDB() {
 	dbname := os.Getenv("POSTGRES_DB")
 	password := os.Getenv("POSTGRES_PASSWORD")
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Europe/Paris", host, user, password, dbname, port)
+	// Les dates sont échangées en UTC ; chaque concert est saisi et affiché au fuseau de sa salle
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC", host, user, password, dbname, port)
 
 	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
@@ -39,11 +45,383 @@ func GetDB() *gorm.DB {
 	return db
 }
 
+// legacyPriceColumns liste les anciennes colonnes de prix en float (unités majeures) et le
+// préfixe des colonnes Money qui les remplacent
+var legacyPriceColumns = []struct {
+	Model  interface{}
+	Column string
+	Prefix string
+}{
+	{&models.ConcertCategory{}, "price", "price_"},
+	{&models.TicketListing{}, "price", "price_"},
+	{&models.Conversation{}, "price", "price_"},
+	{&models.Sale{}, "final_price", "final_price_"},
+	{&models.Ticket{}, "max_price", "max_price_"},
+	{&models.Order{}, "total_amount", "total_"},
+	{&models.OrderItem{}, "unit_price", "unit_price_"},
+}
+
+// renameLegacyPriceColumns met de côté les colonnes de prix en float avant l'AutoMigrate,
+// certaines portant le même nom que les nouvelles colonnes (orders.total_amount)
+func renameLegacyPriceColumns() error {
+	migrator := db.Migrator()
+	for _, legacy := range legacyPriceColumns {
+		if !migrator.HasColumn(legacy.Model, legacy.Column) || migrator.HasColumn(legacy.Model, legacy.Prefix+"currency") {
+			continue
+		}
+		if err := migrator.RenameColumn(legacy.Model, legacy.Column, legacy.Column+"_legacy"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyPrices convertit les anciens prix en centimes dans la devise par défaut
+// puis supprime les colonnes float. Les étapes sont idempotentes.
+func migrateLegacyPrices() error {
+	for _, legacy := range legacyPriceColumns {
+		column := legacy.Column + "_legacy"
+		if !db.Migrator().HasColumn(legacy.Model, column) {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(legacy.Model).UpdateColumns(map[string]interface{}{
+				legacy.Prefix + "amount":   gorm.Expr("ROUND(" + column + " * 100)"),
+				legacy.Prefix + "currency": models.DefaultCurrency,
+			}).Error
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(legacy.Model, column)
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Migrated legacy prices from column %s", column)
+	}
+
+	// Les paiements enregistraient la devise en minuscules, comme Stripe
+	for _, model := range []interface{}{&models.Payment{}, &models.RefundRequest{}} {
+		if err := db.Model(model).Where("currency <> UPPER(currency)").Update("currency", gorm.Expr("UPPER(currency)")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureActiveListingIndex garantit qu'un ticket n'a qu'une annonce ouverte à la fois. Les
+// doublons hérités de l'ancienne vérification applicative sont retirés, sauf le plus récent.
+func ensureActiveListingIndex() error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TicketListing{}).
+			Where("status IN ?", models.ActiveListingStatuses).
+			Where("id NOT IN (?)", tx.Raw("SELECT DISTINCT ON (ticket_id) id FROM ticket_listings WHERE status IN ? ORDER BY ticket_id, created_at DESC", models.ActiveListingStatuses)).
+			Updates(map[string]interface{}{"status": models.ListingWithdrawn, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_ticket_listing_active ON ticket_listings (ticket_id)
+WHERE status IN ('` + models.ListingAvailable + `', '` + models.ListingReserved + `') AND deleted_at IS NULL`).Error
+	})
+}
+
+// searchVectorExpr construit le tsvector d'un texte en français, en anglais et sans racinisation
+// (pour l'autocomplétion), sans accents et avec le poids donné
+func searchVectorExpr(column, weight string) string {
+	text := "immutable_unaccent(coalesce(" + column + ", ''))"
+	var parts []string
+	for _, config := range []string{"french", "english", "simple"} {
+		parts = append(parts, "setweight(to_tsvector('"+config+"', "+text+"), '"+weight+"')")
+	}
+	return strings.Join(parts, " || ")
+}
+
+// ensureSearchIndexes prépare la recherche plein texte : une colonne tsvector générée et indexée
+// sur les concerts, artistes, organisations et centres d'intérêt, et des index trigrammes sur les
+// noms pour la tolérance aux fautes de frappe
+func ensureSearchIndexes() error {
+	concertVector := searchVectorExpr("name", "A") + " || " + searchVectorExpr("location", "B") + " || " + searchVectorExpr("description", "C")
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`CREATE EXTENSION IF NOT EXISTS unaccent`,
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			// unaccent n'est pas IMMUTABLE : cette enveloppe permet de l'utiliser dans les colonnes générées et les index
+			`CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text AS $$
+	SELECT public.unaccent('public.unaccent', $1)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT`,
+			`ALTER TABLE concerts ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (` + concertVector + `) STORED`,
+			`ALTER TABLE artists ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (` + searchVectorExpr("name", "A") + `) STORED`,
+			`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (` + searchVectorExpr("name", "A") + " || " + searchVectorExpr("description", "C") + `) STORED`,
+			`ALTER TABLE interests ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (` + searchVectorExpr("name", "A") + `) STORED`,
+		}
+		for _, table := range []string{"concerts", "artists", "organizations", "interests"} {
+			statements = append(statements,
+				`CREATE INDEX IF NOT EXISTS idx_`+table+`_search_vector ON `+table+` USING GIN (search_vector)`,
+				`CREATE INDEX IF NOT EXISTS idx_`+table+`_name_trgm ON `+table+` USING GIN (immutable_unaccent(lower(name)) gin_trgm_ops)`,
+			)
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// protectTicketOwnership rend l'historique des détenteurs en ajout seul : toute modification
+// ou suppression d'une entrée est refusée par la base
+func protectTicketOwnership() error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`CREATE OR REPLACE FUNCTION ticket_ownerships_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'ticket_ownerships is append-only';
+END;
+$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS ticket_ownerships_append_only ON ticket_ownerships`,
+			`CREATE TRIGGER ticket_ownerships_append_only BEFORE UPDATE OR DELETE ON ticket_ownerships
+FOR EACH ROW EXECUTE FUNCTION ticket_ownerships_append_only()`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ownershipEvent est un changement de détenteur reconstitué à partir des ventes et des transferts
+type ownershipEvent struct {
+	At       time.Time
+	From     uuid.UUID
+	To       uuid.UUID
+	Source   string
+	Price    models.Money
+	SaleID   *uuid.UUID
+	Transfer *uuid.UUID
+}
+
+// backfillTicketOwnership reconstitue l'historique des tickets émis avant son introduction : le
+// détenteur initial, puis les reventes, les dons acceptés et les reventes remboursées
+func backfillTicketOwnership() error {
+	var tickets []models.Ticket
+	result := db.Preload("ConcertCategory").
+		Where("NOT EXISTS (SELECT 1 FROM ticket_ownerships WHERE ticket_ownerships.ticket_id = tickets.id)").
+		FindInBatches(&tickets, 200, func(tx *gorm.DB, batch int) error {
+			for _, ticket := range tickets {
+				if err := backfillTicket(tx, &ticket); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled ownership history for %d ticket(s)", result.RowsAffected)
+	}
+	return nil
+}
+
+func backfillTicket(tx *gorm.DB, ticket *models.Ticket) error {
+	var sales []struct {
+		models.Sale
+		ListingStatus    string
+		ListingUpdatedAt time.Time
+	}
+	if err := tx.Model(&models.Sale{}).
+		Select("sales.*, ticket_listings.status AS listing_status, ticket_listings.updated_at AS listing_updated_at").
+		Joins("JOIN ticket_listings ON ticket_listings.id = sales.ticket_listing_id").
+		Where("ticket_listings.ticket_id = ?", ticket.ID).
+		Scan(&sales).Error; err != nil {
+		return err
+	}
+	var transfers []models.TicketTransfer
+	if err := tx.Where("ticket_id = ? AND status = ?", ticket.ID, models.TicketTransferAccepted).Find(&transfers).Error; err != nil {
+		return err
+	}
+
+	var events []ownershipEvent
+	for _, sale := range sales {
+		saleID := sale.ID
+		events = append(events, ownershipEvent{sale.CreatedAt, sale.SellerId, sale.BuyerId, models.OwnershipResale, sale.FinalPrice, &saleID, nil})
+		if sale.ListingStatus == models.ListingRefunded {
+			events = append(events, ownershipEvent{sale.ListingUpdatedAt, sale.BuyerId, sale.SellerId, models.OwnershipRefund, sale.FinalPrice, &saleID, nil})
+		}
+	}
+	for _, transfer := range transfers {
+		transferID := transfer.ID
+		at := transfer.UpdatedAt
+		if transfer.RespondedAt != nil {
+			at = *transfer.RespondedAt
+		}
+		events = append(events, ownershipEvent{at, transfer.SenderId, transfer.RecipientId, models.OwnershipGift, models.NewMoney(0, ticket.MaxPrice.Currency), nil, &transferID})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+
+	holder := ticket.UserId
+	if len(events) > 0 {
+		holder = events[0].From
+	}
+	price := ticket.ConcertCategory.Price
+	if ticket.OrderItemId != nil {
+		var item models.OrderItem
+		if err := tx.Where("id = ?", ticket.OrderItemId).First(&item).Error; err == nil {
+			price = item.UnitPrice
+		}
+	}
+
+	entries := []models.TicketOwnership{{
+		ID:         uuid.New(),
+		TicketId:   ticket.ID,
+		UserId:     holder,
+		Source:     models.OwnershipPrimary,
+		Price:      price,
+		AcquiredAt: ticket.CreatedAt,
+		CreatedAt:  time.Now(),
+	}}
+	for _, event := range events {
+		from := event.From
+		entries = append(entries, models.TicketOwnership{
+			ID:             uuid.New(),
+			TicketId:       ticket.ID,
+			UserId:         event.To,
+			PreviousUserId: &from,
+			Source:         event.Source,
+			Price:          event.Price,
+			SaleId:         event.SaleID,
+			TransferId:     event.Transfer,
+			AcquiredAt:     event.At,
+			CreatedAt:      time.Now(),
+		})
+	}
+	// Un écart avec le détenteur actuel vient d'une réattribution manuelle non tracée
+	if last := entries[len(entries)-1]; last.UserId != ticket.UserId {
+		entries = append(entries, models.TicketOwnership{
+			ID:             uuid.New(),
+			TicketId:       ticket.ID,
+			UserId:         ticket.UserId,
+			PreviousUserId: &last.UserId,
+			Source:         models.OwnershipAdmin,
+			Price:          models.NewMoney(0, ticket.MaxPrice.Currency),
+			AcquiredAt:     ticket.UpdatedAt,
+			CreatedAt:      time.Now(),
+		})
+	}
+	for i := range entries {
+		if entries[i].Price.Currency == "" {
+			entries[i].Price.Currency = models.DefaultCurrency
+		}
+	}
+	return tx.Create(&entries).Error
+}
+
+// migrateConcertVenues rattache les concerts sans salle à une salle de leur organisation, créée
+// à partir de leur ancien lieu en texte libre. La capacité retenue est la plus grande jauge des
+// concerts donnés à ce lieu ; les coordonnées restent à compléter par l'organisateur.
+func migrateConcertVenues() error {
+	var locations []struct {
+		OrganizationId uuid.UUID
+		Location       string
+		Capacity       int
+	}
+	if err := db.Raw("SELECT organization_id, location, MAX(capacity) AS capacity FROM (" +
+		"SELECT concerts.organization_id, TRIM(concerts.location) AS location, " +
+		"COALESCE(SUM(concert_categories.available_tickets), 0) AS capacity " +
+		"FROM concerts LEFT JOIN concert_categories ON concert_categories.concert_id = concerts.id " +
+		"WHERE concerts.venue_id IS NULL AND TRIM(concerts.location) <> '' " +
+		"GROUP BY concerts.id) AS concert_capacities GROUP BY organization_id, location").
+		Scan(&locations).Error; err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var venue models.Venue
+			err := tx.Where("organization_id = ? AND name = ?", location.OrganizationId, location.Location).First(&venue).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				venue = models.Venue{
+					ID:             uuid.New(),
+					OrganizationId: location.OrganizationId,
+					Name:           location.Location,
+					Address:        location.Location,
+					TimeZone:       models.DefaultVenueTimeZone,
+					Capacity:       location.Capacity,
+					CreatedAt:      time.Now(),
+					UpdatedAt:      time.Now(),
+				}
+				err = tx.Create(&venue).Error
+			}
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.Concert{}).
+				Where("venue_id IS NULL AND organization_id = ? AND TRIM(location) = ?", location.OrganizationId, location.Location).
+				Update("venue_id", venue.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(locations) > 0 {
+		log.Printf("Attached concerts to %d venue(s) from their former location", len(locations))
+	}
+	return nil
+}
+
+// Statut unique des concerts créés avant leur cycle de vie
+const legacyConcertScheduled = "scheduled"
+
+// markLegacyConcertStatuses s'exécute avant l'AutoMigrate, tant que le cycle de vie n'est pas en
+// place (pas de colonne sales_open_at). Sans cela, les concerts existants recevraient le nouveau
+// défaut draft et disparaîtraient du catalogue : la colonne status est créée avec l'ancien défaut
+// si elle manque, et les statuts vides passent à scheduled.
+func markLegacyConcertStatuses() error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Concert{}) || migrator.HasColumn(&models.Concert{}, "sales_open_at") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(&models.Concert{}, "status") {
+			return tx.Exec("ALTER TABLE concerts ADD COLUMN status text NOT NULL DEFAULT '" + legacyConcertScheduled + "'").Error
+		}
+		return tx.Exec("UPDATE concerts SET status = ? WHERE status IS NULL OR status IN ?",
+			legacyConcertScheduled, []string{"", models.ConcertDraft}).Error
+	})
+}
+
+// migrateConcertStatuses reprend les concerts antérieurs au cycle de vie : ils étaient en vente
+// dès leur création, ils passent donc en vente, ou terminés s'ils ont eu lieu
+func migrateConcertStatuses() error {
+	if err := db.Model(&models.Concert{}).Where("status = ? AND date < ?", legacyConcertScheduled, time.Now()).
+		Update("status", models.ConcertCompleted).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Concert{}).Where("status = ?", legacyConcertScheduled).
+		Update("status", models.ConcertOnSale).Error
+}
+
 func Migrate() {
+	if err := renameLegacyPriceColumns(); err != nil {
+		log.Fatalf("Error renaming legacy price columns: %v", err)
+	}
+
+	if err := markLegacyConcertStatuses(); err != nil {
+		log.Fatalf("Error marking legacy concert statuses: %v", err)
+	}
+
 	err := db.AutoMigrate(
 		&models.ConcertCategory{},
 		&models.User{},
 		&models.Organization{},
+		&models.Venue{},
+		&models.SeatMap{},
+		&models.SeatSection{},
+		&models.SeatRow{},
+		&models.Seat{},
+		&models.ConcertSeat{},
 		&models.Concert{},
 		&models.Category{},
 		&models.Interest{},
@@ -51,10 +429,57 @@ func Migrate() {
 		&models.TicketListing{},
 		&models.Sale{},
 		&models.Conversation{},
+		&models.Offer{},
+		&models.TicketTransfer{},
+		&models.TicketOwnership{},
+		&models.WaitlistEntry{},
+		&models.WaitlistAlert{},
+		&models.Rating{},
+		&models.FraudReview{},
 		&models.Message{},
 		&models.Artist{},
+		&models.TicketHold{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.Payment{},
+		&models.RefundRequest{},
+		&models.ConcertCancellation{},
+		&models.CheckIn{},
+		&models.CheckInBundle{},
+		&models.Escrow{},
+		&models.LedgerAccount{},
+		&models.LedgerTransaction{},
+		&models.LedgerEntry{},
 	)
 	if err != nil {
 		log.Fatalf("Error migrating database schema: %v", err)
 	}
+
+	if err := migrateLegacyPrices(); err != nil {
+		log.Fatalf("Error migrating legacy prices: %v", err)
+	}
+
+	if err := migrateConcertVenues(); err != nil {
+		log.Fatalf("Error attaching concerts to venues: %v", err)
+	}
+
+	if err := migrateConcertStatuses(); err != nil {
+		log.Fatalf("Error migrating concert statuses: %v", err)
+	}
+
+	if err := ensureActiveListingIndex(); err != nil {
+		log.Fatalf("Error creating the active ticket listing index: %v", err)
+	}
+
+	if err := ensureSearchIndexes(); err != nil {
+		log.Fatalf("Error creating the search indexes: %v", err)
+	}
+
+	if err := protectTicketOwnership(); err != nil {
+		log.Fatalf("Error protecting ticket ownership history: %v", err)
+	}
+
+	if err := backfillTicketOwnership(); err != nil {
+		log.Fatalf("Error backfilling ticket ownership history: %v", err)
+	}
 }
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+	"time"
+	"weezemaster/internal/models"
+	"weezemaster/internal/testdb"
+
+	"github.com/google/uuid"
+)
+
+const legacyConcertsTable = `CREATE TABLE concerts (
+	id uuid PRIMARY KEY,
+	name text NOT NULL,
+	description text NOT NULL,
+	location text NOT NULL,
+	date timestamptz NOT NULL,
+	image text,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	organization_id uuid NOT NULL,
+	artist_id uuid NOT NULL
+)`
+
+func TestConcertStatusMigration(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []string
+	}{
+		{"status column missing", []string{legacyConcertsTable}},
+		{"status column with scheduled default", []string{
+			legacyConcertsTable,
+			"ALTER TABLE concerts ADD COLUMN status text NOT NULL DEFAULT 'scheduled'",
+		}},
+		{"status column with empty values", []string{
+			legacyConcertsTable,
+			"ALTER TABLE concerts ADD COLUMN status text",
+			"ALTER TABLE concerts ALTER COLUMN status SET DEFAULT ''",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := testdb.Open(t)
+			previous := db
+			db = testDB
+			t.Cleanup(func() { db = previous })
+
+			for _, statement := range tt.setup {
+				if err := db.Exec(statement).Error; err != nil {
+					t.Fatalf("setup: %v", err)
+				}
+			}
+			past, upcoming := uuid.New(), uuid.New()
+			for id, date := range map[uuid.UUID]time.Time{
+				past:     time.Now().AddDate(0, -1, 0),
+				upcoming: time.Now().AddDate(0, 1, 0),
+			} {
+				if err := db.Exec("INSERT INTO concerts (id, name, description, location, date, organization_id, artist_id) VALUES (?, 'Concert', '', 'Paris', ?, ?, ?)",
+					id, date, uuid.New(), uuid.New()).Error; err != nil {
+					t.Fatalf("insert legacy concert: %v", err)
+				}
+			}
+
+			migrate := func() {
+				t.Helper()
+				if err := markLegacyConcertStatuses(); err != nil {
+					t.Fatalf("mark legacy statuses: %v", err)
+				}
+				if err := db.AutoMigrate(&models.Concert{}); err != nil {
+					t.Fatalf("auto migrate: %v", err)
+				}
+				if err := migrateConcertStatuses(); err != nil {
+					t.Fatalf("migrate statuses: %v", err)
+				}
+			}
+			migrate()
+
+			want := map[uuid.UUID]string{past: models.ConcertCompleted, upcoming: models.ConcertOnSale}
+			for id, status := range want {
+				var concert models.Concert
+				if err := db.Select("id", "status").Where("id = ?", id).First(&concert).Error; err != nil {
+					t.Fatalf("load concert: %v", err)
+				}
+				if concert.Status != status {
+					t.Errorf("concert status = %q, want %q", concert.Status, status)
+				}
+			}
+
+			// Après la migration, un nouveau concert est un brouillon et le reste si elle est rejouée
+			draft := uuid.New()
+			if err := db.Exec("INSERT INTO concerts (id, name, description, location, date, organization_id, artist_id) VALUES (?, 'Brouillon', '', 'Paris', ?, ?, ?)",
+				draft, time.Now().AddDate(0, 2, 0), uuid.New(), uuid.New()).Error; err != nil {
+				t.Fatalf("insert draft concert: %v", err)
+			}
+			migrate()
+
+			var concert models.Concert
+			if err := db.Select("id", "status").Where("id = ?", draft).First(&concert).Error; err != nil {
+				t.Fatalf("load concert: %v", err)
+			}
+			if concert.Status != models.ConcertDraft {
+				t.Errorf("new concert status = %q, want %q", concert.Status, models.ConcertDraft)
+			}
+		})
+	}
+}
@@ -31,13 +31,22 @@ func LoadConcertFixtures() {
 		return
 	}
 
+	var venue models.Venue
+	db.Where("organization_id = ? AND name = ?", organization.ID, "Paris La Défense Arena").First(&venue)
+	if venue.ID == uuid.Nil {
+		log.Println("Venue not found")
+		return
+	}
+
 	if organization.ID != uuid.Nil {
 		concert := models.Concert{
 			ID:             uuid.New(),
 			Name:           "Eras Tour",
 			Description:    "The Eras Tour is the fifth concert tour by American singer-songwriter Taylor Swift, in support of her ninth studio album, Eras.",
 			Date:           time.Now().AddDate(0, 1, 0),
-			Location:       "Paris La Défense Arena",
+			Location:       venue.Name,
+			VenueId:        &venue.ID,
+			Status:         models.ConcertOnSale,
 			Organization:   &organization,
 			OrganizationId: organization.ID,
 			Artist:         &artist,
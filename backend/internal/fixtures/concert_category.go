@@ -21,12 +21,12 @@ func LoadConcertCategoryFixtures() {
 		categoryAssociations := map[int]struct {
 			AvailableTickets int
 			SoldTickets      int
-			Price            float64
+			Price            models.Money
 		}{
-			1: {AvailableTickets: 200, SoldTickets: 0, Price: 70.0},
-			3: {AvailableTickets: 170, SoldTickets: 0, Price: 100.0},
-			4: {AvailableTickets: 120, SoldTickets: 0, Price: 170.0},
-			5: {AvailableTickets: 80, SoldTickets: 0, Price: 250.0},
+			1: {AvailableTickets: 200, SoldTickets: 0, Price: models.NewMoney(7000, models.DefaultCurrency)},
+			3: {AvailableTickets: 170, SoldTickets: 0, Price: models.NewMoney(10000, models.DefaultCurrency)},
+			4: {AvailableTickets: 120, SoldTickets: 0, Price: models.NewMoney(17000, models.DefaultCurrency)},
+			5: {AvailableTickets: 80, SoldTickets: 0, Price: models.NewMoney(25000, models.DefaultCurrency)},
 		}
 
 		for categoryID, details := range categoryAssociations {
@@ -42,10 +42,25 @@ func LoadTicketFixtures() {
 		UserId:            user.ID,
 		ConcertCategoryId: concertCategory.ID,
 		MaxPrice:          concertCategory.Price,
+		Status:            models.TicketValid,
 	}
 
 	result := db.Create(&ticket)
 	if result.Error != nil {
 		log.Println("Error creating ticket:", result.Error)
+		return
+	}
+
+	ownership := models.TicketOwnership{
+		ID:         uuid.New(),
+		TicketId:   ticket.ID,
+		UserId:     user.ID,
+		Source:     models.OwnershipPrimary,
+		Price:      concertCategory.Price,
+		AcquiredAt: ticket.CreatedAt,
+		CreatedAt:  time.Now(),
+	}
+	if err := db.Create(&ownership).Error; err != nil {
+		log.Println("Error creating ticket ownership:", err)
 	}
 }
@@ -0,0 +1,39 @@
+package fixtures
+
+import (
+	"log"
+	"weezemaster/internal/database"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func LoadVenueFixtures() {
+	db := database.GetDB()
+
+	var organization models.Organization
+	db.Where("name = ?", "Weezevent").First(&organization)
+	if organization.ID == uuid.Nil {
+		log.Println("Organization not found")
+		return
+	}
+
+	latitude, longitude := 48.8957, 2.2290
+	venue := models.Venue{
+		ID:             uuid.New(),
+		OrganizationId: organization.ID,
+		Name:           "Paris La Défense Arena",
+		Address:        "99 Jardins de l'Arche",
+		City:           "Nanterre",
+		PostalCode:     "92000",
+		Country:        "FR",
+		Latitude:       &latitude,
+		Longitude:      &longitude,
+		TimeZone:       "Europe/Paris",
+		Capacity:       40000,
+	}
+	result := db.Create(&venue)
+	if result.Error != nil {
+		log.Println("Error creating venue:", result.Error)
+	}
+}
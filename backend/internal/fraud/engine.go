@@ -0,0 +1,104 @@
+package fraud
+
+import (
+	"time"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attempt décrit l'action évaluée : une réservation de places, la mise en vente d'un ticket ou
+// l'achat d'une annonce
+type Attempt struct {
+	Checkpoint        string
+	UserID            uuid.UUID
+	ConcertID         uuid.UUID
+	ConcertCategoryID uuid.UUID
+	// Ticket mis en vente ou acheté en revente
+	TicketID uuid.UUID
+	// Annonce achetée en revente
+	ListingID uuid.UUID
+	Quantity  int
+	At        time.Time
+}
+
+// SubjectID renvoie l'objet de l'action : la catégorie réservée, le ticket mis en vente ou
+// l'annonce achetée
+func (a Attempt) SubjectID() uuid.UUID {
+	switch a.Checkpoint {
+	case models.FraudCheckpointListing:
+		return a.TicketID
+	case models.FraudCheckpointResalePurchase:
+		return a.ListingID
+	}
+	return a.ConcertCategoryID
+}
+
+// Verdict est l'issue d'une règle, ou du moteur pour l'ensemble des règles
+type Verdict struct {
+	Outcome string
+	Rule    string
+	Reason  string
+}
+
+var Allow = Verdict{Outcome: models.FraudAllow}
+
+var severity = map[string]int{
+	models.FraudAllow: 0,
+	models.FraudHold:  1,
+	models.FraudBlock: 2,
+}
+
+// Rule est une règle anti-fraude. Une règle n'est évaluée qu'aux points de contrôle qu'elle
+// déclare et renvoie Allow quand elle n'a rien à signaler.
+type Rule interface {
+	Name() string
+	Checkpoints() []string
+	Evaluate(db *gorm.DB, attempt Attempt) (Verdict, error)
+}
+
+// Engine évalue une action contre un ensemble de règles
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+func appliesTo(rule Rule, checkpoint string) bool {
+	for _, c := range rule.Checkpoints() {
+		if c == checkpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate renvoie le verdict le plus sévère des règles applicables ; à sévérité égale, celui
+// de la première règle déclarée
+func (e *Engine) Evaluate(db *gorm.DB, attempt Attempt) (Verdict, error) {
+	if attempt.At.IsZero() {
+		attempt.At = time.Now()
+	}
+	if attempt.Quantity == 0 {
+		attempt.Quantity = 1
+	}
+
+	result := Allow
+	for _, rule := range e.rules {
+		if !appliesTo(rule, attempt.Checkpoint) {
+			continue
+		}
+		verdict, err := rule.Evaluate(db, attempt)
+		if err != nil {
+			return Verdict{}, err
+		}
+		if severity[verdict.Outcome] > severity[result.Outcome] {
+			verdict.Rule = rule.Name()
+			result = verdict
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"weezemaster/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var purchaseCheckpoints = []string{models.FraudCheckpointReservation, models.FraudCheckpointResalePurchase}
+
+// MaxTicketsPerConcert bloque un achat qui porterait le nombre de tickets valides de
+// l'utilisateur pour un concert au-delà de Limit
+type MaxTicketsPerConcert struct {
+	Limit int
+}
+
+func (r MaxTicketsPerConcert) Name() string          { return "max_tickets_per_concert" }
+func (r MaxTicketsPerConcert) Checkpoints() []string { return purchaseCheckpoints }
+
+func (r MaxTicketsPerConcert) Evaluate(db *gorm.DB, attempt Attempt) (Verdict, error) {
+	var owned int64
+	if err := db.Model(&models.Ticket{}).
+		Joins("JOIN concert_categories ON concert_categories.id = tickets.concert_category_id").
+		Where("tickets.user_id = ? AND concert_categories.concert_id = ? AND tickets.status = ?", attempt.UserID, attempt.ConcertID, models.TicketValid).
+		Count(&owned).Error; err != nil {
+		return Verdict{}, err
+	}
+	if int(owned)+attempt.Quantity > r.Limit {
+		return Verdict{Outcome: models.FraudBlock, Reason: fmt.Sprintf("at most %d tickets per user for this concert", r.Limit)}, nil
+	}
+	return Allow, nil
+}
+
+// PurchaseVelocity retient pour examen un achat quand l'utilisateur a déjà démarré Limit
+// paiements ou plus sur la période Window
+type PurchaseVelocity struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (r PurchaseVelocity) Name() string          { return "purchase_velocity" }
+func (r PurchaseVelocity) Checkpoints() []string { return purchaseCheckpoints }
+
+func (r PurchaseVelocity) Evaluate(db *gorm.DB, attempt Attempt) (Verdict, error) {
+	var recent int64
+	if err := db.Model(&models.Payment{}).
+		Where("user_id = ? AND created_at >= ?", attempt.UserID, attempt.At.Add(-r.Window)).
+		Count(&recent).Error; err != nil {
+		return Verdict{}, err
+	}
+	if int(recent) >= r.Limit {
+		return Verdict{Outcome: models.FraudHold, Reason: fmt.Sprintf("%d purchases started in the last %d minutes", recent, int(r.Window.Minutes()))}, nil
+	}
+	return Allow, nil
+}
+
+// NewAccount retient pour examen un achat fait par un compte créé moins de MinAge auparavant,
+// comme ceux ouverts quelques minutes avant une mise en vente
+type NewAccount struct {
+	MinAge time.Duration
+}
+
+func (r NewAccount) Name() string          { return "new_account" }
+func (r NewAccount) Checkpoints() []string { return purchaseCheckpoints }
+
+func (r NewAccount) Evaluate(db *gorm.DB, attempt Attempt) (Verdict, error) {
+	var user models.User
+	if err := db.Select("id", "created_at").Where("id = ?", attempt.UserID).First(&user).Error; err != nil {
+		return Verdict{}, err
+	}
+	if attempt.At.Sub(user.CreatedAt) < r.MinAge {
+		return Verdict{Outcome: models.FraudHold, Reason: fmt.Sprintf("account created less than %d minutes ago", int(r.MinAge.Minutes()))}, nil
+	}
+	return Allow, nil
+}
+
+// MinHoldBeforeResale bloque la remise en vente d'un ticket que son détenteur a lui-même acheté
+// en revente il y a moins de MinHold
+type MinHoldBeforeResale struct {
+	MinHold time.Duration
+}
+
+func (r MinHoldBeforeResale) Name() string { return "min_hold_before_resale" }
+func (r MinHoldBeforeResale) Checkpoints() []string {
+	return []string{models.FraudCheckpointListing}
+}
+
+func (r MinHoldBeforeResale) Evaluate(db *gorm.DB, attempt Attempt) (Verdict, error) {
+	var ownership models.TicketOwnership
+	err := db.Where("ticket_id = ?", attempt.TicketID).Order("acquired_at DESC, created_at DESC").First(&ownership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Allow, nil
+	}
+	if err != nil {
+		return Verdict{}, err
+	}
+	if ownership.UserId != attempt.UserID || ownership.Source != models.OwnershipResale {
+		return Allow, nil
+	}
+	if attempt.At.Sub(ownership.AcquiredAt) < r.MinHold {
+		return Verdict{Outcome: models.FraudBlock, Reason: "ticket was bought on resale too recently to be listed again"}, nil
+	}
+	return Allow, nil
+}
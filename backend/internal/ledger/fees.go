@@ -0,0 +1,100 @@
+package ledger
+
+import (
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// FeeRules fixe les commissions en points de base (100 = 1 %) prélevées sur le prix payé
+type FeeRules struct {
+	// Commission de la plateforme sur les ventes de l'organisateur
+	PlatformPrimaryBps int64
+	// Commission de la plateforme sur les reventes
+	PlatformResaleBps int64
+	// Part de l'organisateur sur les reventes de ses concerts
+	OrganizerResaleBps int64
+}
+
+// Fee calcule une commission, arrondie au centime le plus proche
+func Fee(amount models.Money, bps int64) models.Money {
+	fee := (amount.Amount*bps + 5000) / 10000
+	if amount.Amount < 0 {
+		fee = -((-amount.Amount*bps + 5000) / 10000)
+	}
+	return models.NewMoney(fee, amount.Currency)
+}
+
+// ResaleSplit répartit le prix d'une revente entre la plateforme, l'organisateur et le vendeur
+func ResaleSplit(amount models.Money, platformBps, organizerBps int64) (platformFee, organizerFee, sellerNet models.Money) {
+	platformFee = Fee(amount, platformBps)
+	organizerFee = Fee(amount, organizerBps)
+	sellerNet = models.NewMoney(amount.Amount-platformFee.Amount-organizerFee.Amount, amount.Currency)
+	return platformFee, organizerFee, sellerNet
+}
+
+// PrimarySaleLines enregistre l'encaissement d'une vente de l'organisateur : l'acheteur paie la
+// plateforme, qui doit le prix à l'organisateur après sa commission
+func PrimarySaleLines(buyerID, organizationID uuid.UUID, amount models.Money, platformBps int64) []Line {
+	fee := Fee(amount, platformBps)
+	return []Line{
+		Debit(models.LedgerAccountPlatformCash, uuid.Nil, amount),
+		Credit(models.LedgerAccountBuyer, buyerID, amount),
+		Debit(models.LedgerAccountBuyer, buyerID, amount),
+		Credit(models.LedgerAccountPlatformFees, uuid.Nil, fee),
+		Credit(models.LedgerAccountOrganization, organizationID, models.NewMoney(amount.Amount-fee.Amount, amount.Currency)),
+	}
+}
+
+// ResaleLines enregistre l'encaissement d'une revente. La part du vendeur est portée au compte
+// sellerAccount : LedgerAccountSellerEscrow tant que les fonds sont sous séquestre.
+func ResaleLines(buyerID, sellerID, organizationID uuid.UUID, amount models.Money, platformBps, organizerBps int64, sellerAccount string) []Line {
+	return resaleLines(buyerID, organizationID, amount, platformBps, organizerBps, sellerAccount, sellerID)
+}
+
+// ResaleRefundLines contrepasse une revente remboursée. La part du vendeur est reprise sur le
+// compte sellerAccount : son séquestre, son solde si le séquestre a été libéré, ou le compte de
+// l'acheteur (LedgerAccountBuyer) si l'annulation du séquestre la lui a déjà rendue.
+func ResaleRefundLines(buyerID, sellerID, organizationID uuid.UUID, amount models.Money, platformBps, organizerBps int64, sellerAccount string) []Line {
+	ownerID := sellerID
+	if sellerAccount == models.LedgerAccountBuyer {
+		ownerID = buyerID
+	}
+	return Reverse(resaleLines(buyerID, organizationID, amount, platformBps, organizerBps, sellerAccount, ownerID))
+}
+
+func resaleLines(buyerID, organizationID uuid.UUID, amount models.Money, platformBps, organizerBps int64, sellerAccount string, sellerOwnerID uuid.UUID) []Line {
+	platformFee, organizerFee, sellerNet := ResaleSplit(amount, platformBps, organizerBps)
+	return []Line{
+		Debit(models.LedgerAccountPlatformCash, uuid.Nil, amount),
+		Credit(models.LedgerAccountBuyer, buyerID, amount),
+		Debit(models.LedgerAccountBuyer, buyerID, amount),
+		Credit(models.LedgerAccountPlatformFees, uuid.Nil, platformFee),
+		Credit(models.LedgerAccountOrganization, organizationID, organizerFee),
+		Credit(sellerAccount, sellerOwnerID, sellerNet),
+	}
+}
+
+// EscrowReleaseLines rend disponible au vendeur le montant d'un séquestre libéré
+func EscrowReleaseLines(sellerID uuid.UUID, amount models.Money) []Line {
+	return []Line{
+		Debit(models.LedgerAccountSellerEscrow, sellerID, amount),
+		Credit(models.LedgerAccountSeller, sellerID, amount),
+	}
+}
+
+// EscrowReversalLines rend à l'acheteur la part du vendeur retenue par un séquestre annulé
+func EscrowReversalLines(sellerID, buyerID uuid.UUID, amount models.Money) []Line {
+	return []Line{
+		Debit(models.LedgerAccountSellerEscrow, sellerID, amount),
+		Credit(models.LedgerAccountBuyer, buyerID, amount),
+	}
+}
+
+// PayoutLines enregistre le versement au propriétaire du compte d'une partie de son solde
+func PayoutLines(accountType string, ownerID uuid.UUID, amount models.Money) []Line {
+	return []Line{
+		Debit(accountType, ownerID, amount),
+		Credit(models.LedgerAccountPlatformCash, uuid.Nil, amount),
+	}
+}
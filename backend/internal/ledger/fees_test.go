@@ -0,0 +1,115 @@
+package ledger
+
+import (
+	"testing"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestFee(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		bps    int64
+		want   int64
+	}{
+		{"no commission", 5000, 0, 0},
+		{"whole percent", 5000, 500, 250},
+		{"full amount", 5000, 10000, 5000},
+		{"rounds half a cent up", 1050, 500, 53},
+		{"rounds below half a cent down", 1049, 500, 52},
+		{"one basis point of a small amount", 99, 1, 0},
+		{"negative amount rounds symmetrically", -1050, 500, -53},
+		{"zero amount", 0, 750, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fee(models.NewMoney(tt.amount, "EUR"), tt.bps)
+			if got != models.NewMoney(tt.want, "EUR") {
+				t.Errorf("Fee(%d, %d) = %+v, want %d EUR", tt.amount, tt.bps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResaleSplit(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		amount, platformBps, organizerBps    int64
+		wantPlatform, wantOrganizer, wantNet int64
+	}{
+		{"no commission", 10000, 0, 0, 0, 0, 10000},
+		{"platform and organizer", 10000, 500, 300, 500, 300, 9200},
+		{"rounded shares", 3333, 750, 250, 250, 83, 3000},
+		{"everything to commissions", 100, 6000, 4000, 60, 40, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platform, organizer, net := ResaleSplit(models.NewMoney(tt.amount, "USD"), tt.platformBps, tt.organizerBps)
+			if platform.Amount != tt.wantPlatform || organizer.Amount != tt.wantOrganizer || net.Amount != tt.wantNet {
+				t.Errorf("ResaleSplit() = %d, %d, %d, want %d, %d, %d",
+					platform.Amount, organizer.Amount, net.Amount, tt.wantPlatform, tt.wantOrganizer, tt.wantNet)
+			}
+			// Les trois parts couvrent exactement le prix payé, dans sa devise
+			if platform.Amount+organizer.Amount+net.Amount != tt.amount {
+				t.Errorf("shares add up to %d, want %d", platform.Amount+organizer.Amount+net.Amount, tt.amount)
+			}
+			for _, share := range []models.Money{platform, organizer, net} {
+				if share.Currency != "USD" {
+					t.Errorf("share currency = %q, want USD", share.Currency)
+				}
+			}
+		})
+	}
+}
+
+// Annuler le séquestre puis rembourser la revente ramène chaque compte à zéro
+func TestEscrowReversalThenRefundClearsAccounts(t *testing.T) {
+	buyer, seller, organization := uuid.New(), uuid.New(), uuid.New()
+	price := models.NewMoney(3333, "EUR")
+	_, _, sellerNet := ResaleSplit(price, 750, 250)
+
+	var lines []Line
+	lines = append(lines, ResaleLines(buyer, seller, organization, price, 750, 250, models.LedgerAccountSellerEscrow)...)
+	lines = append(lines, EscrowReversalLines(seller, buyer, sellerNet)...)
+	lines = append(lines, ResaleRefundLines(buyer, seller, organization, price, 750, 250, models.LedgerAccountBuyer)...)
+
+	balances := make(map[Line]int64)
+	for _, line := range lines {
+		key := Line{AccountType: line.AccountType, OwnerId: line.OwnerId}
+		balances[key] += line.Amount.Amount
+	}
+	for account, balance := range balances {
+		if balance != 0 {
+			t.Errorf("%s account of %s ends at %d, want 0", account.AccountType, account.OwnerId, balance)
+		}
+	}
+}
+
+func TestSaleLinesAreBalanced(t *testing.T) {
+	buyer, seller, organization := uuid.New(), uuid.New(), uuid.New()
+	tests := []struct {
+		name  string
+		lines []Line
+	}{
+		{"primary sale", PrimarySaleLines(buyer, organization, models.NewMoney(3333, "EUR"), 750)},
+		{"resale", ResaleLines(buyer, seller, organization, models.NewMoney(3333, "EUR"), 750, 250, models.LedgerAccountSellerEscrow)},
+		{"resale without commission", ResaleLines(buyer, seller, organization, models.NewMoney(1000, "EUR"), 0, 0, models.LedgerAccountSeller)},
+		{"escrow release", EscrowReleaseLines(seller, models.NewMoney(3000, "EUR"))},
+		{"escrow reversal", EscrowReversalLines(seller, buyer, models.NewMoney(3000, "EUR"))},
+		{"refunded resale after escrow reversal", ResaleRefundLines(buyer, seller, organization, models.NewMoney(3333, "EUR"), 750, 250, models.LedgerAccountBuyer)},
+		{"payout", PayoutLines(models.LedgerAccountOrganization, organization, models.NewMoney(3083, "EUR"))},
+		{"reversed resale", Reverse(ResaleLines(buyer, seller, organization, models.NewMoney(3333, "EUR"), 750, 250, models.LedgerAccountSellerEscrow))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkBalanced(tt.lines); err != nil {
+				t.Errorf("checkBalanced() = %v, want nil", err)
+			}
+		})
+	}
+}
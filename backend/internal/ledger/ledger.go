@@ -0,0 +1,186 @@
+package ledger
+
+import (
+	"errors"
+	"time"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnbalanced = errors.New("ledger transaction is not balanced")
+var ErrEmptyTransaction = errors.New("ledger transaction has no entries")
+
+// Line est une ligne d'écriture à passer sur le compte AccountType de OwnerId.
+// Un montant positif est un débit, un montant négatif un crédit.
+type Line struct {
+	AccountType string
+	OwnerId     uuid.UUID
+	Amount      models.Money
+}
+
+func Debit(accountType string, ownerID uuid.UUID, amount models.Money) Line {
+	return Line{AccountType: accountType, OwnerId: ownerID, Amount: amount}
+}
+
+func Credit(accountType string, ownerID uuid.UUID, amount models.Money) Line {
+	amount.Amount = -amount.Amount
+	return Line{AccountType: accountType, OwnerId: ownerID, Amount: amount}
+}
+
+// Reverse inverse le sens de chaque ligne, pour annuler tout ou partie d'une écriture
+func Reverse(lines []Line) []Line {
+	reversed := make([]Line, 0, len(lines))
+	for _, line := range lines {
+		line.Amount.Amount = -line.Amount.Amount
+		reversed = append(reversed, line)
+	}
+	return reversed
+}
+
+// Posting décrit une transaction à enregistrer
+type Posting struct {
+	Kind            string
+	Reference       string
+	Description     string
+	PlatformFeeBps  int64
+	OrganizerFeeBps int64
+	Lines           []Line
+}
+
+// checkBalanced vérifie que les débits et les crédits s'équilibrent dans chaque devise
+func checkBalanced(lines []Line) error {
+	totals := make(map[string]int64)
+	for _, line := range lines {
+		totals[line.Amount.Currency] += line.Amount.Amount
+	}
+	for _, total := range totals {
+		if total != 0 {
+			return ErrUnbalanced
+		}
+	}
+	return nil
+}
+
+// findOrCreateAccount renvoie le compte demandé, en le créant à sa première utilisation
+func findOrCreateAccount(tx *gorm.DB, accountType string, ownerID uuid.UUID, currency string) (*models.LedgerAccount, error) {
+	created := models.LedgerAccount{
+		ID:        uuid.New(),
+		Type:      accountType,
+		OwnerId:   ownerID,
+		Currency:  currency,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error; err != nil {
+		return nil, err
+	}
+	var account models.LedgerAccount
+	if err := tx.Where("type = ? AND owner_id = ? AND currency = ?", accountType, ownerID, currency).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// Post enregistre une transaction équilibrée. Une transaction déjà enregistrée avec la même
+// nature et la même référence est renvoyée telle quelle, ce qui permet de rejouer un traitement.
+func Post(tx *gorm.DB, posting Posting) (*models.LedgerTransaction, error) {
+	var existing models.LedgerTransaction
+	err := tx.Where("kind = ? AND reference = ?", posting.Kind, posting.Reference).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	lines := make([]Line, 0, len(posting.Lines))
+	for _, line := range posting.Lines {
+		if !line.Amount.IsZero() {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, ErrEmptyTransaction
+	}
+	if err := checkBalanced(lines); err != nil {
+		return nil, err
+	}
+
+	transaction := models.LedgerTransaction{
+		ID:              uuid.New(),
+		Kind:            posting.Kind,
+		Reference:       posting.Reference,
+		Description:     posting.Description,
+		PlatformFeeBps:  posting.PlatformFeeBps,
+		OrganizerFeeBps: posting.OrganizerFeeBps,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		account, err := findOrCreateAccount(tx, line.AccountType, line.OwnerId, line.Amount.Currency)
+		if err != nil {
+			return nil, err
+		}
+		entry := models.LedgerEntry{
+			ID:            uuid.New(),
+			TransactionId: transaction.ID,
+			AccountId:     account.ID,
+			Amount:        line.Amount,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		transaction.Entries = append(transaction.Entries, entry)
+	}
+
+	return &transaction, nil
+}
+
+// FindTransaction renvoie la transaction enregistrée pour une nature et une référence
+func FindTransaction(tx *gorm.DB, kind, reference string) (*models.LedgerTransaction, error) {
+	var transaction models.LedgerTransaction
+	if err := tx.Where("kind = ? AND reference = ?", kind, reference).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// AvailableBalance verrouille un compte jusqu'à la fin de la transaction et renvoie son solde
+// créditeur, c'est-à-dire ce qui est dû à son propriétaire
+func AvailableBalance(tx *gorm.DB, accountType string, ownerID uuid.UUID, currency string) (models.Money, error) {
+	account, err := findOrCreateAccount(tx, accountType, ownerID, currency)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", account.ID).First(account).Error; err != nil {
+		return models.Money{}, err
+	}
+	balance, err := Balance(tx, accountType, ownerID, currency)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return models.NewMoney(-balance.Amount, currency), nil
+}
+
+// Balance renvoie le solde d'un compte, positif s'il est débiteur
+func Balance(tx *gorm.DB, accountType string, ownerID uuid.UUID, currency string) (models.Money, error) {
+	var total int64
+	err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(ledger_entries.amount), 0)").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Where("ledger_accounts.type = ? AND ledger_accounts.owner_id = ? AND ledger_accounts.currency = ?", accountType, ownerID, currency).
+		Scan(&total).Error
+	if err != nil {
+		return models.Money{}, err
+	}
+	return models.NewMoney(total, currency), nil
+}
@@ -0,0 +1,134 @@
+package ledger
+
+import (
+	"sort"
+	"time"
+	"weezemaster/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccountTypeBalance est le solde cumulé des comptes d'un type dans une devise
+type AccountTypeBalance struct {
+	Type     string `json:"type"`
+	Currency string `json:"currency"`
+	Balance  int64  `json:"balance"`
+}
+
+// CashCheck compare, pour une devise, l'argent encaissé d'après les paiements et d'après le grand livre
+type CashCheck struct {
+	Currency string `json:"currency"`
+	// Paiements honorés, nets des remboursements
+	Payments int64 `json:"payments"`
+	// Solde du compte de trésorerie augmenté des versements effectués
+	Ledger     int64 `json:"ledger"`
+	Difference int64 `json:"difference"`
+}
+
+// Report est le rapport de rapprochement. Le total des écritures doit être nul dans chaque devise.
+type Report struct {
+	GeneratedAt            time.Time            `json:"generatedAt"`
+	Balanced               bool                 `json:"balanced"`
+	Totals                 []models.Money       `json:"totals"`
+	UnbalancedTransactions []uuid.UUID          `json:"unbalancedTransactions"`
+	Accounts               []AccountTypeBalance `json:"accounts"`
+	Cash                   []CashCheck          `json:"cash"`
+}
+
+type currencyTotal struct {
+	Currency string
+	Amount   int64
+}
+
+// Reconcile construit le rapport de rapprochement du grand livre
+func Reconcile(db *gorm.DB) (*Report, error) {
+	report := &Report{
+		GeneratedAt:            time.Now(),
+		Balanced:               true,
+		Totals:                 []models.Money{},
+		UnbalancedTransactions: []uuid.UUID{},
+		Accounts:               []AccountTypeBalance{},
+		Cash:                   []CashCheck{},
+	}
+
+	var totals []currencyTotal
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("currency, SUM(amount) AS amount").
+		Group("currency").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	for _, total := range totals {
+		report.Totals = append(report.Totals, models.NewMoney(total.Amount, total.Currency))
+		if total.Amount != 0 {
+			report.Balanced = false
+		}
+	}
+
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("transaction_id").
+		Group("transaction_id, currency").
+		Having("SUM(amount) <> 0").
+		Pluck("transaction_id", &report.UnbalancedTransactions).Error; err != nil {
+		return nil, err
+	}
+	if len(report.UnbalancedTransactions) > 0 {
+		report.Balanced = false
+	}
+
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("ledger_accounts.type AS type, ledger_entries.currency AS currency, SUM(ledger_entries.amount) AS balance").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Group("ledger_accounts.type, ledger_entries.currency").
+		Order("ledger_accounts.type").
+		Scan(&report.Accounts).Error; err != nil {
+		return nil, err
+	}
+
+	var collected []currencyTotal
+	if err := db.Model(&models.Payment{}).
+		Select("currency, SUM(amount - refunded_amount) AS amount").
+		Where("status IN ?", []string{models.PaymentSucceeded, models.PaymentRefunded}).
+		Group("currency").
+		Scan(&collected).Error; err != nil {
+		return nil, err
+	}
+
+	var payouts []currencyTotal
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("ledger_entries.currency AS currency, -SUM(ledger_entries.amount) AS amount").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Where("ledger_transactions.kind = ? AND ledger_accounts.type = ?", models.LedgerPayout, models.LedgerAccountPlatformCash).
+		Group("ledger_entries.currency").
+		Scan(&payouts).Error; err != nil {
+		return nil, err
+	}
+
+	cash := make(map[string]*CashCheck)
+	check := func(currency string) *CashCheck {
+		if cash[currency] == nil {
+			cash[currency] = &CashCheck{Currency: currency}
+		}
+		return cash[currency]
+	}
+	for _, total := range collected {
+		check(total.Currency).Payments += total.Amount
+	}
+	for _, balance := range report.Accounts {
+		if balance.Type == models.LedgerAccountPlatformCash {
+			check(balance.Currency).Ledger += balance.Balance
+		}
+	}
+	for _, total := range payouts {
+		check(total.Currency).Ledger += total.Amount
+	}
+	for _, c := range cash {
+		c.Difference = c.Payments - c.Ledger
+		report.Cash = append(report.Cash, *c)
+	}
+	sort.Slice(report.Cash, func(i, j int) bool { return report.Cash[i].Currency < report.Cash[j].Currency })
+
+	return report, nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CheckInOnline  = "online"
+	CheckInOffline = "offline"
+)
+
+// CheckIn enregistre l'entrée d'un ticket dans la salle. Un ticket ne peut être scanné
+// qu'une seule fois : l'index unique sur TicketId rejette les doublons concurrents.
+// Quand un ticket est scanné sur plusieurs appareils, le scan le plus ancien est conservé
+// et DuplicateScans compte les scans refusés.
+type CheckIn struct {
+	// gorm.Model
+	ID             uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	TicketId       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	Ticket         Ticket    `gorm:"foreignKey:TicketId"`
+	ConcertId      uuid.UUID `gorm:"type:uuid;not null;index"`
+	UserId         uuid.UUID `gorm:"type:uuid;not null"`
+	ScannedById    uuid.UUID `gorm:"type:uuid;not null"`
+	ScannedBy      User      `gorm:"foreignKey:ScannedById"`
+	ScannedAt      time.Time `gorm:"not null"`
+	DeviceId       string
+	Source         string `gorm:"not null;default:online"`
+	DuplicateScans int    `gorm:"not null;default:0"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time `gorm:"index"`
+}
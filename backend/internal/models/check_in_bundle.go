@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CheckInBundle trace chaque export hors ligne des tickets d'un concert. Version est
+// incrémentée à chaque export pour que les appareils sachent quel bundle est le plus récent.
+type CheckInBundle struct {
+	// gorm.Model
+	ID            uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	ConcertId     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_check_in_bundle_version"`
+	Concert       Concert   `gorm:"foreignKey:ConcertId"`
+	Version       int       `gorm:"not null;uniqueIndex:idx_check_in_bundle_version"`
+	GeneratedById uuid.UUID `gorm:"type:uuid;not null"`
+	TicketCount   int       `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time `gorm:"index"`
+}
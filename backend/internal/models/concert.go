@@ -6,6 +6,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// Cycle de vie d'un concert : un brouillon n'est visible que de son organisation, un concert
+// publié est annoncé sans être en vente, et seuls on_sale et sold_out permettent d'acheter
+const (
+	ConcertDraft     = "draft"
+	ConcertPublished = "published"
+	ConcertOnSale    = "on_sale"
+	ConcertSoldOut   = "sold_out"
+	ConcertPostponed = "postponed"
+	ConcertCancelled = "cancelled"
+	ConcertCompleted = "completed"
+)
+
 type Concert struct {
 	// gorm.Model
 	ID                uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
@@ -23,4 +35,19 @@ type Concert struct {
 	ConcertCategories []ConcertCategory `gorm:"foreignKey:ConcertId"`
 	ArtistId          uuid.UUID         `gorm:"not null"`
 	Artist            *Artist           `gorm:"not null;foreignKey:ArtistId"`
+	// Politique de remboursement : les demandes des utilisateurs sont refusées
+	// moins de RefundCutoffHours heures avant la date du concert
+	RefundsEnabled    bool   `gorm:"not null;default:true"`
+	RefundCutoffHours int    `gorm:"not null;default:48"`
+	Status            string `gorm:"not null;default:draft;index"`
+	CancelledAt       *time.Time
+	// Salle du concert ; Location en reprend le nom pour l'affichage
+	VenueId *uuid.UUID `gorm:"type:uuid;index"`
+	Venue   *Venue     `gorm:"foreignKey:VenueId"`
+	// Plan de salle des concerts en placement numéroté
+	SeatMapId *uuid.UUID `gorm:"type:uuid;index"`
+	SeatMap   *SeatMap   `gorm:"foreignKey:SeatMapId"`
+	// Période de vente ; la vente ferme au plus tard au début du concert
+	SalesOpenAt  *time.Time
+	SalesCloseAt *time.Time
 }
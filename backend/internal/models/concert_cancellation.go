@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ConcertCancellationRunning   = "running"
+	ConcertCancellationCompleted = "completed"
+	ConcertCancellationFailed    = "failed"
+)
+
+// ConcertCancellation suit le remboursement de tous les tickets d'un concert annulé.
+// Le job peut être relancé après une interruption : chaque ticket est remboursé par une
+// RefundRequest liée au job, qui n'est jamais remboursée deux fois.
+type ConcertCancellation struct {
+	// gorm.Model
+	ID              uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	ConcertId       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	Concert         Concert   `gorm:"foreignKey:ConcertId"`
+	RequestedById   uuid.UUID `gorm:"type:uuid;not null"`
+	RequestedBy     User      `gorm:"foreignKey:RequestedById"`
+	Reason          string
+	Status          string `gorm:"not null;index"`
+	TotalTickets    int    `gorm:"not null"`
+	RefundedTickets int    `gorm:"not null"`
+	FailedTickets   int    `gorm:"not null"`
+	LastError       string
+	StartedAt       time.Time
+	CompletedAt     *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       *time.Time `gorm:"index"`
+}
@@ -13,7 +13,7 @@ type ConcertCategory struct {
 	CategoryId       int       `gorm:"not null;index"`
 	AvailableTickets int       `gorm:"not null"`
 	SoldTickets      int
-	Price            float64 `gorm:"not null"`
+	Price            Money `gorm:"embedded;embeddedPrefix:price_"`
 	Tickets          []Ticket
 	// Tickets          []Ticket `gorm:"-"`
 	Concert   Concert  `gorm:"foreignKey:ConcertId"`
@@ -21,4 +21,7 @@ type ConcertCategory struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt *time.Time `gorm:"index"`
+	// Période de vente propre à la catégorie, à défaut celle du concert
+	SalesOpenAt  *time.Time
+	SalesCloseAt *time.Time
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EscrowHeld     = "held"
+	EscrowReleased = "released"
+	EscrowReversed = "reversed"
+)
+
+// Motifs de libération et d'annulation d'un séquestre
+const (
+	EscrowReleaseConcertDate      = "concert_date"
+	EscrowReleaseCheckIn          = "check_in"
+	EscrowReverseConcertCancelled = "concert_cancelled"
+	EscrowReverseRefund           = "refund"
+	EscrowReverseTicketInvalid    = "ticket_invalid"
+)
+
+// Escrow retient la part du vendeur dans une revente, commissions déduites, jusqu'à ce que le
+// concert ait eu lieu ou que le ticket ait été scanné à l'entrée. Le montant libéré est dû au
+// vendeur ; un séquestre annulé revient à l'acheteur.
+type Escrow struct {
+	// gorm.Model
+	ID            uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	SaleId        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	Sale          Sale      `gorm:"foreignKey:SaleId"`
+	TicketId      uuid.UUID `gorm:"type:uuid;not null;index"`
+	ConcertId     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Concert       Concert   `gorm:"foreignKey:ConcertId"`
+	SellerId      uuid.UUID `gorm:"type:uuid;not null;index"`
+	BuyerId       uuid.UUID `gorm:"type:uuid;not null"`
+	Amount        Money     `gorm:"embedded"`
+	Status        string    `gorm:"not null;index"`
+	ReleaseAfter  time.Time `gorm:"not null;index"`
+	ReleasedAt    *time.Time
+	ReleaseReason string
+	ReversedAt    *time.Time
+	ReverseReason string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time `gorm:"index"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Points de contrôle où le moteur anti-fraude est évalué
+const (
+	FraudCheckpointReservation    = "reservation"
+	FraudCheckpointListing        = "listing"
+	FraudCheckpointResalePurchase = "resale_purchase"
+)
+
+// Issues possibles d'une évaluation, de la moins à la plus sévère
+const (
+	FraudAllow = "allow"
+	FraudHold  = "hold"
+	FraudBlock = "block"
+)
+
+const (
+	FraudReviewPending  = "pending"
+	FraudReviewApproved = "approved"
+	FraudReviewRejected = "rejected"
+	FraudReviewBlocked  = "blocked"
+)
+
+// FraudReview conserve une action retenue ou bloquée par le moteur anti-fraude. Une action
+// retenue attend la décision d'un administrateur (pending) ; une action bloquée est archivée
+// directement (blocked). SubjectId est la catégorie réservée, le ticket mis en vente ou
+// l'annonce achetée selon le point de contrôle.
+type FraudReview struct {
+	// gorm.Model
+	ID           uuid.UUID  `gorm:"unique;type:uuid;primaryKey"`
+	UserId       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	User         User       `gorm:"foreignKey:UserId"`
+	Checkpoint   string     `gorm:"not null"`
+	SubjectId    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ConcertId    uuid.UUID  `gorm:"type:uuid;not null"`
+	Quantity     int        `gorm:"not null;default:1"`
+	Outcome      string     `gorm:"not null"`
+	Rule         string     `gorm:"not null"`
+	Reason       string     `gorm:"not null"`
+	Status       string     `gorm:"not null;index"`
+	ReviewedById *uuid.UUID `gorm:"type:uuid"`
+	ReviewedAt   *time.Time
+	ReviewNote   string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time `gorm:"index"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types de comptes du grand livre. Les comptes de la plateforme ont pour propriétaire uuid.Nil.
+const (
+	LedgerAccountPlatformCash = "platform_cash"
+	LedgerAccountPlatformFees = "platform_fees"
+	LedgerAccountOrganization = "organization"
+	LedgerAccountSeller       = "seller"
+	LedgerAccountSellerEscrow = "seller_escrow"
+	LedgerAccountBuyer        = "buyer"
+)
+
+// Natures des transactions comptables
+const (
+	LedgerPrimarySale    = "primary_sale"
+	LedgerResale         = "resale"
+	LedgerEscrowRelease  = "escrow_release"
+	LedgerEscrowReversal = "escrow_reversal"
+	LedgerRefund         = "refund"
+	LedgerPayout         = "payout"
+)
+
+// LedgerAccount est un compte du grand livre, tenu dans une seule devise
+type LedgerAccount struct {
+	// gorm.Model
+	ID        uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	Type      string    `gorm:"not null;uniqueIndex:idx_ledger_account"`
+	OwnerId   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_ledger_account"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_ledger_account"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// LedgerTransaction regroupe des écritures équilibrées. Le couple Kind/Reference (l'ID du
+// paiement, de la demande de remboursement, du séquestre...) rend chaque écriture idempotente.
+// Les commissions appliquées sont conservées pour rembourser au même taux.
+type LedgerTransaction struct {
+	// gorm.Model
+	ID              uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	Kind            string    `gorm:"not null;uniqueIndex:idx_ledger_transaction_reference"`
+	Reference       string    `gorm:"not null;uniqueIndex:idx_ledger_transaction_reference"`
+	Description     string
+	PlatformFeeBps  int64         `gorm:"not null;default:0"`
+	OrganizerFeeBps int64         `gorm:"not null;default:0"`
+	Entries         []LedgerEntry `gorm:"foreignKey:TransactionId"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       *time.Time `gorm:"index"`
+}
+
+// LedgerEntry est une ligne d'écriture : un montant positif est un débit, un montant négatif un crédit
+type LedgerEntry struct {
+	// gorm.Model
+	ID            uuid.UUID     `gorm:"unique;type:uuid;primaryKey"`
+	TransactionId uuid.UUID     `gorm:"type:uuid;not null;index"`
+	AccountId     uuid.UUID     `gorm:"type:uuid;not null;index"`
+	Account       LedgerAccount `gorm:"foreignKey:AccountId"`
+	Amount        Money         `gorm:"embedded"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time `gorm:"index"`
+}
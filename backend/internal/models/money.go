@@ -0,0 +1,120 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Devise des prix saisis sans devise explicite
+const DefaultCurrency = "EUR"
+
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+var ErrInvalidCurrency = errors.New("invalid currency code")
+
+// Money est un montant en unités mineures (centimes) associé à un code devise ISO 4217.
+// Toutes les devises acceptées ont deux décimales. En base, un champ Money est embarqué
+// (gorm:"embedded;embeddedPrefix:price_") et occupe deux colonnes : price_amount et price_currency.
+type Money struct {
+	Amount   int64  `gorm:"not null;default:0" json:"amount"`
+	Currency string `gorm:"type:varchar(3);not null;default:EUR" json:"currency"`
+}
+
+// NewMoney construit un montant à partir d'unités mineures
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// MoneyFromMajor convertit un montant en unités majeures (12.5 €) en unités mineures (1250),
+// arrondi au centime le plus proche
+func MoneyFromMajor(value float64, currency string) Money {
+	amount := decimal.NewFromFloat(value).Shift(2).Round(0).IntPart()
+	return NewMoney(amount, currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Validate vérifie que le code devise a la forme d'un code ISO 4217
+func (m Money) Validate() error {
+	if len(m.Currency) != 3 {
+		return ErrInvalidCurrency
+	}
+	for _, r := range m.Currency {
+		if r < 'A' || r > 'Z' {
+			return ErrInvalidCurrency
+		}
+	}
+	return nil
+}
+
+// Cmp renvoie -1, 0 ou 1 selon que m est inférieur, égal ou supérieur à other.
+// Deux montants de devises différentes ne sont pas comparables.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// String formate le montant pour l'affichage (notifications, emails), par exemple "12.50 EUR"
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", decimal.New(m.Amount, -2).StringFixed(2), m.Currency)
+}
+
+// UnmarshalJSON accepte l'objet {"amount": 1250, "currency": "EUR"} ou, pour les clients qui
+// envoient encore un prix en unités majeures, un simple nombre (12.5) dans la devise par défaut
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] != '{' {
+		var value float64
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*m = MoneyFromMajor(value, DefaultCurrency)
+		return nil
+	}
+
+	var raw struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Currency == "" {
+		raw.Currency = DefaultCurrency
+	}
+	*m = NewMoney(raw.Amount, raw.Currency)
+	return m.Validate()
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMoneyFromMajor(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		currency string
+		want     Money
+	}{
+		{"whole amount", 12, "EUR", Money{Amount: 1200, Currency: "EUR"}},
+		{"two decimals", 12.5, "EUR", Money{Amount: 1250, Currency: "EUR"}},
+		{"binary rounding error", 0.29, "EUR", Money{Amount: 29, Currency: "EUR"}},
+		{"half cent rounds up", 10.005, "EUR", Money{Amount: 1001, Currency: "EUR"}},
+		{"below half a cent rounds down", 10.004, "EUR", Money{Amount: 1000, Currency: "EUR"}},
+		{"negative amount", -3.2, "EUR", Money{Amount: -320, Currency: "EUR"}},
+		{"zero", 0, "EUR", Money{Amount: 0, Currency: "EUR"}},
+		{"lower case currency", 1, "usd", Money{Amount: 100, Currency: "USD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MoneyFromMajor(tt.value, tt.currency); got != tt.want {
+				t.Errorf("MoneyFromMajor(%v, %q) = %+v, want %+v", tt.value, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyValidate(t *testing.T) {
+	tests := []struct {
+		currency string
+		wantErr  bool
+	}{
+		{"EUR", false},
+		{"USD", false},
+		{"", true},
+		{"EU", true},
+		{"EURO", true},
+		{"eur", true},
+		{"E1R", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			err := Money{Amount: 100, Currency: tt.currency}.Validate()
+			if tt.wantErr && !errors.Is(err, ErrInvalidCurrency) {
+				t.Errorf("Validate() = %v, want ErrInvalidCurrency", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	eur := func(amount int64) Money { return NewMoney(amount, "EUR") }
+	usd := NewMoney(100, "USD")
+
+	cmpTests := []struct {
+		name    string
+		a, b    Money
+		want    int
+		wantErr error
+	}{
+		{"lower", eur(100), eur(200), -1, nil},
+		{"equal", eur(200), eur(200), 0, nil},
+		{"greater", eur(300), eur(200), 1, nil},
+		{"currency mismatch", eur(100), usd, 0, ErrCurrencyMismatch},
+	}
+	for _, tt := range cmpTests {
+		t.Run("Cmp "+tt.name, func(t *testing.T) {
+			got, err := tt.a.Cmp(tt.b)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Cmp() = %d, %v, want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	addTests := []struct {
+		name    string
+		a, b    Money
+		want    Money
+		wantErr error
+	}{
+		{"same currency", eur(1250), eur(-250), eur(1000), nil},
+		{"currency mismatch", eur(100), usd, Money{}, ErrCurrencyMismatch},
+	}
+	for _, tt := range addTests {
+		t.Run("Add "+tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Add() = %+v, %v, want %+v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if got := eur(1250).Mul(3); got != eur(3750) {
+		t.Errorf("Mul() = %+v, want %+v", got, eur(3750))
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{NewMoney(1250, "EUR"), "12.50 EUR"},
+		{NewMoney(5, "EUR"), "0.05 EUR"},
+		{NewMoney(0, "USD"), "0.00 USD"},
+		{NewMoney(-1999, "EUR"), "-19.99 EUR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.money.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{"object", `{"amount": 1250, "currency": "EUR"}`, NewMoney(1250, "EUR"), false},
+		{"lower case currency", `{"amount": 100, "currency": "usd"}`, NewMoney(100, "USD"), false},
+		{"object without currency", `{"amount": 100}`, NewMoney(100, DefaultCurrency), false},
+		{"legacy major units", `12.5`, NewMoney(1250, DefaultCurrency), false},
+		{"legacy rounding", `19.999`, NewMoney(2000, DefaultCurrency), false},
+		{"null keeps zero value", `null`, Money{}, false},
+		{"invalid currency", `{"amount": 100, "currency": "EURO"}`, Money{}, true},
+		{"string amount", `"12.5"`, Money{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Unmarshal(%s) = %+v, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s) = %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
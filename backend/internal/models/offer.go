@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OfferPending   = "pending"
+	OfferAccepted  = "accepted"
+	OfferRejected  = "rejected"
+	OfferCountered = "countered"
+	OfferWithdrawn = "withdrawn"
+	OfferExpired   = "expired"
+)
+
+// Offer est une proposition de prix faite dans une conversation de revente. Une seule offre
+// peut être en attente à la fois et seule l'autre partie peut y répondre : l'accepter, la
+// refuser ou faire une contre-offre. L'auteur d'une offre en attente peut la remplacer.
+type Offer struct {
+	// gorm.Model
+	ID             uuid.UUID    `gorm:"unique;type:uuid;primaryKey"`
+	ConversationId uuid.UUID    `gorm:"type:uuid;not null;index"`
+	Conversation   Conversation `gorm:"foreignKey:ConversationId"`
+	ProposerId     uuid.UUID    `gorm:"type:uuid;not null"`
+	Proposer       User         `gorm:"foreignKey:ProposerId"`
+	Price          Money        `gorm:"embedded;embeddedPrefix:price_"`
+	Status         string       `gorm:"not null;index"`
+	CounterOfId    *uuid.UUID   `gorm:"type:uuid"`
+	ExpiresAt      time.Time    `gorm:"not null"`
+	RespondedAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time `gorm:"index"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OrderPending   = "pending"
+	OrderCompleted = "completed"
+	OrderCancelled = "cancelled"
+)
+
+// Order regroupe plusieurs catégories de concert achetées en un seul paiement
+type Order struct {
+	// gorm.Model
+	ID          uuid.UUID   `gorm:"unique;type:uuid;primaryKey"`
+	UserId      uuid.UUID   `gorm:"type:uuid;not null;index"`
+	User        User        `gorm:"foreignKey:UserId"`
+	Status      string      `gorm:"not null;index"`
+	TotalAmount Money       `gorm:"embedded;embeddedPrefix:total_"`
+	Items       []OrderItem `gorm:"foreignKey:OrderId"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time `gorm:"index"`
+}
+
+type OrderItem struct {
+	// gorm.Model
+	ID                uuid.UUID       `gorm:"unique;type:uuid;primaryKey"`
+	OrderId           uuid.UUID       `gorm:"type:uuid;not null;index"`
+	ConcertCategoryId uuid.UUID       `gorm:"type:uuid;not null;index"`
+	ConcertCategory   ConcertCategory `gorm:"foreignKey:ConcertCategoryId"`
+	Quantity          int             `gorm:"not null"`
+	UnitPrice         Money           `gorm:"embedded;embeddedPrefix:unit_price_"`
+	Tickets           []Ticket        `gorm:"foreignKey:OrderItemId"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time `gorm:"index"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PaymentPending           = "pending"
+	PaymentSucceeded         = "succeeded"
+	PaymentFailed            = "failed"
+	PaymentRefunded          = "refunded"
+	PaymentFulfillmentFailed = "fulfillment_failed"
+)
+
+// Types d'éléments payables, repris des préfixes d'ID de CreatePaymentIntent
+const (
+	PaymentItemConcertCategory = "cc"
+	PaymentItemTicketListing   = "tl"
+	PaymentItemConversation    = "cv"
+	PaymentItemOrder           = "or"
+)
+
+// Payment enregistre chaque payment intent créé auprès du prestataire de paiement
+// et le relie à l'élément acheté, puis au ticket, à la vente ou à la commande émis
+// une fois le paiement confirmé
+type Payment struct {
+	// gorm.Model
+	ID                uuid.UUID  `gorm:"unique;type:uuid;primaryKey"`
+	Provider          string     `gorm:"not null;default:stripe"`
+	ProviderPaymentId string     `gorm:"unique;not null"`
+	Status            string     `gorm:"not null;index"`
+	Amount            Money      `gorm:"embedded"`
+	RefundedAmount    int64      `gorm:"not null;default:0"`
+	UserId            uuid.UUID  `gorm:"type:uuid;not null;index"`
+	User              User       `gorm:"foreignKey:UserId"`
+	ItemType          string     `gorm:"not null"`
+	ItemId            uuid.UUID  `gorm:"type:uuid;not null;index"`
+	TicketId          *uuid.UUID `gorm:"type:uuid"`
+	Ticket            *Ticket    `gorm:"foreignKey:TicketId"`
+	SaleId            *uuid.UUID `gorm:"type:uuid"`
+	Sale              *Sale      `gorm:"foreignKey:SaleId"`
+	OrderId           *uuid.UUID `gorm:"type:uuid"`
+	Order             *Order     `gorm:"foreignKey:OrderId"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time `gorm:"index"`
+}
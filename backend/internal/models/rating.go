@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RatingAsBuyer  = "buyer"
+	RatingAsSeller = "seller"
+)
+
+const (
+	RatingMinScore = 1
+	RatingMaxScore = 5
+)
+
+// Rating est la note laissée par une partie d'une revente à l'autre, avec un court avis.
+// Chaque partie note une seule fois par vente ; RateeRole est le rôle de la personne notée.
+type Rating struct {
+	// gorm.Model
+	ID        uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	SaleId    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_rating_sale_rater"`
+	Sale      Sale      `gorm:"foreignKey:SaleId" json:"-"`
+	RaterId   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_rating_sale_rater"`
+	Rater     User      `gorm:"foreignKey:RaterId"`
+	RateeId   uuid.UUID `gorm:"type:uuid;not null;index"`
+	RateeRole string    `gorm:"not null"`
+	Score     int       `gorm:"not null"`
+	Comment   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// ReputationScore est la moyenne des notes reçues et leur nombre
+type ReputationScore struct {
+	Average float64 `json:"average"`
+	Count   int64   `json:"count"`
+}
+
+// Reputation agrège les notes reçues par un utilisateur, en tout et par rôle. Elle est calculée
+// à la lecture et n'est pas stockée.
+type Reputation struct {
+	ReputationScore
+	AsSeller ReputationScore `json:"asSeller"`
+	AsBuyer  ReputationScore `json:"asBuyer"`
+}
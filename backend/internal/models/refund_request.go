@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RefundRequestPending   = "pending"
+	RefundRequestApproved  = "approved"
+	RefundRequestRejected  = "rejected"
+	RefundRequestCompleted = "completed"
+	RefundRequestFailed    = "failed"
+)
+
+// RefundRequest est une demande de remboursement d'un ticket, ouverte par son détenteur
+// ou par un administrateur, puis approuvée ou refusée par un administrateur.
+// Le remboursement d'un ticket acheté en revente annule la vente (SaleId).
+type RefundRequest struct {
+	// gorm.Model
+	ID               uuid.UUID  `gorm:"unique;type:uuid;primaryKey"`
+	TicketId         uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Ticket           Ticket     `gorm:"foreignKey:TicketId"`
+	RequesterId      uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Requester        User       `gorm:"foreignKey:RequesterId"`
+	Reason           string     `gorm:"not null"`
+	Status           string     `gorm:"not null;index"`
+	Amount           Money      `gorm:"embedded"`
+	PaymentId        *uuid.UUID `gorm:"type:uuid"`
+	Payment          *Payment   `gorm:"foreignKey:PaymentId"`
+	SaleId           *uuid.UUID `gorm:"type:uuid"`
+	Sale             *Sale      `gorm:"foreignKey:SaleId"`
+	ReviewerId       *uuid.UUID `gorm:"type:uuid"`
+	ReviewNote       string
+	ReviewedAt       *time.Time
+	ProviderRefundId string
+	CancellationId   *uuid.UUID `gorm:"type:uuid;index"`
+	CompletedAt      *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        *time.Time `gorm:"index"`
+}
@@ -9,7 +9,7 @@ import (
 type Sale struct {
 	// gorm.Model
 	ID              uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
-	FinalPrice      float64   `gorm:"not null"`
+	FinalPrice      Money     `gorm:"embedded;embeddedPrefix:final_price_"`
 	TicketListingId uuid.UUID
 	TicketSold      TicketListing `gorm:"foreignKey:TicketListingId"`
 	BuyerId         uuid.UUID
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// États d'une place numérotée pour un concert, calculés à partir du hold et du ticket
+const (
+	SeatAvailable = "available"
+	SeatHeld      = "held"
+	SeatSold      = "sold"
+)
+
+// SeatMap est le plan d'une salle en placement numéroté : des sections, découpées en rangs de
+// places. Width et Height donnent la taille du plan dans laquelle s'inscrivent les coordonnées
+// des places.
+type SeatMap struct {
+	// gorm.Model
+	ID        uuid.UUID     `gorm:"unique;type:uuid;primaryKey"`
+	VenueId   uuid.UUID     `gorm:"type:uuid;not null;index"`
+	Venue     *Venue        `gorm:"foreignKey:VenueId"`
+	Name      string        `gorm:"not null"`
+	Width     float64       `gorm:"not null;default:0"`
+	Height    float64       `gorm:"not null;default:0"`
+	Sections  []SeatSection `gorm:"foreignKey:SeatMapId"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+type SeatSection struct {
+	// gorm.Model
+	ID        uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	SeatMapId uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name      string    `gorm:"not null"`
+	Position  int       `gorm:"not null;default:0"`
+	Rows      []SeatRow `gorm:"foreignKey:SectionId"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+type SeatRow struct {
+	// gorm.Model
+	ID        uuid.UUID    `gorm:"unique;type:uuid;primaryKey"`
+	SectionId uuid.UUID    `gorm:"type:uuid;not null;index"`
+	Section   *SeatSection `gorm:"foreignKey:SectionId" json:",omitempty"`
+	Label     string       `gorm:"not null"`
+	Position  int          `gorm:"not null;default:0"`
+	Seats     []Seat       `gorm:"foreignKey:RowId"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// Seat est une place physique du plan, positionnée par X et Y pour l'affichage
+type Seat struct {
+	// gorm.Model
+	ID        uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	RowId     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Row       *SeatRow  `gorm:"foreignKey:RowId" json:",omitempty"`
+	Label     string    `gorm:"not null"`
+	X         float64   `gorm:"not null;default:0"`
+	Y         float64   `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// ConcertSeat met en vente une place du plan pour un concert, au tarif d'une de ses catégories.
+// La place est bloquée tant que HoldId désigne un hold actif et non expiré, et vendue dès que
+// TicketId est renseigné.
+type ConcertSeat struct {
+	// gorm.Model
+	ID                uuid.UUID        `gorm:"unique;type:uuid;primaryKey"`
+	ConcertId         uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_concert_seat"`
+	SeatId            uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_concert_seat"`
+	Seat              *Seat            `gorm:"foreignKey:SeatId"`
+	ConcertCategoryId uuid.UUID        `gorm:"type:uuid;not null;index"`
+	ConcertCategory   *ConcertCategory `gorm:"foreignKey:ConcertCategoryId"`
+	HoldId            *uuid.UUID       `gorm:"type:uuid;index"`
+	TicketId          *uuid.UUID       `gorm:"type:uuid;uniqueIndex"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
@@ -6,6 +6,11 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	TicketValid    = "valid"
+	TicketRefunded = "refunded"
+)
+
 type Ticket struct {
 	// gorm.Model
 	ID                uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
@@ -15,7 +20,14 @@ type Ticket struct {
 	UserId            uuid.UUID
 	User              User `gorm:"foreignKey:UserId"`
 	ConcertCategoryId uuid.UUID
-	ConcertCategory   ConcertCategory `gorm:"foreignKey:ConcertCategoryId"`
-	TicketListings     *[]TicketListing  `gorm:"foreignKey:TicketId"`
-	MaxPrice         float64   `gorm:"not null"`
+	ConcertCategory   ConcertCategory  `gorm:"foreignKey:ConcertCategoryId"`
+	TicketListings    *[]TicketListing `gorm:"foreignKey:TicketId"`
+	MaxPrice          Money            `gorm:"embedded;embeddedPrefix:max_price_"`
+	OrderItemId       *uuid.UUID       `gorm:"type:uuid;index"`
+	Status            string           `gorm:"not null;default:valid"`
+	CheckIn           *CheckIn         `gorm:"foreignKey:TicketId"`
+	// Place attribuée, pour les catégories en placement numéroté. Un ticket remboursé la
+	// conserve alors qu'elle est remise en vente.
+	ConcertSeatId *uuid.UUID   `gorm:"type:uuid;index"`
+	ConcertSeat   *ConcertSeat `gorm:"foreignKey:ConcertSeatId"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TicketHoldActive    = "active"
+	TicketHoldReleased  = "released"
+	TicketHoldExpired   = "expired"
+	TicketHoldConverted = "converted"
+)
+
+// TicketHold réserve temporairement l'accès à un concert (sortie de la file
+// d'attente) puis des places dans une catégorie pendant le paiement.
+// Un utilisateur a au plus un hold actif par catégorie, pour qu'une commande
+// puisse porter sur plusieurs catégories. Tant qu'un hold est actif et non
+// expiré, ses places sont décomptées des places disponibles de la catégorie.
+type TicketHold struct {
+	// gorm.Model
+	ID                uuid.UUID        `gorm:"unique;type:uuid;primaryKey"`
+	UserId            uuid.UUID        `gorm:"type:uuid;not null;index"`
+	User              User             `gorm:"foreignKey:UserId"`
+	ConcertId         uuid.UUID        `gorm:"type:uuid;not null;index"`
+	Concert           Concert          `gorm:"foreignKey:ConcertId"`
+	ConcertCategoryId *uuid.UUID       `gorm:"type:uuid;index"`
+	ConcertCategory   *ConcertCategory `gorm:"foreignKey:ConcertCategoryId"`
+	Quantity          int              `gorm:"not null;default:0"`
+	Status            string           `gorm:"not null;index"`
+	ExpiresAt         time.Time        `gorm:"not null;index"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time `gorm:"index"`
+}
@@ -6,6 +6,22 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	ListingAvailable = "available"
+	ListingReserved  = "reserved"
+	ListingSold      = "sold"
+	ListingWithdrawn = "withdrawn"
+	ListingExpired   = "expired"
+	ListingRefunded  = "refunded"
+)
+
+// ActiveListingStatuses sont les statuts d'une annonce encore ouverte. Un index unique partiel
+// garantit qu'un ticket n'a jamais plus d'une annonce dans l'un de ces statuts.
+var ActiveListingStatuses = []string{ListingAvailable, ListingReserved}
+
+// TicketListing est une annonce de revente. Elle est réservée pendant le paiement d'un acheteur
+// (ReservedById jusqu'à ReservedUntil), puis vendue, retirée par le vendeur ou expirée avant le
+// concert. Une annonce vendue passe en refunded si la revente est remboursée.
 type TicketListing struct {
 	// gorm.Model
 	// ID            uuid.UUID       `gorm:"unique;type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
@@ -15,14 +31,18 @@ type TicketListing struct {
 	// UpdatedAt     time.Time       `json:"updatedAt"`
 	// DeletedAt     *time.Time      `gorm:"index"`
 	// TicketId      uuid.UUID       `gorm:"not null" json:"ticketId"`
-	ID            uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
-	Price         float64   `gorm:"not null"`
-	Status        string    `gorm:"not null"`
+	ID            uuid.UUID  `gorm:"unique;type:uuid;primaryKey"`
+	Price         Money      `gorm:"embedded;embeddedPrefix:price_"`
+	Status        string     `gorm:"not null;index"`
+	ReservedById  *uuid.UUID `gorm:"type:uuid"`
+	ReservedUntil *time.Time
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	DeletedAt     *time.Time      `gorm:"index"`
-	TicketId      uuid.UUID       `gorm:"not null;"`
+	TicketId      uuid.UUID       `gorm:"not null;index"`
 	Ticket        Ticket          `gorm:"foreignKey:TicketId"`
 	Conversations *[]Conversation `gorm:"foreignKey:TicketListingId"`
 	Sale          *Sale           `gorm:"foreignKey:TicketListingId"`
+	// Réputation du vendeur, renseignée dans les réponses publiques
+	SellerReputation *Reputation `gorm:"-" json:",omitempty"`
 }
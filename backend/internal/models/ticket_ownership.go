@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Origines d'un changement de détenteur
+const (
+	OwnershipPrimary = "primary"
+	OwnershipResale  = "resale"
+	OwnershipGift    = "gift"
+	OwnershipAdmin   = "admin"
+	OwnershipRefund  = "refund"
+)
+
+// TicketOwnership est une entrée de l'historique des détenteurs d'un ticket : achat initial,
+// revente, don, réattribution par un administrateur ou retour au vendeur après le remboursement
+// d'une revente. L'historique est en ajout seul : une entrée n'est jamais modifiée ni supprimée,
+// ce que garantit un trigger en base.
+type TicketOwnership struct {
+	// gorm.Model
+	ID             uuid.UUID  `gorm:"unique;type:uuid;primaryKey"`
+	TicketId       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	UserId         uuid.UUID  `gorm:"type:uuid;not null;index"`
+	User           User       `gorm:"foreignKey:UserId"`
+	PreviousUserId *uuid.UUID `gorm:"type:uuid"`
+	Source         string     `gorm:"not null;index"`
+	Price          Money      `gorm:"embedded;embeddedPrefix:price_"`
+	SaleId         *uuid.UUID `gorm:"type:uuid;index"`
+	TransferId     *uuid.UUID `gorm:"type:uuid;index"`
+	AssignedById   *uuid.UUID `gorm:"type:uuid"`
+	AcquiredAt     time.Time  `gorm:"not null;index"`
+	CreatedAt      time.Time
+}
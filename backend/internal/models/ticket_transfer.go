@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TicketTransferPending   = "pending"
+	TicketTransferAccepted  = "accepted"
+	TicketTransferDeclined  = "declined"
+	TicketTransferCancelled = "cancelled"
+	TicketTransferExpired   = "expired"
+)
+
+// TicketTransfer est le don gratuit d'un ticket à un autre utilisateur, désigné par son email.
+// Le ticket ne change de détenteur que si le destinataire accepte avant l'échéance ; les
+// transferts acceptés forment l'historique des détenteurs successifs du ticket.
+type TicketTransfer struct {
+	// gorm.Model
+	ID          uuid.UUID `gorm:"unique;type:uuid;primaryKey"`
+	TicketId    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Ticket      Ticket    `gorm:"foreignKey:TicketId"`
+	SenderId    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Sender      User      `gorm:"foreignKey:SenderId"`
+	RecipientId uuid.UUID `gorm:"type:uuid;not null;index"`
+	Recipient   User      `gorm:"foreignKey:RecipientId"`
+	Status      string    `gorm:"not null;index"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	RespondedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time `gorm:"index"`
+}
@@ -48,4 +48,6 @@ type User struct {
 	SalesAsSeller         []Sale         `gorm:"foreignKey:SellerId"`
 	ResetCode             string         `json:"-"`
 	ResetCodeExpiration   time.Time
+	// Réputation calculée à partir des notes reçues après les reventes
+	Reputation *Reputation `gorm:"-" json:",omitempty"`
 }
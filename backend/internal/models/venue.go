@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fuseau des salles dont le fuseau n'est pas précisé, celui qu'utilisait la base avant les salles
+const DefaultVenueTimeZone = "Europe/Paris"
+
+// Venue est une salle de concert appartenant à une organisation. Les coordonnées sont absentes
+// pour les salles créées à partir d'un ancien lieu en texte libre. La date d'un concert est
+// saisie à l'heure locale de sa salle (TimeZone, nom IANA).
+type Venue struct {
+	// gorm.Model
+	ID             uuid.UUID     `gorm:"unique;type:uuid;primaryKey"`
+	OrganizationId uuid.UUID     `gorm:"type:uuid;not null;index"`
+	Organization   *Organization `gorm:"foreignKey:OrganizationId"`
+	Name           string        `gorm:"not null"`
+	Address        string
+	City           string
+	PostalCode     string
+	Country        string
+	Latitude       *float64 `gorm:"index:idx_venue_coordinates"`
+	Longitude      *float64 `gorm:"index:idx_venue_coordinates"`
+	TimeZone       string   `gorm:"not null;default:Europe/Paris"`
+	Capacity       int      `gorm:"not null;default:0"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time `gorm:"index"`
+	Concerts       []Concert  `gorm:"foreignKey:VenueId"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WaitlistActive    = "active"
+	WaitlistFulfilled = "fulfilled"
+	WaitlistCancelled = "cancelled"
+)
+
+const (
+	WaitlistAlertOpen      = "open"
+	WaitlistAlertExpired   = "expired"
+	WaitlistAlertConverted = "converted"
+)
+
+// WaitlistEntry est l'inscription d'un utilisateur aux alertes d'un concert, éventuellement
+// limitée à une catégorie et à un prix maximum (MaxPrice nul : pas de limite). Les inscrits sont
+// prévenus dans l'ordre d'inscription.
+type WaitlistEntry struct {
+	// gorm.Model
+	ID                uuid.UUID        `gorm:"unique;type:uuid;primaryKey"`
+	UserId            uuid.UUID        `gorm:"type:uuid;not null;index"`
+	User              User             `gorm:"foreignKey:UserId"`
+	ConcertId         uuid.UUID        `gorm:"type:uuid;not null;index"`
+	Concert           Concert          `gorm:"foreignKey:ConcertId"`
+	ConcertCategoryId *uuid.UUID       `gorm:"type:uuid;index"`
+	ConcertCategory   *ConcertCategory `gorm:"foreignKey:ConcertCategoryId"`
+	MaxPrice          Money            `gorm:"embedded;embeddedPrefix:max_price_"`
+	Status            string           `gorm:"not null;index"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time `gorm:"index"`
+}
+
+// WaitlistAlert est la fenêtre d'achat exclusive accordée à un inscrit : l'annonce lui est
+// réservée (TicketListingId) ou une place de la catégorie lui est bloquée (ConcertCategoryId)
+// jusqu'à ExpiresAt, après quoi l'inscrit suivant est prévenu.
+type WaitlistAlert struct {
+	// gorm.Model
+	ID                uuid.UUID     `gorm:"unique;type:uuid;primaryKey"`
+	EntryId           uuid.UUID     `gorm:"type:uuid;not null;index"`
+	Entry             WaitlistEntry `gorm:"foreignKey:EntryId"`
+	UserId            uuid.UUID     `gorm:"type:uuid;not null;index"`
+	TicketListingId   *uuid.UUID    `gorm:"type:uuid;index"`
+	ConcertCategoryId *uuid.UUID    `gorm:"type:uuid;index"`
+	Price             Money         `gorm:"embedded;embeddedPrefix:price_"`
+	Status            string        `gorm:"not null;index"`
+	ExpiresAt         time.Time     `gorm:"not null;index"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time `gorm:"index"`
+}
@@ -0,0 +1,128 @@
+package payments
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// FakeProvider est un prestataire en mémoire pour les tests et le développement local.
+// Les intents sont confirmés dès leur création, sauf si AutoSucceed est désactivé.
+type FakeProvider struct {
+	mutex       sync.Mutex
+	intents     map[string]*Intent
+	refunds     map[string][]Refund
+	refundKeys  map[string]Refund
+	AutoSucceed bool
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		intents:     make(map[string]*Intent),
+		refunds:     make(map[string][]Refund),
+		refundKeys:  make(map[string]Refund),
+		AutoSucceed: true,
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateIntent(ctx context.Context, params CreateIntentParams) (*Intent, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	id := "pi_fake_" + uuid.New().String()
+	intent := &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret_fake",
+		Amount:       params.Amount.Amount,
+		Currency:     strings.ToLower(params.Amount.Currency),
+		Status:       StatusRequiresPaymentMethod,
+	}
+	if p.AutoSucceed {
+		intent.Status = StatusSucceeded
+	}
+	p.intents[id] = intent
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, intentID string) (*Intent, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	intent.Status = StatusSucceeded
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if refund, ok := p.refundKeys[idempotencyKey]; ok && idempotencyKey != "" {
+		return &refund, nil
+	}
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if amount == 0 {
+		amount = intent.Amount
+	}
+
+	refund := Refund{
+		ID:       "re_fake_" + uuid.New().String(),
+		IntentID: intentID,
+		Amount:   amount,
+		Status:   StatusSucceeded,
+	}
+	p.refunds[intentID] = append(p.refunds[intentID], refund)
+	if idempotencyKey != "" {
+		p.refundKeys[idempotencyKey] = refund
+	}
+	return &refund, nil
+}
+
+func (p *FakeProvider) FetchStatus(ctx context.Context, intentID string) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return "", ErrIntentNotFound
+	}
+	return intent.Status, nil
+}
+
+// SetStatus force le statut d'un intent, pour simuler un paiement confirmé ou refusé
+func (p *FakeProvider) SetStatus(intentID, status string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return ErrIntentNotFound
+	}
+	intent.Status = status
+	return nil
+}
+
+// Refunds renvoie les remboursements effectués sur un intent
+func (p *FakeProvider) Refunds(intentID string) []Refund {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return append([]Refund(nil), p.refunds[intentID]...)
+}
@@ -0,0 +1,70 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"weezemaster/internal/config"
+	"weezemaster/internal/models"
+)
+
+// Statuts de payment intent communs à tous les prestataires (repris de Stripe)
+const (
+	StatusRequiresPaymentMethod = "requires_payment_method"
+	StatusRequiresCapture       = "requires_capture"
+	StatusSucceeded             = "succeeded"
+	StatusCanceled              = "canceled"
+)
+
+var ErrIntentNotFound = errors.New("payment intent not found")
+
+type CreateIntentParams struct {
+	Amount   models.Money
+	Metadata map[string]string
+}
+
+// Intent est un payment intent tel que renvoyé par le prestataire
+type Intent struct {
+	ID           string `json:"id"`
+	ClientSecret string `json:"client_secret"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+}
+
+type Refund struct {
+	ID       string `json:"id"`
+	IntentID string `json:"payment_intent"`
+	Amount   int64  `json:"amount"`
+	Status   string `json:"status"`
+}
+
+// Provider abstrait le prestataire de paiement utilisé par les contrôleurs
+type Provider interface {
+	Name() string
+	CreateIntent(ctx context.Context, params CreateIntentParams) (*Intent, error)
+	Capture(ctx context.Context, intentID string) (*Intent, error)
+	// Refund rembourse un payment intent ; un montant à 0 rembourse la totalité.
+	// Deux appels avec la même clé d'idempotence ne produisent qu'un seul remboursement.
+	Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error)
+	FetchStatus(ctx context.Context, intentID string) (string, error)
+}
+
+var provider Provider
+
+// Init instancie le prestataire choisi par la configuration (PAYMENT_PROVIDER)
+func Init() error {
+	switch config.PaymentProvider {
+	case "stripe":
+		provider = NewStripeProvider(config.StripeSecretKey, config.StripeApiUrl)
+	case "fake":
+		provider = NewFakeProvider()
+	default:
+		return fmt.Errorf("unknown payment provider %q", config.PaymentProvider)
+	}
+	return nil
+}
+
+func GetProvider() Provider {
+	return provider
+}
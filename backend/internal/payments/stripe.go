@@ -0,0 +1,174 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Écart maximum accepté entre l'horodatage signé par Stripe et l'heure du serveur
+const stripeSignatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid Stripe signature")
+
+type StripeProvider struct {
+	secretKey string
+	baseURL   string
+	client    *http.Client
+}
+
+func NewStripeProvider(secretKey, baseURL string) *StripeProvider {
+	return &StripeProvider{
+		secretKey: secretKey,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// do envoie une requête à l'API Stripe et décode la réponse JSON dans out. Une clé d'idempotence
+// non vide garantit qu'une requête rejouée n'est exécutée qu'une fois par Stripe.
+func (p *StripeProvider) do(ctx context.Context, method, path string, data url.Values, idempotencyKey string, out interface{}) error {
+	var body io.Reader
+	if data != nil {
+		body = strings.NewReader(data.Encode())
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+p.secretKey)
+	if data != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		request.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrIntentNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		var stripeErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(response.Body).Decode(&stripeErr)
+		return fmt.Errorf("stripe returned status %d: %s", response.StatusCode, stripeErr.Error.Message)
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func (p *StripeProvider) CreateIntent(ctx context.Context, params CreateIntentParams) (*Intent, error) {
+	data := url.Values{}
+	data.Set("amount", strconv.FormatInt(params.Amount.Amount, 10))
+	data.Set("currency", strings.ToLower(params.Amount.Currency))
+	data.Set("payment_method_types[]", "card")
+	for key, value := range params.Metadata {
+		data.Set("metadata["+key+"]", value)
+	}
+
+	var intent Intent
+	if err := p.do(ctx, "POST", "/v1/payment_intents", data, "", &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (p *StripeProvider) Capture(ctx context.Context, intentID string) (*Intent, error) {
+	var intent Intent
+	if err := p.do(ctx, "POST", "/v1/payment_intents/"+url.PathEscape(intentID)+"/capture", url.Values{}, "", &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (p *StripeProvider) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	data := url.Values{}
+	data.Set("payment_intent", intentID)
+	if amount > 0 {
+		data.Set("amount", strconv.FormatInt(amount, 10))
+	}
+
+	var refund Refund
+	if err := p.do(ctx, "POST", "/v1/refunds", data, idempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (p *StripeProvider) FetchStatus(ctx context.Context, intentID string) (string, error) {
+	var intent Intent
+	if err := p.do(ctx, "GET", "/v1/payment_intents/"+url.PathEscape(intentID), nil, "", &intent); err != nil {
+		return "", err
+	}
+	return intent.Status, nil
+}
+
+// VerifyStripeSignature vérifie l'en-tête Stripe-Signature (t=...,v1=...) d'un webhook
+func VerifyStripeSignature(payload []byte, header, secret string, now time.Time) error {
+	if secret == "" || header == "" {
+		return ErrInvalidSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if delta := now.Sub(time.Unix(unix, 0)); delta > stripeSignatureTolerance || delta < -stripeSignatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := SignStripePayload(payload, timestamp, secret)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignStripePayload calcule la signature v1 d'un payload de webhook Stripe
+func SignStripePayload(payload []byte, timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package payments
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyStripeSignature(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_test","type":"payment_intent.succeeded"}`)
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	valid := SignStripePayload(payload, timestamp, secret)
+
+	header := func(t time.Time, signatures ...string) string {
+		value := "t=" + strconv.FormatInt(t.Unix(), 10)
+		for _, signature := range signatures {
+			value += ",v1=" + signature
+		}
+		return value
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		secret  string
+		wantErr bool
+	}{
+		{"valid signature", payload, header(now, valid), secret, false},
+		{"valid among several v1 signatures", payload, header(now, "deadbeef", valid, "cafe"), secret, false},
+		{"header with spaces and v0 signature", payload, "t=" + timestamp + ", v0=ignored, v1=" + valid, secret, false},
+		{"timestamp within tolerance", payload, header(now.Add(-4*time.Minute), SignStripePayload(payload, strconv.FormatInt(now.Add(-4*time.Minute).Unix(), 10), secret)), secret, false},
+		{"tampered payload", []byte(`{"id":"evt_test","type":"charge.refunded"}`), header(now, valid), secret, true},
+		{"wrong secret", payload, header(now, valid), "whsec_other", true},
+		{"expired timestamp", payload, header(now.Add(-6*time.Minute), SignStripePayload(payload, strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10), secret)), secret, true},
+		{"timestamp too far in the future", payload, header(now.Add(6*time.Minute), SignStripePayload(payload, strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10), secret)), secret, true},
+		{"signature for another timestamp", payload, header(now.Add(time.Second), valid), secret, true},
+		{"several invalid v1 signatures", payload, header(now, "deadbeef", "cafe"), secret, true},
+		{"no v1 signature", payload, header(now), secret, true},
+		{"missing timestamp", payload, "v1=" + valid, secret, true},
+		{"malformed timestamp", payload, "t=abc,v1=" + valid, secret, true},
+		{"empty header", payload, "", secret, true},
+		{"empty secret", payload, header(now, valid), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyStripeSignature(tt.payload, tt.header, tt.secret, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Errorf("VerifyStripeSignature() = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("VerifyStripeSignature() = %v, want nil", err)
+			}
+		})
+	}
+}
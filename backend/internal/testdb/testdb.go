@@ -0,0 +1,65 @@
+// Package testdb fournit aux tests une base PostgreSQL isolée.
+package testdb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Open ouvre la base de test désignée par WEEZEMASTER_TEST_DSN dans un schéma jetable, supprimé à
+// la fin du test, et y crée les tables des modèles donnés. Le test est ignoré si la variable n'est
+// pas définie, ou échoue si WEEZEMASTER_TEST_DB_REQUIRED vaut 1.
+func Open(t testing.TB, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("WEEZEMASTER_TEST_DSN")
+	if dsn == "" {
+		if os.Getenv("WEEZEMASTER_TEST_DB_REQUIRED") == "1" {
+			t.Fatal("WEEZEMASTER_TEST_DSN is not set")
+		}
+		t.Skip("WEEZEMASTER_TEST_DSN is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	// Chaque connexion du pool travaille dans le schéma du test
+	separator := " "
+	if strings.Contains(dsn, "://") {
+		separator = "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+	}
+	db, err := gorm.Open(postgres.Open(dsn+separator+"search_path="+schema), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatalf("open test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if len(tables) > 0 {
+		if err := db.AutoMigrate(tables...); err != nil {
+			t.Fatalf("migrate test schema: %v", err)
+		}
+	}
+	return db
+}
@@ -12,13 +12,31 @@ class ConcertsBloc extends Bloc<ConcertsEvent, ConcertsState> {
       emit(ConcertsLoading());
 
       try {
-        final concerts = await ApiServices.getConcerts();
-        emit(ConcertsDataLoadingSuccess(concerts: concerts));
+        final page = await ApiServices.getConcerts(includeAll: true);
+        emit(ConcertsDataLoadingSuccess(concerts: page.concerts, nextCursor: page.nextCursor));
       } on ApiException catch (error) {
         emit(ConcertsDataLoadingError(errorMessage: error.message));
       } catch (error) {
         emit(ConcertsDataLoadingError(errorMessage: 'Unhandled error'));
       }
     });
+
+    on<ConcertsNextPageRequested>((event, emit) async {
+      final current = state;
+      if (current is! ConcertsDataLoadingSuccess || !current.hasMore || current.isLoadingMore) {
+        return;
+      }
+      emit(current.copyWith(isLoadingMore: true, loadMoreFailed: false));
+
+      try {
+        final page = await ApiServices.getConcerts(cursor: current.nextCursor, includeAll: true);
+        emit(ConcertsDataLoadingSuccess(
+          concerts: [...current.concerts, ...page.concerts],
+          nextCursor: page.nextCursor,
+        ));
+      } catch (error) {
+        emit(current.copyWith(isLoadingMore: false, loadMoreFailed: true));
+      }
+    });
   }
 }
\ No newline at end of file
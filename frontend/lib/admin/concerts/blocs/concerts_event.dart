@@ -3,4 +3,6 @@ part of 'concerts_bloc.dart';
 @immutable
 sealed class ConcertsEvent {}
 
-final class ConcertsDataLoaded extends ConcertsEvent {}
\ No newline at end of file
+final class ConcertsDataLoaded extends ConcertsEvent {}
+
+final class ConcertsNextPageRequested extends ConcertsEvent {}
\ No newline at end of file
@@ -9,8 +9,27 @@ final class ConcertsLoading extends ConcertsState {}
 
 final class ConcertsDataLoadingSuccess extends ConcertsState {
   final List<Concert> concerts;
+  final String nextCursor;
+  final bool isLoadingMore;
+  final bool loadMoreFailed;
 
-  ConcertsDataLoadingSuccess({required this.concerts});
+  ConcertsDataLoadingSuccess({
+    required this.concerts,
+    this.nextCursor = '',
+    this.isLoadingMore = false,
+    this.loadMoreFailed = false,
+  });
+
+  bool get hasMore => nextCursor.isNotEmpty;
+
+  ConcertsDataLoadingSuccess copyWith({bool? isLoadingMore, bool? loadMoreFailed}) {
+    return ConcertsDataLoadingSuccess(
+      concerts: concerts,
+      nextCursor: nextCursor,
+      isLoadingMore: isLoadingMore ?? this.isLoadingMore,
+      loadMoreFailed: loadMoreFailed ?? this.loadMoreFailed,
+    );
+  }
 }
 
 final class ConcertsDataLoadingError extends ConcertsState {
@@ -155,8 +155,26 @@ class ConcertsScreenState extends State<ConcertsScreen> {
             return const Center(child: CircularProgressIndicator());
           } else if (state is ConcertsDataLoadingSuccess) {
             return ListView.builder(
-              itemCount: state.concerts.length,
+              itemCount: state.concerts.length + (state.hasMore ? 1 : 0),
               itemBuilder: (context, index) {
+                // Le loader de fin de liste demande la page suivante quand il devient visible
+                if (index == state.concerts.length) {
+                  if (state.loadMoreFailed) {
+                    return Center(
+                      child: TextButton(
+                        onPressed: () {
+                          context.read<ConcertsBloc>().add(ConcertsNextPageRequested());
+                        },
+                        child: Text(translate(context)!.retry),
+                      ),
+                    );
+                  }
+                  context.read<ConcertsBloc>().add(ConcertsNextPageRequested());
+                  return const Padding(
+                    padding: EdgeInsets.symmetric(vertical: 16.0),
+                    child: Center(child: CircularProgressIndicator()),
+                  );
+                }
                 final concert = state.concerts[index];
                 return ListTile(
                   title: Text(concert.name),
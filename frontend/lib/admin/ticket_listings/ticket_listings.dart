@@ -22,6 +22,23 @@ class _TicketListingsScreenState extends State<TicketListingsScreen> {
     super.initState();
   }
 
+  String _statusLabel(BuildContext context, String status) {
+    switch (status) {
+      case 'available':
+        return translate(context)!.available;
+      case 'reserved':
+        return translate(context)!.reserved;
+      case 'sold':
+        return translate(context)!.sold;
+      case 'withdrawn':
+        return translate(context)!.withdrawn;
+      case 'expired':
+        return translate(context)!.expired;
+      default:
+        return status;
+    }
+  }
+
   Future<void> _showUpdateDialog(BuildContext context, TicketListing ticketListing) async {
     final ticketListingsBloc = context.read<TicketListingsBloc>();
 
@@ -65,16 +82,13 @@ class _TicketListingsScreenState extends State<TicketListingsScreen> {
                         _status = value;
                       });
                     },
-                    items: [
-                      DropdownMenuItem(
-                        value: 'available',
-                        child: Text(translate(context)!.available),
-                      ),
-                      DropdownMenuItem(
-                        value: 'sold',
-                        child: Text(translate(context)!.sold),
-                      ),
-                    ],
+                    // Une annonce ne peut être que retirée : les autres statuts suivent la vente
+                    items: {ticketListing.status, 'withdrawn'}
+                        .map((status) => DropdownMenuItem(
+                              value: status,
+                              child: Text(_statusLabel(context, status)),
+                            ))
+                        .toList(),
                   ),
                 ],
               ),
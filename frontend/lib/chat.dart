@@ -7,6 +7,7 @@ import 'package:flutter_secure_storage/flutter_secure_storage.dart';
 import 'package:go_router/go_router.dart';
 import 'package:intl/intl.dart';
 import 'package:weezemaster/core/exceptions/api_exception.dart';
+import 'package:weezemaster/core/models/money.dart';
 import 'package:weezemaster/translation.dart';
 import 'components/message_bubble.dart';
 import 'components/ticket_details.dart';
@@ -105,8 +106,8 @@ class ChatScreenState extends State<ChatScreen> {
     });
 
     try {
-      final apiUrl = '${dotenv.env['API_PROTOCOL']}://${dotenv.env['API_HOST']}${dotenv.env['API_PORT']}/conversations/$conversationId';
-      final response = await http.patch(
+      final apiUrl = '${dotenv.env['API_PROTOCOL']}://${dotenv.env['API_HOST']}${dotenv.env['API_PORT']}/conversations/$conversationId/offers';
+      final response = await http.post(
         Uri.parse(apiUrl),
         headers: {
           'Content-Type': 'application/json',
@@ -115,17 +116,12 @@ class ChatScreenState extends State<ChatScreen> {
         body: body,
       );
 
-      if (response.statusCode == 200) {
-        debugPrint('Price updated');
+      if (response.statusCode == 201) {
+        // Le serveur diffuse lui-même la nouvelle offre sur le WebSocket du chat
+        debugPrint('Offer created');
         ScaffoldMessenger.of(context).showSnackBar(
           SnackBar(content: Text(translate(context)!.price_success)),
         );
-        final priceMessage = {
-          "conversation_id": widget.id,
-          "new_price": price,
-        };
-        _channel?.sink.add(jsonEncode(priceMessage));
-        debugPrint('Price update message WebSocket sent: $priceMessage');
       } else {
         debugPrint('Failed to update price: ${response.body}');
         ScaffoldMessenger.of(context).showSnackBar(
@@ -142,6 +138,49 @@ class ChatScreenState extends State<ChatScreen> {
     }
   }
 
+  // Accepte l'offre en attente du vendeur : un ticket ne s'achète qu'au prix d'une offre acceptée
+  Future<bool> acceptCurrentOffer(String conversationId) async {
+    final tokenService = TokenService();
+    String? jwtToken = await tokenService.getValidAccessToken();
+    final baseUrl = '${dotenv.env['API_PROTOCOL']}://${dotenv.env['API_HOST']}${dotenv.env['API_PORT']}';
+
+    try {
+      final response = await http.get(
+        Uri.parse('$baseUrl/conversations/$conversationId/offers'),
+        headers: {'Authorization': 'Bearer $jwtToken'},
+      );
+      if (response.statusCode != 200) {
+        debugPrint('Failed to fetch offers: ${response.body}');
+        return false;
+      }
+
+      final List<dynamic> offers = jsonDecode(response.body);
+      if (offers.isEmpty) {
+        return false;
+      }
+      final latest = offers.first;
+      if (latest['Status'] == 'accepted') {
+        return true;
+      }
+      if (latest['Status'] != 'pending' || latest['ProposerId'] == userId) {
+        return false;
+      }
+
+      final acceptResponse = await http.post(
+        Uri.parse('$baseUrl/offers/${latest['ID']}/accept'),
+        headers: {'Authorization': 'Bearer $jwtToken'},
+      );
+      if (acceptResponse.statusCode != 200) {
+        debugPrint('Failed to accept offer: ${acceptResponse.body}');
+        return false;
+      }
+      return true;
+    } catch (e) {
+      debugPrint('Error accepting offer: $e');
+      return false;
+    }
+  }
+
   @override
   void initState() {
     super.initState();
@@ -209,9 +248,10 @@ class ChatScreenState extends State<ChatScreen> {
         setState(() {
           widget.id = decodedMessage['conversation_id'];
         });
-        if (decodedMessage.containsKey('new_price')) {
+        final offerStatus = decodedMessage['status'];
+        if (decodedMessage.containsKey('new_price') && (offerStatus == 'pending' || offerStatus == 'accepted')) {
           setState(() {
-            price = decodedMessage['new_price'].toString();
+            price = moneyToDouble(decodedMessage['new_price']).toString();
           });
         }
       } else {
@@ -312,8 +352,8 @@ class ChatScreenState extends State<ChatScreen> {
           buyerId = conversation["BuyerId"];
           if (conversation['Concert'] != null) {
             concertName = conversation['Concert']['Name'] ?? "Unknown Concert";
-            price = conversation['Price'].toString() ?? "0";
-            maxPrice = conversation['TicketListing']['Price'].toString() ?? "0";
+            price = moneyToDouble(conversation['Price']).toString();
+            maxPrice = moneyToDouble(conversation['TicketListing']['Price']).toString();
             resellerName = conversation['SellerName'] ?? "Unknown Seller";
             buyerName = conversation['BuyerName'] ?? "Unknown Buyer";
             category = conversation['Category'] ?? "Unknown Category";
@@ -506,6 +546,10 @@ class ChatScreenState extends State<ChatScreen> {
                     if (token == null) {
                       context.read<NavigationCubit>().updateUserRole('');
                       GoRouter.of(context).go(Routes.loginRegisterNamedPage);
+                    } else if (!await acceptCurrentOffer(widget.id)) {
+                      ScaffoldMessenger.of(context).showSnackBar(
+                        SnackBar(content: Text(translate(context)!.payment_error)),
+                      );
                     } else {
                       final paymentIntentData = await paymentService.createPaymentIntent(widget.id, 'cv_');
                       if (paymentIntentData != null) {
@@ -35,8 +35,8 @@ class _ConcertListItemState extends State<ConcertListItem> {
     return dateFormat.format(dateTime);
   }
 
-  Future<void> joinQueueOrConcertPage(BuildContext context, String concertId, String userId) async {
-    webSocketService.connect(concertId, userId);
+  Future<void> joinQueueOrConcertPage(BuildContext context, String concertId, String accessToken) async {
+    webSocketService.connect(concertId, accessToken);
 
     // Accès au flux de diffusion
     final broadcastStream = webSocketService.stream;
@@ -136,29 +136,8 @@ class _ConcertListItemState extends State<ConcertListItem> {
             pathParameters: {'id': widget.concert.id},
           );
         } else {
-          final parts = token.split('.');
-          if (parts.length != 3) {
-            throw Exception('Invalid token');
-          }
-
-          String output = parts[1].replaceAll('-', '+').replaceAll('_', '/');
-          switch (output.length % 4) {
-            case 0:
-              break;
-            case 2:
-              output += '==';
-              break;
-            case 3:
-              output += '=';
-              break;
-            default:
-              throw Exception('Illegal base64url string!"');
-          }
-
-          String userId = json.decode(utf8.decode(base64.decode(output)))['id'];
-
-          debugPrint('Joining queue or concert page for concert: ${widget.concert.id} and user: $userId');
-          await joinQueueOrConcertPage(context, widget.concert.id, userId);
+          debugPrint('Joining queue or concert page for concert: ${widget.concert.id}');
+          await joinQueueOrConcertPage(context, widget.concert.id, token);
         }
       },
       child: Padding(
@@ -1,3 +1,4 @@
+import 'package:weezemaster/core/models/money.dart';
 import 'package:weezemaster/core/models/category.dart';
 import 'package:weezemaster/core/models/concert.dart';
 import 'package:weezemaster/core/models/ticket.dart';
@@ -29,7 +30,7 @@ class ConcertCategory {
       id: json['ID'],
       availableTickets: json['AvailableTickets'] ?? 0,
       soldTickets: json['SoldTickets'] ?? 0,
-      price: (json['Price'] ?? 0).toDouble(),
+      price: moneyToDouble(json['Price']),
       tickets: ticketList,
       category: Category.fromJson(json['Category']),
       concert: Concert.fromJson(json['Concert']),
@@ -0,0 +1,23 @@
+import 'package:weezemaster/core/models/concert.dart';
+
+// Page du catalogue des concerts. nextCursor est vide quand il n'y a plus de page à charger.
+class ConcertPage {
+  final List<Concert> concerts;
+  final String nextCursor;
+
+  ConcertPage({
+    required this.concerts,
+    required this.nextCursor,
+  });
+
+  bool get hasMore => nextCursor.isNotEmpty;
+
+  factory ConcertPage.fromJson(Map<String, dynamic> json) {
+    var itemsFromJson = json['items'] as List? ?? [];
+
+    return ConcertPage(
+      concerts: itemsFromJson.map((e) => Concert.fromJson(e)).toList(),
+      nextCursor: json['nextCursor'] as String? ?? '',
+    );
+  }
+}
@@ -1,3 +1,4 @@
+import 'package:weezemaster/core/models/money.dart';
 import 'package:weezemaster/core/models/ticket_listing.dart';
 import 'package:weezemaster/core/models/seller_buyer.dart';
 
@@ -40,7 +41,7 @@ class Conversation {
       ticketListingId: json['TicketListingId'],
       ticketListing: TicketListing.fromJson(json['TicketListing']),
       messages: json['Messages'] ?? [],
-      price: (json['Price'] ?? 0).toDouble(),
+      price: moneyToDouble(json['Price']),
     );
   }
 }
\ No newline at end of file
@@ -0,0 +1,11 @@
+// Les prix sont renvoyés par l'API sous la forme {"amount": 1250, "currency": "EUR"},
+// le montant étant exprimé en centimes.
+double moneyToDouble(dynamic json) {
+  if (json == null) {
+    return 0;
+  }
+  if (json is num) {
+    return json.toDouble();
+  }
+  return ((json['amount'] ?? 0) as num) / 100;
+}
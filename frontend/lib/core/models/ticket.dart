@@ -1,3 +1,4 @@
+import 'package:weezemaster/core/models/money.dart';
 import 'package:weezemaster/core/models/concert_category.dart';
 import 'package:weezemaster/core/models/user.dart';
 import 'package:weezemaster/core/models/ticket_listing.dart';
@@ -23,7 +24,7 @@ class Ticket {
       user: User.fromJson(json['User']),
       concertCategory: ConcertCategory.fromJson(json['ConcertCategory']),
       ticketListings: (json['TicketListings'] as List? ?? []).map((i) => TicketListing.fromJson(i)).toList(),
-      maxPrice: (json['MaxPrice'] ?? 0).toDouble(),
+      maxPrice: moneyToDouble(json['MaxPrice']),
     );
   }
 }
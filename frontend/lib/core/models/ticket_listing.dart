@@ -1,3 +1,4 @@
+import 'package:weezemaster/core/models/money.dart';
 import 'package:weezemaster/core/models/ticket.dart';
 
 class TicketListing {
@@ -22,7 +23,7 @@ class TicketListing {
   factory TicketListing.fromJson(Map<String, dynamic> json) {
     return TicketListing(
       id: json['ID'],
-      price: (json['Price'] as num).toDouble(),
+      price: moneyToDouble(json['Price']),
       status: json['Status'],
       ticketId: json['TicketId'],
       ticket: Ticket.fromJson(json['Ticket']),
@@ -9,6 +9,7 @@ import 'package:flutter_secure_storage/flutter_secure_storage.dart';
 import 'package:weezemaster/core/exceptions/api_exception.dart';
 import 'package:weezemaster/core/models/artist.dart';
 import 'package:weezemaster/core/models/concert.dart';
+import 'package:weezemaster/core/models/concert_page.dart';
 import 'package:weezemaster/core/models/interest.dart';
 import 'package:weezemaster/core/models/category.dart';
 import 'package:weezemaster/core/models/log.dart';
@@ -20,25 +21,34 @@ import 'package:weezemaster/core/models/user.dart';
 
 class ApiServices {
   static const storage = FlutterSecureStorage();
-  static Future<List<Concert>> getConcerts() async {
+  // Parcourt les pages du catalogue ; l'administration demande aussi les concerts passés et annulés
+  // Charge une page du catalogue. Le nextCursor de la page renvoyée donne la page suivante.
+  static Future<ConcertPage> getConcerts({String cursor = '', int limit = 20, bool includeAll = false}) async {
     try {
       final tokenService = TokenService();
       String? jwtToken = await tokenService.getValidAccessToken();
       final apiUrl = '${dotenv.env['API_PROTOCOL']}://${dotenv.env['API_HOST']}${dotenv.env['API_PORT']}/concerts';
+      final queryParameters = <String, String>{'limit': '$limit'};
+      if (includeAll) {
+        queryParameters['includePast'] = 'true';
+        queryParameters['includeCancelled'] = 'true';
+      }
+      if (cursor.isNotEmpty) {
+        queryParameters['cursor'] = cursor;
+      }
       final response = await http.get(
-        Uri.parse(apiUrl),
+        Uri.parse(apiUrl).replace(queryParameters: queryParameters),
         headers: {
           'Accept': 'application/json; charset=UTF-8',
           'Authorization': 'Bearer $jwtToken',
         },
       );
-      await Future.delayed(const Duration(seconds: 1));
       if (response.statusCode < 200 || response.statusCode >= 400) {
         throw ApiException(message: 'Bad request');
       }
 
-      final data = json.decode(response.body) as List<dynamic>;
-      return data.mapList((e) => Concert.fromJson(e));
+      final data = json.decode(response.body) as Map<String, dynamic>;
+      return ConcertPage.fromJson(data);
     } on SocketException catch (error) {
       log('Network error.', error: error);
       throw ApiException(message: 'Network error');
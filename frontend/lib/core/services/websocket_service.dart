@@ -17,16 +17,18 @@ class WebSocketService {
 
   Stream? get stream => _broadcastStream;
 
-  void connect(String concertId, String userId) {
+  // L'utilisateur de la file d'attente est celui de l'access token, vérifié par le serveur
+  void connect(String concertId, String accessToken) {
     if (_channel != null) {
       debugPrint('WebSocket already connected.');
       return;
     }
 
     final protocol = dotenv.env['API_PROTOCOL'] == 'http' ? 'ws' : 'wss';
-    final wsUrl = Uri.parse('$protocol://${dotenv.env['API_HOST']}${dotenv.env['API_PORT']}/ws-queue?concertId=$concertId&userId=$userId');
+    final wsUrl = Uri.parse('$protocol://${dotenv.env['API_HOST']}${dotenv.env['API_PORT']}/ws-queue')
+        .replace(queryParameters: {'concertId': concertId, 'token': accessToken});
 
-    debugPrint('Attempting WebSocket connection to: $wsUrl');
+    debugPrint('Attempting WebSocket connection for concert: $concertId');
     
     _channel = WebSocketChannel.connect(wsUrl);
     _broadcastStream = _channel!.stream.asBroadcastStream();
@@ -37,20 +37,39 @@ class HomeBloc extends Bloc<HomeEvent, HomeState> {
 
       try {
         final userRole = await getUserRoleFromJwt();
-        final List<Concert> concerts;
 
         if (userRole == 'organizer') {
-          concerts = await ApiServices.getConcertsByOrga();
+          final concerts = await ApiServices.getConcertsByOrga();
+          emit(HomeDataLoadingSuccess(concerts: concerts));
         } else {
-          concerts = await ApiServices.getConcerts();
+          final page = await ApiServices.getConcerts();
+          emit(HomeDataLoadingSuccess(concerts: page.concerts, nextCursor: page.nextCursor));
         }
-
-        emit(HomeDataLoadingSuccess(concerts: concerts));
       } on ApiException catch (error) {
         emit(HomeDataLoadingError(errorMessage: error.message));
       } catch (error) {
         emit(HomeDataLoadingError(errorMessage: 'Unhandled error'));
       }
     });
+
+    // Charge la page suivante du catalogue quand la liste arrive en bas de l'écran
+    on<HomeNextPageRequested>((event, emit) async {
+      final current = state;
+      if (current is! HomeDataLoadingSuccess || !current.hasMore || current.isLoadingMore) {
+        return;
+      }
+      emit(current.copyWith(isLoadingMore: true, loadMoreFailed: false));
+
+      try {
+        final page = await ApiServices.getConcerts(cursor: current.nextCursor);
+        emit(HomeDataLoadingSuccess(
+          concerts: [...current.concerts, ...page.concerts],
+          nextCursor: page.nextCursor,
+        ));
+      } catch (error) {
+        // Les concerts déjà chargés restent affichés, l'utilisateur peut relancer le chargement
+        emit(current.copyWith(isLoadingMore: false, loadMoreFailed: true));
+      }
+    });
   }
 }
\ No newline at end of file
@@ -3,4 +3,6 @@ part of 'home_bloc.dart';
 @immutable
 sealed class HomeEvent {}
 
-final class HomeDataLoaded extends HomeEvent {}
\ No newline at end of file
+final class HomeDataLoaded extends HomeEvent {}
+
+final class HomeNextPageRequested extends HomeEvent {}
\ No newline at end of file
@@ -9,8 +9,27 @@ final class HomeLoading extends HomeState {}
 
 final class HomeDataLoadingSuccess extends HomeState {
   final List<Concert> concerts;
+  final String nextCursor;
+  final bool isLoadingMore;
+  final bool loadMoreFailed;
 
-  HomeDataLoadingSuccess({required this.concerts});
+  HomeDataLoadingSuccess({
+    required this.concerts,
+    this.nextCursor = '',
+    this.isLoadingMore = false,
+    this.loadMoreFailed = false,
+  });
+
+  bool get hasMore => nextCursor.isNotEmpty;
+
+  HomeDataLoadingSuccess copyWith({bool? isLoadingMore, bool? loadMoreFailed}) {
+    return HomeDataLoadingSuccess(
+      concerts: concerts,
+      nextCursor: nextCursor,
+      isLoadingMore: isLoadingMore ?? this.isLoadingMore,
+      loadMoreFailed: loadMoreFailed ?? this.loadMoreFailed,
+    );
+  }
 }
 
 final class HomeDataLoadingError extends HomeState {
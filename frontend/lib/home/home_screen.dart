@@ -145,8 +145,8 @@ class HomeScreenState extends State<HomeScreen> {
 
   final webSocketService = WebSocketService();
   
-  Future<void> joinQueueOrConcertPage(String concertId, String userId) async {
-    webSocketService.connect(concertId, userId);
+  Future<void> joinQueueOrConcertPage(String concertId, String accessToken) async {
+    webSocketService.connect(concertId, accessToken);
 
     // Accès au flux de diffusion
     final broadcastStream = webSocketService.stream;
@@ -359,10 +359,29 @@ class HomeScreenState extends State<HomeScreen> {
                       Expanded(
                         child: ListView.builder(
                           itemBuilder: (context, index) {
+                            // Le loader de fin de liste est construit à l'approche du bas de l'écran :
+                            // il demande la page suivante du catalogue
+                            if (index == _filteredConcerts.length) {
+                              if (state.loadMoreFailed) {
+                                return Center(
+                                  child: TextButton(
+                                    onPressed: () {
+                                      context.read<HomeBloc>().add(HomeNextPageRequested());
+                                    },
+                                    child: Text(translate(context)!.retry),
+                                  ),
+                                );
+                              }
+                              context.read<HomeBloc>().add(HomeNextPageRequested());
+                              return const Padding(
+                                padding: EdgeInsets.symmetric(vertical: 16.0),
+                                child: Center(child: CircularProgressIndicator()),
+                              );
+                            }
                             final concert = _filteredConcerts[index];
                             return ConcertListItem(concert: concert);
                           },
-                          itemCount: _filteredConcerts.length,
+                          itemCount: _filteredConcerts.length + (state.hasMore ? 1 : 0),
                         ),
                       ),
                     ],